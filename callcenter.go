@@ -0,0 +1,613 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallcenterInfo - Subclass of CUSTOM events mod_callcenter reports queue, agent and member changes with
+const CallcenterInfo = "callcenter::info"
+
+// AgentType - How mod_callcenter reaches the agent
+type AgentType string
+
+const (
+	AgentTypeCallback    AgentType = "callback"
+	AgentTypeUUIDStandby AgentType = "uuid-standby"
+)
+
+// AgentStatus - Whether agent is willing to take calls
+type AgentStatus string
+
+const (
+	AgentStatusLoggedOut         AgentStatus = "Logged Out"
+	AgentStatusAvailable         AgentStatus = "Available"
+	AgentStatusAvailableOnDemand AgentStatus = "Available (On Demand)"
+	AgentStatusOnBreak           AgentStatus = "On Break"
+)
+
+// AgentState - What agent is doing at the moment
+type AgentState string
+
+const (
+	AgentStateIdle        AgentState = "Idle"
+	AgentStateWaiting     AgentState = "Waiting"
+	AgentStateReceiving   AgentState = "Receiving"
+	AgentStateInQueueCall AgentState = "In a queue call"
+)
+
+// TierState - State of agent within the queue
+type TierState string
+
+const (
+	TierStateUnknown       TierState = "Unknown"
+	TierStateNoAnswer      TierState = "No Answer"
+	TierStateReady         TierState = "Ready"
+	TierStateOffering      TierState = "Offering"
+	TierStateActiveInbound TierState = "Active Inbound"
+	TierStateStandby       TierState = "Standby"
+)
+
+// CallcenterAgent - Single agent as reported by `callcenter_config agent list` or `queue list agents`
+type CallcenterAgent struct {
+	Name               string
+	InstanceID         string
+	UUID               string
+	Type               AgentType
+	Contact            string
+	Status             AgentStatus
+	State              AgentState
+	MaxNoAnswer        int
+	WrapUpTime         int
+	RejectDelayTime    int
+	BusyDelayTime      int
+	NoAnswerDelayTime  int
+	LastBridgeStart    time.Time
+	LastBridgeEnd      time.Time
+	LastOfferedCall    time.Time
+	LastStatusChange   time.Time
+	NoAnswerCount      int
+	CallsAnswered      int
+	TalkTime           int
+	ReadyTime          int
+	ExternalCallsCount int
+}
+
+// CallcenterTier - Single tier (agent to queue assignment) as reported by `callcenter_config tier list`
+type CallcenterTier struct {
+	Queue    string
+	Agent    string
+	State    TierState
+	Level    int
+	Position int
+}
+
+// CallcenterMember - Single caller waiting in or being served by the queue (`callcenter_config queue list members`)
+type CallcenterMember struct {
+	Queue          string
+	InstanceID     string
+	UUID           string
+	SessionUUID    string
+	CIDNumber      string
+	CIDName        string
+	SystemEpoch    time.Time
+	JoinedEpoch    time.Time
+	RejoinedEpoch  time.Time
+	BridgeEpoch    time.Time
+	AbandonedEpoch time.Time
+	BaseScore      int
+	SkillScore     int
+	ServingAgent   string
+	ServingSystem  string
+	State          string
+}
+
+// CallcenterQueue - Single queue as reported by `callcenter_config queue list`
+type CallcenterQueue struct {
+	Name                  string
+	Strategy              string
+	MOHSound              string
+	TimeBaseScore         string
+	TierRulesApply        bool
+	MaxWaitTime           int
+	MaxWaitTimeNoAgent    int
+	DiscardAbandonedAfter int
+	RecordTemplate        string
+	CallsAnswered         int
+	CallsAbandoned        int
+}
+
+// ccRecord - Single row of callcenter_config list output keyed by column names
+type ccRecord map[string]string
+
+func (r ccRecord) int(name string) int {
+	v, _ := strconv.Atoi(r[name])
+	return v
+}
+
+// parseCallcenterList - Will parse `|` delimited list output. Header comes first and +OK closes the list. Free is
+// the column that may contain | itself (agent contact, member caller name), there is no other output format.
+func parseCallcenterList(body []byte, free string) ([]ccRecord, error) {
+	lines := []string{}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line == "+OK" {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(lines[0], "-ERR") {
+		return nil, &ReplyError{Reply: strings.TrimSpace(strings.TrimPrefix(lines[0], "-ERR"))}
+	}
+
+	records, err := parseDelimited(strings.Join(lines, "\n"), free)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ccRecord, len(records))
+	for i, r := range records {
+		rows[i] = r
+	}
+
+	return rows, nil
+}
+
+// ParseCallcenterAgents - Will parse output of `callcenter_config agent list` or `callcenter_config queue list agents`
+func ParseCallcenterAgents(body []byte) ([]CallcenterAgent, error) {
+	rows, err := parseCallcenterList(body, "contact")
+	if err != nil {
+		return nil, err
+	}
+
+	agents := make([]CallcenterAgent, 0, len(rows))
+	for _, r := range rows {
+		agents = append(agents, CallcenterAgent{
+			Name:               r["name"],
+			InstanceID:         r["instance_id"],
+			UUID:               r["uuid"],
+			Type:               AgentType(r["type"]),
+			Contact:            r["contact"],
+			Status:             AgentStatus(r["status"]),
+			State:              AgentState(r["state"]),
+			MaxNoAnswer:        r.int("max_no_answer"),
+			WrapUpTime:         r.int("wrap_up_time"),
+			RejectDelayTime:    r.int("reject_delay_time"),
+			BusyDelayTime:      r.int("busy_delay_time"),
+			NoAnswerDelayTime:  r.int("no_answer_delay_time"),
+			LastBridgeStart:    epochTime(r["last_bridge_start"]),
+			LastBridgeEnd:      epochTime(r["last_bridge_end"]),
+			LastOfferedCall:    epochTime(r["last_offered_call"]),
+			LastStatusChange:   epochTime(r["last_status_change"]),
+			NoAnswerCount:      r.int("no_answer_count"),
+			CallsAnswered:      r.int("calls_answered"),
+			TalkTime:           r.int("talk_time"),
+			ReadyTime:          r.int("ready_time"),
+			ExternalCallsCount: r.int("external_calls_count"),
+		})
+	}
+
+	return agents, nil
+}
+
+// ParseCallcenterTiers - Will parse output of `callcenter_config tier list` or `callcenter_config queue list tiers`
+func ParseCallcenterTiers(body []byte) ([]CallcenterTier, error) {
+	rows, err := parseCallcenterList(body, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make([]CallcenterTier, 0, len(rows))
+	for _, r := range rows {
+		tiers = append(tiers, CallcenterTier{
+			Queue:    r["queue"],
+			Agent:    r["agent"],
+			State:    TierState(r["state"]),
+			Level:    r.int("level"),
+			Position: r.int("position"),
+		})
+	}
+
+	return tiers, nil
+}
+
+// ParseCallcenterMembers - Will parse output of `callcenter_config queue list members`
+func ParseCallcenterMembers(body []byte) ([]CallcenterMember, error) {
+	rows, err := parseCallcenterList(body, "cid_name")
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]CallcenterMember, 0, len(rows))
+	for _, r := range rows {
+		members = append(members, CallcenterMember{
+			Queue:          r["queue"],
+			InstanceID:     r["instance_id"],
+			UUID:           r["uuid"],
+			SessionUUID:    r["session_uuid"],
+			CIDNumber:      r["cid_number"],
+			CIDName:        r["cid_name"],
+			SystemEpoch:    epochTime(r["system_epoch"]),
+			JoinedEpoch:    epochTime(r["joined_epoch"]),
+			RejoinedEpoch:  epochTime(r["rejoined_epoch"]),
+			BridgeEpoch:    epochTime(r["bridge_epoch"]),
+			AbandonedEpoch: epochTime(r["abandoned_epoch"]),
+			BaseScore:      r.int("base_score"),
+			SkillScore:     r.int("skill_score"),
+			ServingAgent:   r["serving_agent"],
+			ServingSystem:  r["serving_system"],
+			State:          r["state"],
+		})
+	}
+
+	return members, nil
+}
+
+// ParseCallcenterQueues - Will parse output of `callcenter_config queue list`
+func ParseCallcenterQueues(body []byte) ([]CallcenterQueue, error) {
+	rows, err := parseCallcenterList(body, "")
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make([]CallcenterQueue, 0, len(rows))
+	for _, r := range rows {
+		queues = append(queues, CallcenterQueue{
+			Name:                  r["name"],
+			Strategy:              r["strategy"],
+			MOHSound:              r["moh_sound"],
+			TimeBaseScore:         r["time_base_score"],
+			TierRulesApply:        r["tier_rules_apply"] == "true",
+			MaxWaitTime:           r.int("max_wait_time"),
+			MaxWaitTimeNoAgent:    r.int("max_wait_time_with_no_agent"),
+			DiscardAbandonedAfter: r.int("discard_abandoned_after"),
+			RecordTemplate:        r["record_template"],
+			CallsAnswered:         r.int("calls_answered"),
+			CallsAbandoned:        r.int("calls_abandoned"),
+		})
+	}
+
+	return queues, nil
+}
+
+// callcenterConfig - Will send `callcenter_config <args>` and return its response body
+func (sc *SocketConnection) callcenterConfig(args ...string) (string, error) {
+	cmd := []string{"callcenter_config"}
+
+	// mod_callcenter splits args on spaces honouring single quotes, values with spaces (statuses, states) are quoted
+	// for that reason and empty ones still take their place
+	for _, a := range args {
+		if _, err := apiArg(a); err != nil {
+			return "", err
+		}
+
+		if a == "" {
+			cmd = append(cmd, "''")
+			continue
+		}
+
+		a, err := quoteArg(a)
+		if err != nil {
+			return "", err
+		}
+
+		cmd = append(cmd, a)
+	}
+
+	m, err := sc.SendApi(strings.Join(cmd, " "))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(m.Body)), nil
+}
+
+// CallcenterAgentAdd - Will add agent of given type
+func (sc *SocketConnection) CallcenterAgentAdd(agent string, typ AgentType) error {
+	_, err := sc.callcenterConfig("agent", "add", agent, string(typ))
+	return err
+}
+
+// CallcenterAgentDel - Will delete agent
+func (sc *SocketConnection) CallcenterAgentDel(agent string) error {
+	_, err := sc.callcenterConfig("agent", "del", agent)
+	return err
+}
+
+// CallcenterAgentSet - Will set agent attribute (status, state, contact, max_no_answer, wrap_up_time...)
+func (sc *SocketConnection) CallcenterAgentSet(agent string, key string, value string) error {
+	_, err := sc.callcenterConfig("agent", "set", key, agent, value)
+	return err
+}
+
+// CallcenterAgentSetStatus - Will set agent status (e.g. AgentStatusAvailable)
+func (sc *SocketConnection) CallcenterAgentSetStatus(agent string, status AgentStatus) error {
+	return sc.CallcenterAgentSet(agent, "status", string(status))
+}
+
+// CallcenterAgentSetState - Will set agent state (e.g. AgentStateWaiting)
+func (sc *SocketConnection) CallcenterAgentSetState(agent string, state AgentState) error {
+	return sc.CallcenterAgentSet(agent, "state", string(state))
+}
+
+// CallcenterAgentSetContact - Will set dial string agent is reached with (e.g. [leg_timeout=10]user/1000@default)
+func (sc *SocketConnection) CallcenterAgentSetContact(agent string, contact string) error {
+	return sc.CallcenterAgentSet(agent, "contact", contact)
+}
+
+// CallcenterAgentGetStatus - Will return agent status
+func (sc *SocketConnection) CallcenterAgentGetStatus(agent string) (AgentStatus, error) {
+	body, err := sc.callcenterConfig("agent", "get", "status", agent)
+	if err != nil {
+		return "", err
+	}
+
+	return AgentStatus(body), nil
+}
+
+// CallcenterAgents - Will return all of the agents
+func (sc *SocketConnection) CallcenterAgents() ([]CallcenterAgent, error) {
+	body, err := sc.callcenterConfig("agent", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterAgents([]byte(body))
+}
+
+// CallcenterTierAdd - Will assign agent to the queue with given level and position
+func (sc *SocketConnection) CallcenterTierAdd(queue string, agent string, level int, position int) error {
+	_, err := sc.callcenterConfig("tier", "add", queue, agent, strconv.Itoa(level), strconv.Itoa(position))
+	return err
+}
+
+// CallcenterTierDel - Will remove agent from the queue
+func (sc *SocketConnection) CallcenterTierDel(queue string, agent string) error {
+	_, err := sc.callcenterConfig("tier", "del", queue, agent)
+	return err
+}
+
+// CallcenterTierSet - Will set tier attribute (state, level or position)
+func (sc *SocketConnection) CallcenterTierSet(queue string, agent string, key string, value string) error {
+	_, err := sc.callcenterConfig("tier", "set", key, queue, agent, value)
+	return err
+}
+
+// CallcenterTierSetState - Will set state of agent within the queue
+func (sc *SocketConnection) CallcenterTierSetState(queue string, agent string, state TierState) error {
+	return sc.CallcenterTierSet(queue, agent, "state", string(state))
+}
+
+// CallcenterTiers - Will return all of the tiers
+func (sc *SocketConnection) CallcenterTiers() ([]CallcenterTier, error) {
+	body, err := sc.callcenterConfig("tier", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterTiers([]byte(body))
+}
+
+// CallcenterQueueLoad - Will load queue from configuration
+func (sc *SocketConnection) CallcenterQueueLoad(queue string) error {
+	_, err := sc.callcenterConfig("queue", "load", queue)
+	return err
+}
+
+// CallcenterQueueUnload - Will unload queue
+func (sc *SocketConnection) CallcenterQueueUnload(queue string) error {
+	_, err := sc.callcenterConfig("queue", "unload", queue)
+	return err
+}
+
+// CallcenterQueueReload - Will reload queue configuration
+func (sc *SocketConnection) CallcenterQueueReload(queue string) error {
+	_, err := sc.callcenterConfig("queue", "reload", queue)
+	return err
+}
+
+// CallcenterQueues - Will return all of the loaded queues
+func (sc *SocketConnection) CallcenterQueues() ([]CallcenterQueue, error) {
+	body, err := sc.callcenterConfig("queue", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterQueues([]byte(body))
+}
+
+// CallcenterQueueAgents - Will return agents of the queue
+func (sc *SocketConnection) CallcenterQueueAgents(queue string) ([]CallcenterAgent, error) {
+	body, err := sc.callcenterConfig("queue", "list", "agents", queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterAgents([]byte(body))
+}
+
+// CallcenterQueueMembers - Will return callers waiting in or being served by the queue
+func (sc *SocketConnection) CallcenterQueueMembers(queue string) ([]CallcenterMember, error) {
+	body, err := sc.callcenterConfig("queue", "list", "members", queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterMembers([]byte(body))
+}
+
+// CallcenterQueueTiers - Will return tiers of the queue
+func (sc *SocketConnection) CallcenterQueueTiers(queue string) ([]CallcenterTier, error) {
+	body, err := sc.callcenterConfig("queue", "list", "tiers", queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCallcenterTiers([]byte(body))
+}
+
+// CallcenterQueueCount - Will return count of agents, members or tiers of the queue (what is one of those)
+func (sc *SocketConnection) CallcenterQueueCount(queue string, what string) (int, error) {
+	body, err := sc.callcenterConfig("queue", "count", what, queue)
+	if err != nil {
+		return 0, err
+	}
+
+	// Count comes alone or followed by +OK line depending on freeswitch version
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(body, "+OK")))
+	if err != nil {
+		return 0, &ReplyError{Reply: body}
+	}
+
+	return count, nil
+}
+
+// CallcenterEvent - CUSTOM callcenter::info. Action (CC-Action) tells what happened, events of known actions
+// are decoded into concrete types (e.g. *CallcenterAgentStatusEvent).
+type CallcenterEvent struct {
+	*Event
+	Action string
+	Queue  string
+	Agent  string
+}
+
+// CallcenterAgentStatusEvent - agent-status-change
+type CallcenterAgentStatusEvent struct {
+	CallcenterEvent
+	Status AgentStatus
+}
+
+// CallcenterAgentStateEvent - agent-state-change
+type CallcenterAgentStateEvent struct {
+	CallcenterEvent
+	State AgentState
+}
+
+// CallcenterMemberEvent - Fields shared by events about the caller (member) of the queue
+type CallcenterMemberEvent struct {
+	CallcenterEvent
+	MemberUUID        string
+	MemberSessionUUID string
+	CallerIDName      string
+	CallerIDNumber    string
+	JoinedTime        time.Time
+}
+
+// CallcenterMemberQueueStartEvent - member-queue-start
+type CallcenterMemberQueueStartEvent struct {
+	CallcenterMemberEvent
+}
+
+// CallcenterMemberQueueEndEvent - member-queue-end. Cause is Terminated or Cancel, CancelReason tells why
+// caller left (NO_AGENT_TIMEOUT, TIMEOUT, BREAK_OUT...).
+type CallcenterMemberQueueEndEvent struct {
+	CallcenterMemberEvent
+	Cause        string
+	CancelReason string
+	LeavingTime  time.Time
+}
+
+// CallcenterAgentOfferingEvent - agent-offering
+type CallcenterAgentOfferingEvent struct {
+	CallcenterMemberEvent
+	AgentType AgentType
+}
+
+// CallcenterBridgeEvent - bridge-agent-start, bridge-agent-end and bridge-agent-fail. HangupCause is only set on
+// failure, TerminatedTime only at the end of the bridge.
+type CallcenterBridgeEvent struct {
+	CallcenterMemberEvent
+	AgentUUID      string
+	CalledTime     time.Time
+	AnsweredTime   time.Time
+	TerminatedTime time.Time
+	HangupCause    string
+}
+
+// CallcenterMembersCountEvent - members-count
+type CallcenterMembersCountEvent struct {
+	CallcenterEvent
+	Count int
+}
+
+// DecodeCallcenterEvent - Will decode callcenter::info event into one of the concrete callcenter events.
+// Events of unknown actions are returned as *CallcenterEvent.
+func DecodeCallcenterEvent(m *Message) (TypedEvent, error) {
+	e, err := NewEvent(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Subclass != CallcenterInfo {
+		return nil, fmt.Errorf(ENotACallcenterEvent, e.Name, e.Subclass)
+	}
+
+	return newCallcenterEvent(e), nil
+}
+
+func newCallcenterEvent(e *Event) TypedEvent {
+	cc := CallcenterEvent{
+		Event:  e,
+		Action: e.Header("CC-Action"),
+		Queue:  e.Header("CC-Queue"),
+		Agent:  e.Header("CC-Agent"),
+	}
+
+	member := func() CallcenterMemberEvent {
+		return CallcenterMemberEvent{
+			CallcenterEvent:   cc,
+			MemberUUID:        e.Header("CC-Member-UUID"),
+			MemberSessionUUID: e.Header("CC-Member-Session-UUID"),
+			CallerIDName:      e.Header("CC-Member-CID-Name"),
+			CallerIDNumber:    e.Header("CC-Member-CID-Number"),
+			JoinedTime:        epochTime(e.Header("CC-Member-Joined-Time")),
+		}
+	}
+
+	switch cc.Action {
+	case "agent-status-change":
+		return &CallcenterAgentStatusEvent{CallcenterEvent: cc, Status: AgentStatus(e.Header("CC-Agent-Status"))}
+	case "agent-state-change":
+		return &CallcenterAgentStateEvent{CallcenterEvent: cc, State: AgentState(e.Header("CC-Agent-State"))}
+	case "member-queue-start":
+		return &CallcenterMemberQueueStartEvent{member()}
+	case "member-queue-end":
+		return &CallcenterMemberQueueEndEvent{
+			CallcenterMemberEvent: member(),
+			Cause:                 e.Header("CC-Cause"),
+			CancelReason:          e.Header("CC-Cancel-Reason"),
+			LeavingTime:           epochTime(e.Header("CC-Member-Leaving-Time")),
+		}
+	case "agent-offering":
+		return &CallcenterAgentOfferingEvent{CallcenterMemberEvent: member(), AgentType: AgentType(e.Header("CC-Agent-Type"))}
+	case "bridge-agent-start", "bridge-agent-end", "bridge-agent-fail":
+		return &CallcenterBridgeEvent{
+			CallcenterMemberEvent: member(),
+			AgentUUID:             e.Header("CC-Agent-UUID"),
+			CalledTime:            epochTime(e.Header("CC-Agent-Called-Time")),
+			AnsweredTime:          epochTime(e.Header("CC-Agent-Answered-Time")),
+			TerminatedTime:        epochTime(e.Header("CC-Bridge-Terminated-Time")),
+			HangupCause:           e.Header("CC-Hangup-Cause"),
+		}
+	case "members-count":
+		count, _ := strconv.Atoi(e.Header("CC-Count"))
+		return &CallcenterMembersCountEvent{CallcenterEvent: cc, Count: count}
+	}
+
+	return &cc
+}
@@ -0,0 +1,157 @@
+package goesl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	callcenterAgents = "name|instance_id|uuid|type|contact|status|state|max_no_answer|wrap_up_time|reject_delay_time|busy_delay_time|no_answer_delay_time|last_bridge_start|last_bridge_end|last_offered_call|last_status_change|no_answer_count|calls_answered|talk_time|ready_time|external_calls_count\n" +
+		"1000@default|single_box||callback|[leg_timeout=10]user/1000@default|Available (On Demand)|Waiting|3|10|10|60|0|1696367556|1696367616|1696367550|1696360000|1|12|540|0|0\n" +
+		"1001@default|single_box||callback|user/1001@default|sofia/gateway/gw1/1001|Logged Out|Idle|3|10|10|60|0|0|0|0|0|0|0|0|0|0\n" +
+		"+OK\n"
+
+	callcenterTiers = "queue|agent|state|level|position\n" +
+		"support@default|1000@default|Ready|1|1\n" +
+		"support@default|1001@default|Standby|2|1\n" +
+		"+OK\n"
+)
+
+func TestParseCallcenterAgents(t *testing.T) {
+	agents, err := ParseCallcenterAgents([]byte(callcenterAgents))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agents) != 2 {
+		t.Fatalf("Expected 2 agents, got %+v", agents)
+	}
+
+	a := agents[0]
+	if a.Name != "1000@default" || a.Contact != "[leg_timeout=10]user/1000@default" || a.Status != AgentStatusAvailableOnDemand || a.State != AgentStateWaiting {
+		t.Fatalf("Unexpected agent: %+v", a)
+	}
+
+	if a.CallsAnswered != 12 || a.TalkTime != 540 || !a.LastBridgeStart.Equal(time.Unix(1696367556, 0)) {
+		t.Fatalf("Unexpected agent stats: %+v", a)
+	}
+
+	// Failover contact has | of its own
+	if !agents[1].LastBridgeStart.IsZero() || agents[1].Status != AgentStatusLoggedOut || agents[1].Contact != "user/1001@default|sofia/gateway/gw1/1001" {
+		t.Fatalf("Unexpected agent: %+v", agents[1])
+	}
+}
+
+func TestParseCallcenterTiers(t *testing.T) {
+	tiers, err := ParseCallcenterTiers([]byte(callcenterTiers))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tiers) != 2 || tiers[1].Agent != "1001@default" || tiers[1].State != TierStateStandby || tiers[1].Level != 2 {
+		t.Fatalf("Unexpected tiers: %+v", tiers)
+	}
+
+	tiers, err = ParseCallcenterTiers([]byte("+OK\n"))
+	if err != nil || len(tiers) != 0 {
+		t.Fatalf("Expected no tiers, got %+v, %v", tiers, err)
+	}
+}
+
+func TestCallcenterCommands(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		switch cmd {
+		case "callcenter_config agent set status 1000@default 'Available (On Demand)'",
+			"callcenter_config agent set contact 1000@default ''",
+			"callcenter_config tier add support@default 1000@default 1 2":
+			return "+OK\n"
+		case "callcenter_config queue list tiers support@default":
+			return callcenterTiers
+		case "callcenter_config queue count members support@default":
+			return "3\n"
+		}
+		return "-ERR Invalid Agent!\n"
+	})
+
+	go c.Handle()
+
+	if err := c.CallcenterAgentSetStatus("1000@default", AgentStatusAvailableOnDemand); err != nil {
+		t.Fatal(err)
+	}
+	nextAPI(t, sess)
+
+	if err := c.CallcenterAgentSet("1000@default", "contact", ""); err != nil {
+		t.Fatal(err)
+	}
+	nextAPI(t, sess)
+
+	if err := c.CallcenterTierAdd("support@default", "1000@default", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	nextAPI(t, sess)
+
+	tiers, err := c.CallcenterQueueTiers("support@default")
+	if err != nil || len(tiers) != 2 {
+		t.Fatalf("Expected 2 tiers, got %+v, %v", tiers, err)
+	}
+	nextAPI(t, sess)
+
+	count, err := c.CallcenterQueueCount("support@default", "members")
+	if err != nil || count != 3 {
+		t.Fatalf("Expected 3 members, got %d, %v", count, err)
+	}
+	nextAPI(t, sess)
+
+	var re *ReplyError
+	if err := c.CallcenterAgentDel("unknown"); !errors.As(err, &re) || re.Reply != "Invalid Agent!" {
+		t.Fatalf("Expected reply error, got %v", err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "callcenter_config agent del unknown" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if err := c.CallcenterAgentSetContact("1000@default", "user/1000'"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestDecodeCallcenterEvent(t *testing.T) {
+	m := channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: callcenter%3A%3Ainfo\nCC-Action: member-queue-end\nCC-Queue: support@default\n"+
+		"CC-Member-UUID: m1\nCC-Member-Session-UUID: s1\nCC-Member-CID-Number: 1000\nCC-Cause: Cancel\nCC-Cancel-Reason: TIMEOUT\n"+
+		"CC-Member-Joined-Time: 1696367556\nCC-Member-Leaving-Time: 1696367616\n")
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end, ok := te.(*CallcenterMemberQueueEndEvent)
+	if !ok {
+		t.Fatalf("Expected *CallcenterMemberQueueEndEvent, got %T", te)
+	}
+
+	if end.Queue != "support@default" || end.MemberSessionUUID != "s1" || end.CancelReason != "TIMEOUT" {
+		t.Fatalf("Unexpected event: %+v", end)
+	}
+
+	if end.LeavingTime.Sub(end.JoinedTime) != time.Minute {
+		t.Fatalf("Unexpected times: %s, %s", end.JoinedTime, end.LeavingTime)
+	}
+
+	m = channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: callcenter%3A%3Ainfo\nCC-Action: agent-status-change\nCC-Agent: 1000@default\nCC-Agent-Status: On%20Break\n")
+
+	te, err = DecodeCallcenterEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ev, ok := te.(*CallcenterAgentStatusEvent); !ok || ev.Agent != "1000@default" || ev.Status != AgentStatusOnBreak {
+		t.Fatalf("Unexpected event: %+v", te)
+	}
+
+	if _, err := DecodeCallcenterEvent(channelEvent(t, "Event-Name: CHANNEL_CREATE\nUnique-ID: a\n")); err == nil {
+		t.Fatal("Expected error decoding non callcenter event")
+	}
+}
@@ -9,8 +9,8 @@ package goesl
 import (
 	"bufio"
 	"fmt"
-	"io"
 	"net"
+	"net/textproto"
 	"strconv"
 	"time"
 )
@@ -33,11 +33,7 @@ func (c *Client) EstablishConnection() error {
 		return err
 	}
 
-	c.SocketConnection = SocketConnection{
-		Conn: conn,
-		err:  make(chan error),
-		m:    make(chan *Message),
-	}
+	c.SocketConnection = newSocketConnection(conn)
 
 	return nil
 }
@@ -45,13 +41,9 @@ func (c *Client) EstablishConnection() error {
 // Authenticate - Method used to authenticate client against freeswitch. In case of any errors durring so
 // we will return error.
 func (c *Client) Authenticate() error {
-	m, err := NewMessage(bufio.NewReaderSize(c, ReadBufferSize), false)
-	if err != nil {
-		Error(ECouldNotCreateMessage, err)
-		return err
-	}
+	tr := textproto.NewReader(bufio.NewReaderSize(c, ReadBufferSize))
 
-	cmr, err := m.tr.ReadMIMEHeader()
+	cmr, err := tr.ReadMIMEHeader()
 	if err != nil && err.Error() != "EOF" {
 		Error(ECouldNotReadMIMEHeaders, err)
 		return err
@@ -64,13 +56,17 @@ func (c *Client) Authenticate() error {
 		return fmt.Errorf(EUnexpectedAuthHeader, cmr.Get("Content-Type"))
 	}
 
-	s := "auth " + c.Passwd + "\r\n\r\n"
-	_, err = io.WriteString(c, s)
+	// Reply is read right here, Handle() is not running yet
+	b, err := encodeCommand("auth "+c.Passwd, nil)
 	if err != nil {
 		return err
 	}
 
-	am, err := m.tr.ReadMIMEHeader()
+	if err := c.write(b); err != nil {
+		return err
+	}
+
+	am, err := tr.ReadMIMEHeader()
 	if err != nil && err.Error() != "EOF" {
 		Error(ECouldNotReadMIMEHeaders, err)
 		return err
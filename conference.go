@@ -0,0 +1,583 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Member selectors conference commands accept in place of member id
+const (
+	MemberAll          = "all"
+	MemberLast         = "last"
+	MemberNonModerator = "non_moderator"
+)
+
+// ConferenceMaintenance - Subclass of CUSTOM events mod_conference reports conference changes with
+const ConferenceMaintenance = "conference::maintenance"
+
+// MemberID - Will turn member id into selector conference commands accept
+func MemberID(id int) string {
+	return strconv.Itoa(id)
+}
+
+// ConferenceEvent - CUSTOM conference::maintenance. Action tells what happened (add-member, del-member,
+// start-talking, lock, floor-change...). MemberID is 0 for actions concerning whole conference.
+type ConferenceEvent struct {
+	*Event
+	Conference     string
+	ConferenceUUID string
+	Action         string
+	MemberID       int
+	MemberType     string
+}
+
+func newConferenceEvent(e *Event) *ConferenceEvent {
+	id, _ := strconv.Atoi(e.Header("Member-ID"))
+
+	return &ConferenceEvent{
+		Event:          e,
+		Conference:     e.Header("Conference-Name"),
+		ConferenceUUID: e.Header("Conference-Unique-ID"),
+		Action:         e.Header("Action"),
+		MemberID:       id,
+		MemberType:     e.Header("Member-Type"),
+	}
+}
+
+// ConferenceMember - Single member as reported by `conference <name> list`
+type ConferenceMember struct {
+	ID             int
+	Channel        string
+	UUID           string
+	CallerIDName   string
+	CallerIDNumber string
+	Flags          []string
+	VolumeIn       int
+	AGCVolumeIn    int
+	VolumeOut      int
+	Energy         int
+}
+
+// Has - Will check if member has flag set (hear, speak, talking, floor, moderator...)
+func (m ConferenceMember) Has(flag string) bool {
+	for _, f := range m.Flags {
+		if f == flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseConferenceList - Will parse output of `conference <name> list`. Lines that are not members are skipped.
+// Older freeswitch ends the line with volume_in;volume_out;energy (9 fields), newer one reports AGC volume in level
+// too (volume_in;agc_volume_in;volume_out;energy, 10 fields). Caller id name is the only field freeswitch does not
+// control, so it can contain ; too. Layout is told by field count and, where a ; in the name makes that ambiguous, by
+// whether the field flags would be at in older layout is a number.
+func ParseConferenceList(body []byte) []ConferenceMember {
+	members := []ConferenceMember{}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), ";")
+		if len(fields) < 9 {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		n := len(fields)
+
+		// Numeric fields at the end of the line
+		tail := 3
+		if n >= 10 {
+			if _, err := strconv.Atoi(fields[n-4]); err == nil {
+				tail = 4
+			}
+		}
+
+		end := n - tail - 2
+
+		m := ConferenceMember{
+			ID:             id,
+			Channel:        fields[1],
+			UUID:           fields[2],
+			CallerIDName:   strings.Join(fields[3:end], ";"),
+			CallerIDNumber: fields[end],
+			Flags:          strings.Split(fields[end+1], "|"),
+		}
+
+		if fields[end+1] == "" {
+			m.Flags = nil
+		}
+
+		m.VolumeIn, _ = strconv.Atoi(fields[n-tail])
+		if tail == 4 {
+			m.AGCVolumeIn, _ = strconv.Atoi(fields[n-3])
+		}
+		m.VolumeOut, _ = strconv.Atoi(fields[n-2])
+		m.Energy, _ = strconv.Atoi(fields[n-1])
+
+		members = append(members, m)
+	}
+
+	return members
+}
+
+// conferenceApi - Will send `conference <name> <command> ...` and return its response body. Unknown conference or
+// member is returned as *ReplyError matching ErrNoSuchConference or ErrNoSuchMember.
+func (sc *SocketConnection) conferenceApi(name string, command string, words []string, rest ...string) (string, error) {
+	cmd, err := apiCommand("conference", append([]string{name, command}, words...), rest...)
+	if err != nil {
+		return "", err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	body := strings.TrimSpace(string(m.Body))
+	reply := &ReplyError{Reply: body}
+
+	if strings.HasPrefix(body, "-USAGE") || errors.Is(reply, ErrNoSuchConference) || errors.Is(reply, ErrNoSuchMember) {
+		return "", reply
+	}
+
+	return body, nil
+}
+
+// ConferenceList - Will return members of the conference
+func (sc *SocketConnection) ConferenceList(name string) ([]ConferenceMember, error) {
+	body, err := sc.conferenceApi(name, "list", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConferenceList([]byte(body)), nil
+}
+
+// ConferenceMute - Will mute member(s). Member is member id (see MemberID) or one of Member* selectors.
+func (sc *SocketConnection) ConferenceMute(name string, member string) error {
+	_, err := sc.conferenceApi(name, "mute", []string{member})
+	return err
+}
+
+// ConferenceUnmute - Will unmute member(s)
+func (sc *SocketConnection) ConferenceUnmute(name string, member string) error {
+	_, err := sc.conferenceApi(name, "unmute", []string{member})
+	return err
+}
+
+// ConferenceDeaf - Will make member(s) unable to hear the conference
+func (sc *SocketConnection) ConferenceDeaf(name string, member string) error {
+	_, err := sc.conferenceApi(name, "deaf", []string{member})
+	return err
+}
+
+// ConferenceUndeaf - Will make member(s) able to hear the conference again
+func (sc *SocketConnection) ConferenceUndeaf(name string, member string) error {
+	_, err := sc.conferenceApi(name, "undeaf", []string{member})
+	return err
+}
+
+// ConferenceKick - Will kick member(s) out of the conference playing kick sound
+func (sc *SocketConnection) ConferenceKick(name string, member string) error {
+	_, err := sc.conferenceApi(name, "kick", []string{member})
+	return err
+}
+
+// ConferenceHup - Will kick member(s) out of the conference without playing kick sound
+func (sc *SocketConnection) ConferenceHup(name string, member string) error {
+	_, err := sc.conferenceApi(name, "hup", []string{member})
+	return err
+}
+
+// ConferenceLock - Will prevent anyone else from joining the conference
+func (sc *SocketConnection) ConferenceLock(name string) error {
+	_, err := sc.conferenceApi(name, "lock", nil)
+	return err
+}
+
+// ConferenceUnlock - Will allow members to join the conference again
+func (sc *SocketConnection) ConferenceUnlock(name string) error {
+	_, err := sc.conferenceApi(name, "unlock", nil)
+	return err
+}
+
+// ConferenceRecord - Will start recording the conference into path
+func (sc *SocketConnection) ConferenceRecord(name string, path string) error {
+	path, err := quoteArg(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = sc.conferenceApi(name, "record", nil, path)
+	return err
+}
+
+// ConferenceStopRecord - Will stop recording into path. Pass MemberAll to stop all of the recordings.
+func (sc *SocketConnection) ConferenceStopRecord(name string, path string) error {
+	path, err := quoteArg(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = sc.conferenceApi(name, "norecord", nil, path)
+	return err
+}
+
+// ConferencePlay - Will play file into the conference or, if member is not empty, to the member only
+func (sc *SocketConnection) ConferencePlay(name string, file string, member string) error {
+	file, err := quoteArg(file)
+	if err != nil {
+		return err
+	}
+
+	if member != "" {
+		if _, err := apiWord(member); err != nil {
+			return err
+		}
+	}
+
+	_, err = sc.conferenceApi(name, "play", nil, file, member)
+	return err
+}
+
+// ConferenceVolumeIn - Will set input volume of member(s). Level goes from -4 to 4, 0 being unchanged.
+func (sc *SocketConnection) ConferenceVolumeIn(name string, member string, level int) error {
+	_, err := sc.conferenceApi(name, "volume_in", []string{member, strconv.Itoa(level)})
+	return err
+}
+
+// ConferenceEnergy - Will set energy level member(s) must reach for their audio to be mixed into the conference
+func (sc *SocketConnection) ConferenceEnergy(name string, member string, level int) error {
+	_, err := sc.conferenceApi(name, "energy", []string{member, strconv.Itoa(level)})
+	return err
+}
+
+// TrackedMember - What ConferenceTracker knows about conference member
+type TrackedMember struct {
+	ID             int
+	UUID           string
+	CallerIDName   string
+	CallerIDNumber string
+	Moderator      bool
+	Hear           bool
+	Speak          bool
+	Talking        bool
+	Floor          bool
+	Joined         time.Time
+}
+
+// TrackedConference - What ConferenceTracker knows about conference. Members are ordered by member id.
+// Floor is id of the member holding the floor, 0 if nobody does.
+type TrackedConference struct {
+	Name    string
+	UUID    string
+	Locked  bool
+	Floor   int
+	Members []TrackedMember
+}
+
+type trackedConference struct {
+	name    string
+	uuid    string
+	locked  bool
+	floor   int
+	members map[int]*TrackedMember
+}
+
+// copy - Will return copy of the conference safe to be handed out of tracker lock
+func (c *trackedConference) copy() TrackedConference {
+	conf := TrackedConference{
+		Name:    c.name,
+		UUID:    c.uuid,
+		Locked:  c.locked,
+		Floor:   c.floor,
+		Members: make([]TrackedMember, 0, len(c.members)),
+	}
+
+	for _, m := range c.members {
+		conf.Members = append(conf.Members, *m)
+	}
+
+	sort.Slice(conf.Members, func(i, j int) bool {
+		return conf.Members[i].ID < conf.Members[j].ID
+	})
+
+	return conf
+}
+
+// ConferenceTracker - Keeps in-memory view of conferences and their members out of conference::maintenance events.
+// Attach it to a connection subscribed to CUSTOM conference::maintenance events.
+type ConferenceTracker struct {
+	mtx         sync.RWMutex
+	conferences map[string]*trackedConference
+
+	sub *Subscription
+}
+
+// NewConferenceTracker - Will create empty conference tracker
+func NewConferenceTracker() *ConferenceTracker {
+	return &ConferenceTracker{conferences: make(map[string]*trackedConference)}
+}
+
+// Attach - Will register tracker as handler of conference::maintenance events on the connection. Keep in mind that
+// events handled by tracker are no longer returned by ReadMsg, register your own handlers for them if needed.
+func (t *ConferenceTracker) Attach(c *SocketConnection) {
+	t.sub = c.OnCustom(ConferenceMaintenance, t.Apply)
+}
+
+// Detach - Will stop tracking events of the connection tracker got attached to
+func (t *ConferenceTracker) Detach() {
+	if t.sub != nil {
+		t.sub.Unregister()
+		t.sub = nil
+	}
+}
+
+// Sync - Will replace tracked members of the conference with what `conference <name> list` reports. Conference
+// is forgotten in case freeswitch no longer has it.
+func (t *ConferenceTracker) Sync(c *SocketConnection, name string) error {
+	members, err := c.ConferenceList(name)
+	if errors.Is(err, ErrNoSuchConference) {
+		t.mtx.Lock()
+		delete(t.conferences, name)
+		t.mtx.Unlock()
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	conf := t.conference(name)
+	old := conf.members
+	conf.members = make(map[int]*TrackedMember, len(members))
+	conf.floor = 0
+
+	for _, m := range members {
+		tm := &TrackedMember{
+			ID:             m.ID,
+			UUID:           m.UUID,
+			CallerIDName:   m.CallerIDName,
+			CallerIDNumber: m.CallerIDNumber,
+			Moderator:      m.Has("moderator"),
+			Hear:           m.Has("hear"),
+			Speak:          m.Has("speak"),
+			Talking:        m.Has("talking"),
+			Floor:          m.Has("floor"),
+		}
+
+		if o, ok := old[m.ID]; ok {
+			tm.Joined = o.Joined
+		}
+
+		if tm.Floor {
+			conf.floor = m.ID
+		}
+
+		conf.members[m.ID] = tm
+	}
+
+	return nil
+}
+
+// conference - Will return tracked conference creating it if needed. Must be called with lock held.
+func (t *ConferenceTracker) conference(name string) *trackedConference {
+	conf, ok := t.conferences[name]
+	if !ok {
+		conf = &trackedConference{name: name, members: make(map[int]*TrackedMember)}
+		t.conferences[name] = conf
+	}
+
+	return conf
+}
+
+// Apply - Will update tracked conferences with the conference::maintenance event. Called for you once tracker is
+// attached to connection, but can be used directly in case you're reading events with ReadMsg.
+func (t *ConferenceTracker) Apply(msg *Message) {
+	e, err := NewEvent(msg)
+	if err != nil || e.Subclass != ConferenceMaintenance {
+		return
+	}
+
+	name := e.Header("Conference-Name")
+	if name == "" {
+		return
+	}
+
+	action := e.Header("Action")
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if action == "conference-destroy" {
+		delete(t.conferences, name)
+		return
+	}
+
+	conf := t.conference(name)
+
+	if uuid := e.Header("Conference-Unique-ID"); uuid != "" {
+		conf.uuid = uuid
+	}
+
+	switch action {
+	case "lock":
+		conf.locked = true
+		return
+	case "unlock":
+		conf.locked = false
+		return
+	case "floor-change":
+		t.floorChange(conf, e)
+		return
+	}
+
+	id, err := strconv.Atoi(e.Header("Member-ID"))
+	if err != nil {
+		return
+	}
+
+	if action == "del-member" {
+		delete(conf.members, id)
+
+		if conf.floor == id {
+			conf.floor = 0
+		}
+
+		return
+	}
+
+	m, ok := conf.members[id]
+	if !ok {
+		m = &TrackedMember{ID: id, Joined: e.Timestamp}
+		conf.members[id] = m
+	}
+
+	t.update(m, e)
+
+	switch action {
+	case "start-talking":
+		m.Talking = true
+	case "stop-talking":
+		m.Talking = false
+	case "mute-member":
+		m.Speak = false
+	case "unmute-member":
+		m.Speak = true
+	case "deaf-member":
+		m.Hear = false
+	case "undeaf-member":
+		m.Hear = true
+	}
+
+	if m.Floor {
+		conf.floor = id
+	}
+}
+
+// update - Will copy member fields found in every member event onto tracked member
+func (t *ConferenceTracker) update(m *TrackedMember, e *Event) {
+	flag := func(field *bool, header string) {
+		if v := e.Header(header); v != "" {
+			*field = v == "true"
+		}
+	}
+
+	if v := e.Header("Unique-ID"); v != "" {
+		m.UUID = v
+	}
+
+	if v := e.Header("Caller-Caller-ID-Name"); v != "" {
+		m.CallerIDName = v
+	}
+
+	if v := e.Header("Caller-Caller-ID-Number"); v != "" {
+		m.CallerIDNumber = v
+	}
+
+	if v := e.Header("Member-Type"); v != "" {
+		m.Moderator = v == "moderator"
+	}
+
+	flag(&m.Hear, "Hear")
+	flag(&m.Speak, "Speak")
+	flag(&m.Talking, "Talking")
+	flag(&m.Floor, "Floor")
+}
+
+// floorChange - Will move the floor from Old-ID to New-ID member. Either of them is "none" when nobody had or
+// got the floor. Must be called with lock held.
+func (t *ConferenceTracker) floorChange(conf *trackedConference, e *Event) {
+	if old, err := strconv.Atoi(e.Header("Old-ID")); err == nil {
+		if m, ok := conf.members[old]; ok {
+			m.Floor = false
+		}
+	}
+
+	conf.floor = 0
+
+	if id, err := strconv.Atoi(e.Header("New-ID")); err == nil {
+		conf.floor = id
+
+		if m, ok := conf.members[id]; ok {
+			m.Floor = true
+		}
+	}
+}
+
+// Get - Will return copy of tracked conference
+func (t *ConferenceTracker) Get(name string) (TrackedConference, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	conf, ok := t.conferences[name]
+	if !ok {
+		return TrackedConference{}, false
+	}
+
+	return conf.copy(), true
+}
+
+// List - Will return copies of all tracked conferences ordered by name
+func (t *ConferenceTracker) List() []TrackedConference {
+	t.mtx.RLock()
+	list := make([]TrackedConference, 0, len(t.conferences))
+
+	for _, conf := range t.conferences {
+		list = append(list, conf.copy())
+	}
+	t.mtx.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// Len - Will return number of tracked conferences
+func (t *ConferenceTracker) Len() int {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return len(t.conferences)
+}
@@ -0,0 +1,201 @@
+package goesl
+
+import (
+	"errors"
+	"testing"
+)
+
+var conferenceList = "1;sofia/internal/1000@192.168.0.1;0dd4e4f7-36ed-a04d-a8f7-7aebb683af50;John Doe;1000;hear|speak|talking|floor|moderator;0;0;300\n" +
+	"2;sofia/internal/1001@192.168.0.1;6c1ef0b4-8f9b-4a6e-a3e5-6d0f8b0bd3d3;Doe; Jane;1001;hear;-1;2;100\n"
+
+func TestParseConferenceList(t *testing.T) {
+	members := ParseConferenceList([]byte(conferenceList))
+	if len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %+v", members)
+	}
+
+	m := members[0]
+	if m.ID != 1 || m.UUID != "0dd4e4f7-36ed-a04d-a8f7-7aebb683af50" || m.CallerIDName != "John Doe" || m.Energy != 300 {
+		t.Fatalf("Unexpected member: %+v", m)
+	}
+
+	if !m.Has("floor") || !m.Has("moderator") || members[1].Has("speak") {
+		t.Fatalf("Unexpected flags: %v, %v", m.Flags, members[1].Flags)
+	}
+
+	if members[1].CallerIDName != "Doe; Jane" || members[1].CallerIDNumber != "1001" || members[1].VolumeIn != -1 || members[1].VolumeOut != 2 {
+		t.Fatalf("Unexpected volumes: %+v", members[1])
+	}
+}
+
+func TestParseConferenceListAGC(t *testing.T) {
+	// Newer freeswitch reports AGC volume in level in between volume in and volume out
+	members := ParseConferenceList(fixture(t, "conference_list.txt"))
+	if len(members) != 3 {
+		t.Fatalf("Expected 3 members, got %+v", members)
+	}
+
+	m := members[0]
+	if m.ID != 3 || m.CallerIDName != "Alice" || m.CallerIDNumber != "1000" || !m.Has("moderator") || m.Energy != 300 {
+		t.Fatalf("Unexpected member: %+v", m)
+	}
+
+	m = members[1]
+	if m.CallerIDName != "Smith; Bob" || m.CallerIDNumber != "1001" || len(m.Flags) != 1 || !m.Has("hear") {
+		t.Fatalf("Unexpected member: %+v", m)
+	}
+
+	if m.VolumeIn != -2 || m.AGCVolumeIn != 1 || m.VolumeOut != 1 || m.Energy != 100 {
+		t.Fatalf("Unexpected volumes: %+v", m)
+	}
+
+	if m := members[2]; m.CallerIDName != "Outbound Call" || len(m.Flags) != 0 || m.Energy != 100 {
+		t.Fatalf("Unexpected member without flags: %+v", m)
+	}
+}
+
+func TestConferenceCommands(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		switch cmd {
+		case "conference 3000 list":
+			return conferenceList
+		case "conference 3000 mute 2":
+			return "OK mute 2\n"
+		case "conference 3000 mute 7":
+			return "Non-Existant ID 7\n"
+		case "conference 3000 volume_in all 2":
+			return "Volume IN 1 = 2\n"
+		case "conference 3000 play '/tmp/hello world.wav' 2":
+			return "+OK (play) Playing file /tmp/hello world.wav to member 2\n"
+		case "conference 3000 record '/tmp/my recording.wav'":
+			return "Record file /tmp/my recording.wav\n"
+		}
+		return "Conference 4000 not found\n"
+	})
+
+	go c.Handle()
+
+	members, err := c.ConferenceList("3000")
+	if err != nil || len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %+v, %v", members, err)
+	}
+	nextAPI(t, sess)
+
+	if err := c.ConferenceMute("3000", MemberID(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "conference 3000 mute 2" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if err := c.ConferenceMute("3000", MemberID(7)); !errors.Is(err, ErrNoSuchMember) {
+		t.Fatalf("Expected ErrNoSuchMember, got %v", err)
+	}
+	nextAPI(t, sess)
+
+	if err := c.ConferenceVolumeIn("3000", MemberAll, 2); err != nil {
+		t.Fatal(err)
+	}
+	nextAPI(t, sess)
+
+	if err := c.ConferenceLock("4000"); !errors.Is(err, ErrNoSuchConference) {
+		t.Fatalf("Expected ErrNoSuchConference, got %v", err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "conference 4000 lock" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	// Paths with spaces are quoted so that they stay in one piece
+	if err := c.ConferencePlay("3000", "/tmp/hello world.wav", MemberID(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "conference 3000 play '/tmp/hello world.wav' 2" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if err := c.ConferenceRecord("3000", "/tmp/my recording.wav"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "conference 3000 record '/tmp/my recording.wav'" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if err := c.ConferencePlay("3000", "/tmp/it's.wav", ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func maintenanceEvent(t *testing.T, headers string) *Message {
+	return channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: conference%3A%3Amaintenance\nConference-Name: 3000\nConference-Unique-ID: c0nf\n"+headers)
+}
+
+func TestConferenceTrackerApply(t *testing.T) {
+	tracker := NewConferenceTracker()
+
+	tracker.Apply(maintenanceEvent(t, "Action: conference-create\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: add-member\nMember-ID: 1\nUnique-ID: a\nCaller-Caller-ID-Number: 1000\nMember-Type: moderator\nHear: true\nSpeak: true\nTalking: false\nFloor: true\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: add-member\nMember-ID: 2\nUnique-ID: b\nCaller-Caller-ID-Number: 1001\nMember-Type: member\nHear: true\nSpeak: true\nTalking: false\nFloor: false\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: start-talking\nMember-ID: 2\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: floor-change\nOld-ID: 1\nNew-ID: 2\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: mute-member\nMember-ID: 1\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: lock\n"))
+
+	conf, ok := tracker.Get("3000")
+	if !ok || conf.UUID != "c0nf" || !conf.Locked || conf.Floor != 2 || len(conf.Members) != 2 {
+		t.Fatalf("Unexpected conference: %+v", conf)
+	}
+
+	a, b := conf.Members[0], conf.Members[1]
+	if a.ID != 1 || !a.Moderator || a.Speak || a.Floor || a.CallerIDNumber != "1000" {
+		t.Fatalf("Unexpected member: %+v", a)
+	}
+
+	if b.ID != 2 || b.Moderator || !b.Talking || !b.Floor || b.UUID != "b" {
+		t.Fatalf("Unexpected member: %+v", b)
+	}
+
+	tracker.Apply(maintenanceEvent(t, "Action: stop-talking\nMember-ID: 2\n"))
+	tracker.Apply(maintenanceEvent(t, "Action: del-member\nMember-ID: 2\n"))
+
+	conf, _ = tracker.Get("3000")
+	if len(conf.Members) != 1 || conf.Floor != 0 {
+		t.Fatalf("Expected member 2 to be gone along with the floor, got %+v", conf)
+	}
+
+	tracker.Apply(maintenanceEvent(t, "Action: conference-destroy\n"))
+
+	if tracker.Len() != 0 {
+		t.Fatalf("Expected conference to be gone, got %+v", tracker.List())
+	}
+}
+
+func TestConferenceTrackerSync(t *testing.T) {
+	c, _ := apiClient(t, func(cmd string) string {
+		if cmd == "conference 3000 list" {
+			return conferenceList
+		}
+		return "Conference 4000 not found\n"
+	})
+
+	go c.Handle()
+
+	tracker := NewConferenceTracker()
+	tracker.Apply(channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: conference%3A%3Amaintenance\nConference-Name: 4000\nAction: add-member\nMember-ID: 9\n"))
+
+	if err := tracker.Sync(&c.SocketConnection, "3000"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tracker.Sync(&c.SocketConnection, "4000"); err != nil {
+		t.Fatal(err)
+	}
+
+	list := tracker.List()
+	if len(list) != 1 || list[0].Name != "3000" || len(list[0].Members) != 2 || list[0].Floor != 1 {
+		t.Fatalf("Unexpected conferences: %+v", list)
+	}
+}
@@ -8,10 +8,9 @@ package goesl
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"strconv"
 	"strings"
@@ -28,35 +27,66 @@ type SocketConnection struct {
 	err                  chan error
 	m                    chan *Message
 	mtx                  *sync.RWMutex
+	w                    *waiters
+	d                    *dispatcher
+	q                    *eventQueue
+	p                    *pipeline
+	limits               MessageLimits
+	done                 chan struct{}
+	closeOnce            *sync.Once
 }
 
-// Dial - Will establish timedout dial against specified address. In this case, it will be freeswitch server
-func (c *SocketConnection) Dial(network string, addr string, timeout time.Duration) (net.Conn, error) {
-	return net.DialTimeout(network, addr, timeout)
+// waiter - Command waiting for specific events (e.g. CHANNEL_EXECUTE_COMPLETE) to be read from the connection
+type waiter struct {
+	match func(*Message) bool
+	ch    chan *Message
 }
 
-// Send - Will send raw message to open net connection
-func (c *SocketConnection) Send(cmd string) error {
+// waiters - All of the waiters registered against connection
+type waiters struct {
+	sync.Mutex
+	waiting map[*waiter]struct{}
+}
 
-	if strings.Contains(cmd, "\r\n") {
-		return fmt.Errorf(EInvalidCommandProvided, cmd)
+// newSocketConnection - Will wrap net connection into SocketConnection with all of its internals initialized
+func newSocketConnection(conn net.Conn) SocketConnection {
+	return SocketConnection{
+		Conn:      conn,
+		err:       make(chan error),
+		m:         make(chan *Message),
+		mtx:       &sync.RWMutex{},
+		w:         &waiters{waiting: make(map[*waiter]struct{})},
+		d:         newDispatcher(),
+		q:         newEventQueue(EventQueueOptions{}),
+		p:         newPipeline(),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
 	}
+}
 
-	// lock mutex
-	c.mtx.Lock()
-	defer c.mtx.Unlock()
+// Dial - Will establish timedout dial against specified address. In this case, it will be freeswitch server
+func (c *SocketConnection) Dial(network string, addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, addr, timeout)
+}
 
-	_, err := io.WriteString(c, cmd)
+// Send - Will send raw message to open net connection. Its reply is read with ReadMsg.
+func (c *SocketConnection) Send(cmd string) error {
+	b, err := encodeCommand(cmd, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.WriteString(c, "\r\n\r\n")
-	if err != nil {
-		return err
-	}
+	return c.send(b, &pendingReply{})
+}
 
-	return nil
+// write - Will write encoded command to the connection without waiting on its reply (e.g. auth, read before
+// Handle() is running). Mutex keeps commands sent from different goroutines from conflicting.
+func (c *SocketConnection) write(b []byte) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	_, err := c.Write(b)
+	return err
 }
 
 // SendMany - Will loop against passed commands and return 1st error if error happens
@@ -71,122 +101,286 @@ func (c *SocketConnection) SendMany(cmds []string) error {
 	return nil
 }
 
-// SendEvent - Will loop against passed event headers
-// If you don't need a event body, pass in empty string ""
-func (c *SocketConnection) SendEvent(eventName string, eventHeaders []string, eventBody string) error {
-	if len(eventHeaders) <= 0 {
-		return fmt.Errorf(ECouldNotSendEvent, len(eventHeaders))
+// OutgoingEvent - Event fired into freeswitch with sendevent (NOTIFY, SEND_MESSAGE, MESSAGE_WAITING, CUSTOM...).
+// Subclass is required for and only allowed with CUSTOM events, Name defaults to CUSTOM when only Subclass is set.
+// Content-Length is computed out of Body.
+type OutgoingEvent struct {
+	Name     string
+	Subclass string
+	Headers  map[string]string
+	Body     string
+}
+
+// message - Will validate event and build message out of it. Headers are written in sorted order.
+func (e OutgoingEvent) message() (string, *Message, error) {
+	name := e.Name
+	if name == "" && e.Subclass != "" {
+		name = "CUSTOM"
 	}
 
-	// lock mutex to prevent event headers from conflicting
-	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	if _, err := apiWord(name); err != nil {
+		return "", nil, err
+	}
 
-	_, err := io.WriteString(c, "sendevent "+eventName)
-	if err != nil {
-		return err
+	if (name == "CUSTOM") != (e.Subclass != "") {
+		return "", nil, fmt.Errorf("%w: subclass is required for and only allowed with CUSTOM events", ErrInvalidArgument)
 	}
 
-	for _, eventHeader := range eventHeaders {
-		_, err := io.WriteString(c, eventHeader)
-		if err != nil {
-			return err
+	m := &Message{Headers: make(map[string]string, len(e.Headers)+1), Body: []byte(e.Body)}
+
+	for k, v := range e.Headers {
+		// Subclass given in the event itself wins over the one in headers
+		if e.Subclass == "" || !strings.EqualFold(k, "Event-Subclass") {
+			m.Headers[k] = v
 		}
+	}
 
-		_, err = io.WriteString(c, "\r\n")
-		if err != nil {
-			return err
+	if e.Subclass != "" {
+		if _, err := apiWord(e.Subclass); err != nil {
+			return "", nil, err
 		}
 
+		m.Headers["Event-Subclass"] = e.Subclass
 	}
 
-	_, err = io.WriteString(c, "\r\n")
-	if err != nil {
-		return err
-	}
+	return "sendevent " + name, m, nil
+}
 
-	if eventBody != "" {
-		_, err := io.WriteString(c, eventBody)
-		if err != nil {
-			return err
-		}
+// SendEvent - Will fire event into freeswitch and wait for its command/reply (+OK <event uuid>). In case freeswitch
+// replies with -ERR, *ReplyError is returned. Handle() must be running for reply to arrive.
+func (c *SocketConnection) SendEvent(e OutgoingEvent) (m *Message, err error) {
+	return c.SendEventContext(context.Background(), e)
+}
 
-		_, err = io.WriteString(c, "\r\n")
-		if err != nil {
-			return err
-		}
+// SendEventContext - Same as SendEvent but gives up waiting for reply once ctx is done
+func (c *SocketConnection) SendEventContext(ctx context.Context, e OutgoingEvent) (m *Message, err error) {
+	line, msg, err := e.message()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return c.request(ctx, line, msg)
 }
 
 // Execute - Helper fuck to execute commands with its args and sync/async mode
 func (c *SocketConnection) Execute(command, args string, sync bool) (m *Message, err error) {
-	return c.SendMsg(map[string]string{
-		"call-command":     "execute",
-		"execute-app-name": command,
-		"execute-app-arg":  args,
-		"event-lock":       strconv.FormatBool(sync),
-	}, "", "")
+	return c.ExecuteUUID("", command, args, sync)
 }
 
 // ExecuteUUID - Helper fuck to execute uuid specific commands with its args and sync/async mode
+// Args longer than ExecuteArgBodyLength are sent as text/plain body instead of execute-app-arg header.
 func (c *SocketConnection) ExecuteUUID(uuid string, command string, args string, sync bool) (m *Message, err error) {
-	return c.SendMsg(map[string]string{
+	return c.executeUUID(context.Background(), uuid, command, args, map[string]string{
+		"event-lock": strconv.FormatBool(sync),
+	})
+}
+
+// executeUUID - Will send execute sendmsg with given extra headers (event-lock, event-uuid...). Whatever executes
+// an application goes through here so that app name is validated and long args are sent as body the same way.
+func (c *SocketConnection) executeUUID(ctx context.Context, uuid, command, args string, headers map[string]string) (*Message, error) {
+	if command == "" || strings.ContainsAny(command, " \t"+unsafeChars) {
+		return nil, &InjectionError{Field: FieldAppName, Value: command}
+	}
+
+	if err := validateValue(FieldAppArg, args); err != nil {
+		return nil, err
+	}
+
+	msg := map[string]string{
 		"call-command":     "execute",
 		"execute-app-name": command,
-		"execute-app-arg":  args,
-		"event-lock":       strconv.FormatBool(sync),
-	}, uuid, "")
+	}
+
+	for k, v := range headers {
+		msg[k] = v
+	}
+
+	if len(args) > ExecuteArgBodyLength {
+		msg["content-type"] = "text/plain"
+		return c.SendMsgContext(ctx, msg, uuid, args)
+	}
+
+	msg["execute-app-arg"] = args
+
+	return c.SendMsgContext(ctx, msg, uuid, "")
 }
 
-// SendMsg - Basically this func will send message to the opened connection
-func (c *SocketConnection) SendMsg(msg map[string]string, uuid, data string) (m *Message, err error) {
-	b := bytes.NewBufferString("sendmsg")
+// ExecuteResult - Outcome of application executed with ExecuteWait, taken from its CHANNEL_EXECUTE_COMPLETE event
+type ExecuteResult struct {
+	Application string
+	Response    string
+	Variables   map[string]string
+	Event       *Message
+}
 
-	if uuid != "" {
-		if strings.Contains(uuid, "\r\n") {
-			return nil, fmt.Errorf(EInvalidCommandProvided, msg)
+// ExecuteWait - Will execute application and block until freeswitch reports it finished (CHANNEL_EXECUTE_COMPLETE).
+// Connection must be subscribed to channel events (e.g. myevents) and Handle() must be running.
+// In case channel hangs up before application completes ErrChannelHangup is returned.
+func (c *SocketConnection) ExecuteWait(ctx context.Context, command, args string) (*ExecuteResult, error) {
+	return c.ExecuteUUIDWait(ctx, "", command, args)
+}
+
+// ExecuteUUIDWait - Same as ExecuteWait but against specific channel uuid
+func (c *SocketConnection) ExecuteUUIDWait(ctx context.Context, uuid string, command string, args string) (*ExecuteResult, error) {
+	appUUID := newUUID()
+
+	w := c.addWaiter(func(msg *Message) bool {
+		switch msg.header("Event-Name") {
+		case "CHANNEL_EXECUTE_COMPLETE":
+			return msg.header("Application-UUID") == appUUID
+		case "CHANNEL_HANGUP", "CHANNEL_HANGUP_COMPLETE":
+			return uuid == "" || msg.header("Unique-ID") == uuid
 		}
 
-		b.WriteString(" " + uuid)
+		return msg.header("Content-Type") == "text/disconnect-notice"
+	})
+	defer c.removeWaiter(w)
+
+	if _, err := c.executeUUID(ctx, uuid, command, args, map[string]string{
+		"event-lock": "true",
+		"event-uuid": appUUID,
+	}); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.waitFor(ctx, w)
+	if err != nil {
+		return nil, err
 	}
 
-	b.WriteString("\n")
+	if msg.GetHeader("Event-Name") != "CHANNEL_EXECUTE_COMPLETE" {
+		return nil, ErrChannelHangup
+	}
 
-	for k, v := range msg {
-		if strings.Contains(k, "\r\n") {
-			return nil, fmt.Errorf(EInvalidCommandProvided, msg)
+	return &ExecuteResult{
+		Application: msg.GetHeader("Application"),
+		Response:    msg.GetHeader("Application-Response"),
+		Variables:   channelVariables(msg),
+		Event:       msg,
+	}, nil
+}
+
+// channelVariables - Will return variable_* headers of the event with variable_ prefix stripped
+func channelVariables(msg *Message) map[string]string {
+	vars := make(map[string]string)
+
+	for k, v := range msg.Headers {
+		if strings.HasPrefix(k, "variable_") {
+			vars[strings.TrimPrefix(k, "variable_")] = v
 		}
+	}
 
-		if v != "" {
-			if strings.Contains(v, "\r\n") {
-				return nil, fmt.Errorf(EInvalidCommandProvided, msg)
-			}
+	return vars
+}
+
+// addWaiter - Will register waiter so that reader hands it every message match returns true for
+func (c *SocketConnection) addWaiter(match func(*Message) bool) *waiter {
+	w := &waiter{match: match, ch: make(chan *Message, 64)}
+
+	c.w.Lock()
+	c.w.waiting[w] = struct{}{}
+	c.w.Unlock()
+
+	return w
+}
+
+// removeWaiter - Will stop delivering messages to waiter
+func (c *SocketConnection) removeWaiter(w *waiter) {
+	c.w.Lock()
+	delete(c.w.waiting, w)
+	c.w.Unlock()
+}
+
+// notifyWaiters - Called by reader for every message, before message is handed over to ReadMsg
+func (c *SocketConnection) notifyWaiters(msg *Message) {
+	if c.w == nil {
+		return
+	}
 
-			b.WriteString(fmt.Sprintf("%s: %s\n", k, v))
+	c.w.Lock()
+	defer c.w.Unlock()
+
+	var matched []*waiter
+
+	for w := range c.w.waiting {
+		if w.match(msg) {
+			matched = append(matched, w)
 		}
 	}
 
-	b.WriteString("\n")
+	if len(matched) == 0 {
+		return
+	}
+
+	// Message goes on to handlers/ReadMsg as well
+	msg.share()
 
-	if msg["content-length"] != "" && data != "" {
-		b.WriteString(data)
+	for _, w := range matched {
+		select {
+		case w.ch <- msg:
+		default:
+			Warn("Waiter is not keeping up. Dropping message: %s", msg.header("Event-Name"))
+		}
 	}
+}
 
-	// lock mutex
-	c.mtx.Lock()
-	_, err = b.WriteTo(c)
-	if err != nil {
-		c.mtx.Unlock()
+// waitFor - Will block until waiter gets a message, connection is gone or context is done. Waiters are fed by the
+// reader itself, so waiting does not depend on anyone reading messages with ReadMsg.
+func (c *SocketConnection) waitFor(ctx context.Context, w *waiter) (*Message, error) {
+	var closed chan struct{}
+	if c.p != nil {
+		closed = c.p.done
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg := <-w.ch:
+		msg.decode()
+		return msg, nil
+	case <-closed:
+		return nil, c.p.err
+	}
+}
+
+// SendMsg - Basically this func will send message to the opened connection
+// Headers with empty values are skipped and the rest is written in sorted order. Data (which can be binary) is sent
+// as message body with Content-Length computed out of it, any content-length in msg is ignored.
+func (c *SocketConnection) SendMsg(msg map[string]string, uuid, data string) (m *Message, err error) {
+	return c.SendMsgContext(context.Background(), msg, uuid, data)
+}
+
+// SendMsgContext - Same as SendMsg but gives up waiting for reply once ctx is done
+func (c *SocketConnection) SendMsgContext(ctx context.Context, msg map[string]string, uuid, data string) (m *Message, err error) {
+	if err := validateUUID(uuid); err != nil {
 		return nil, err
 	}
-	c.mtx.Unlock()
 
+	line := "sendmsg"
+
+	if uuid != "" {
+		line += " " + uuid
+	}
+
+	out := &Message{Headers: make(map[string]string, len(msg)), Body: []byte(data)}
+
+	for k, v := range msg {
+		if v != "" {
+			out.Headers[k] = v
+		}
+	}
+
+	return c.request(ctx, line, out)
+}
+
+// readReply - Will return whatever message is read from the connection next, the way SendMsg always did. Used by
+// connections put together by hand (no pipeline) only.
+func (c *SocketConnection) readReply() (*Message, error) {
 	select {
 	case err := <-c.err:
 		return nil, err
 	case m := <-c.m:
+		m.decode()
 		return m, nil
 	}
 }
@@ -206,11 +400,15 @@ func (c *SocketConnection) ReadMsg() (*Message, error) {
 	case err := <-c.err:
 		return nil, err
 	case msg := <-c.m:
+		msg.decode()
 		return msg, nil
+	case <-c.done:
+		return nil, ErrConnectionClosed
 	}
 }
 
-// Handle - Will handle new messages and close connection when there are no messages left to process
+// Handle - Will handle new messages and close connection when there are no messages left to process.
+// -ERR replies (*ReplyError) are handed to whoever waits on them and do not end it, connection itself is fine.
 func (c *SocketConnection) Handle() {
 
 	done := make(chan bool)
@@ -219,26 +417,128 @@ func (c *SocketConnection) Handle() {
 
 	go func() {
 		for {
-			msg, err := NewMessage(rbuf, true)
+			msg := newMessage(rbuf, c.limits)
+			err := msg.Parse()
 
 			if err != nil {
-				c.err <- err
+				// Message that could not be parsed is never handed out, it can be reused right away
+				msg.Release()
+				msg = nil
+
+				// Anything but -ERR to our command means connection is gone
+				var rerr *ReplyError
+				if !errors.As(err, &rerr) {
+					c.fail(err)
+					done <- true
+					break
+				}
+
+				// Freeswitch said -ERR to our command. Connection itself is fine so keep on reading.
+				if c.complete(nil, err) {
+					continue
+				}
+			} else {
+				c.notifyWaiters(msg)
+
+				if isReply(msg) && c.complete(msg, nil) {
+					continue
+				}
+			}
+
+			// Events, and replies nobody waits on, go through the queue so that reader never waits on handlers/ReadMsg
+			if c.q == nil {
+				c.deliver(queued{msg: msg, err: err})
+				continue
+			}
+
+			if err := c.q.push(queued{msg: msg, err: err}); err != nil {
+				Error("Event queue overflow. Closing connection to %s", c.OriginatorAddr())
+				c.Conn.Close()
+				c.fail(err)
 				done <- true
 				break
 			}
-
-			c.m <- msg
 		}
 	}()
 
+	if c.q != nil {
+		go c.pump()
+	}
+
 	<-done
 
-	// Closing the connection now as there's nothing left to do ...
-	c.Close()
+	if c.q == nil {
+		c.d.stop()
+	}
+
+	// Closing the connection now as there's nothing left to do ... Messages read so far are still handed to ReadMsg
+	// until Close() is called.
+	c.Conn.Close()
+}
+
+// fail - Will fail pending requests and hand err over to ReadMsg once everything read before it was handed out
+func (c *SocketConnection) fail(err error) {
+	if c.p != nil {
+		c.p.close(err)
+	}
+
+	if c.q == nil {
+		c.deliver(queued{err: err})
+		return
+	}
+
+	c.q.close(err)
+}
+
+// pump - Will deliver queued items until queue is closed
+func (c *SocketConnection) pump() {
+	for {
+		it, ok := c.q.pop()
+		if !ok {
+			break
+		}
+
+		c.deliver(it)
+	}
+
+	c.d.stop()
+}
+
+// deliver - Will hand event over to registered handlers or, if none wants it, hand it (or reply/error) to ReadMsg.
+// Everything is dropped instead in case connection was told nobody reads it (SetDropUnhandled) or, once connection
+// is closed (Close()), nobody is there to take it.
+func (c *SocketConnection) deliver(it queued) {
+	if it.err == nil && c.dispatch(it.msg) {
+		return
+	}
+
+	if c.d != nil && c.d.dropsUnhandled() {
+		Debug("Nobody reads messages of connection. Dropping %s", it.name())
+		return
+	}
+
+	if it.err != nil {
+		select {
+		case c.err <- it.err:
+		case <-c.done:
+		}
+		return
+	}
+
+	select {
+	case c.m <- it.msg:
+	case <-c.done:
+		Debug("Connection is closed. Dropping %s", it.name())
+	}
 }
 
-// Close - Will close down net connection and return error if error happen
+// Close - Will close down net connection and return error if error happen. ReadMsg returns ErrConnectionClosed
+// from now on, messages that were not read yet are dropped.
 func (c *SocketConnection) Close() error {
+	if c.done != nil {
+		c.closeOnce.Do(func() { close(c.done) })
+	}
+
 	if err := c.Conn.Close(); err != nil {
 		return err
 	}
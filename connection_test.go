@@ -3,11 +3,18 @@ package goesl
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/byoungdale/goesl/esltest"
 )
 
 func TestDial(t *testing.T) {
@@ -104,103 +111,72 @@ func TestSendMany(t *testing.T) {
 }
 
 func TestSendEvent(t *testing.T) {
-	serverConn, clientConn := net.Pipe()
-	c := &SocketConnection{
-		Conn: clientConn,
-		mtx:  &sync.RWMutex{},
-		err:  make(chan error),
-		m:    make(chan *Message),
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
 	}
 	defer c.Close()
-	defer serverConn.Close()
-	defer clientConn.Close()
 
-	// Test valid command
-	go func() {
+	go c.Handle()
 
-		for {
-			buf := make([]byte, 2048)
-			_, err := serverConn.Read(buf)
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skipping auth
+	sess.NextCommand(time.Second)
+
+	tests := []struct {
+		name    string
+		event   OutgoingEvent
+		line    string
+		headers map[string]string
+	}{
+		{"no headers", OutgoingEvent{Name: "HEARTBEAT"}, "sendevent HEARTBEAT", map[string]string{}},
+		{"custom", OutgoingEvent{Subclass: "myapp::ping", Headers: map[string]string{"event-subclass": "other", "X-Id": "1"}, Body: "ping"}, "sendevent CUSTOM", map[string]string{"Event-Subclass": "myapp::ping", "X-Id": "1", "Content-Length": "4"}},
+		{"notify", NotifyEvent("internal", "1000", "example.com", "check-sync", "application/simple-message-summary", "Messages-Waiting: yes\r\n"), "sendevent NOTIFY", map[string]string{"profile": "internal", "user": "1000", "host": "example.com", "event-string": "check-sync", "content-type": "application/simple-message-summary", "Content-Length": "23"}},
+		{"send message", SendMessageEvent("internal", "1000", "example.com", "text/plain", "hello"), "sendevent SEND_MESSAGE", map[string]string{"profile": "internal", "user": "1000", "host": "example.com", "content-type": "text/plain", "Content-Length": "5"}},
+		{"message waiting", MessageWaitingEvent("1000@example.com", 2, 1), "sendevent MESSAGE_WAITING", map[string]string{"MWI-Messages-Waiting": "yes", "MWI-Message-Account": "1000@example.com", "MWI-Voice-Message": "2/1 (0/0)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := c.SendEvent(tt.event)
 			if err != nil {
-				t.Logf("Server: Error reading from client: '%v'", err)
-				return
+				t.Fatal(err)
 			}
-		}
-	}()
-
-	// Test sending with no headers
-	emptyHeaders := []string{}
-	err := c.SendEvent("SEND_INFO", emptyHeaders, "")
 
-	if err == nil || err.Error() != "Must send at least one event header, detected `0` header" {
-		t.Logf("Got error sending request: '%v'", err)
-		t.Fail()
-	}
+			if !strings.HasPrefix(reply.GetHeader("Reply-Text"), "+OK ") {
+				t.Fatalf("Unexpected reply %v", reply.Headers)
+			}
 
-	// Test sending with headers
-	// CHANNEL_HANGUP event Event headers
-	// reference: https://developer.signalwire.com/freeswitch/FreeSWITCH-Explained/Introduction/Event-System/Event-List_7143557/#16-channel_hangup-event
-	headers := []string{
-		"Hangup-Cause: NORMAL_CLEARING",
-		"Channel-Read-Codec-Name: PCMU",
-		"Channel-Read-Codec-Rate: 8000",
-		"Channel-Write-Codec-Name: PCMU",
-		"Channel-Write-Codec-Rate: 8000",
-		"Caller-Username: jonas",
-		"Caller-Dialplan: XML",
-		"Caller-Caller-ID-Name: jonas",
-		"Caller-Caller-ID-Number: jonas",
-		"Caller-Network-Addr: 192.168.0.58",
-		"Caller-Destination-Number: 541",
-		"Caller-Unique-ID: 0dd4e4f7-36ed-a04d-a8f7-7aebb683af50",
-		"Caller-Source: mod_sofia",
-		"Caller-Context: default",
-		"Caller-Channel-Name: sofia/192.168.0.58/jonas%40192.168.0.58%3A5060",
-		"Caller-Screen-Bit: yes",
-		"Caller-Privacy-Hide-Name: no",
-		"Caller-Privacy-Hide-Number: no",
-		"Originatee-Username: jonas",
-		"Originatee-Dialplan: XML",
-		"Originatee-Caller-ID-Name: jonas",
-		"Originatee-Caller-ID-Number: jonas",
-		"Originatee-Network-Addr: 192.168.0.58",
-		"Originatee-Destination-Number: 192.168.0.58/arne%25192.168.0.58",
-		"Originatee-Unique-ID: f66e8e31-c9fb-9b41-a9a2-a1586facb97f",
-		"Originatee-Source: mod_sofia",
-		"Originatee-Context: default",
-		"Originatee-Channel-Name: sofia/192.168.0.58/arne",
-		"Originatee-Screen-Bit: yes",
-		"Originatee-Privacy-Hide-Name: no",
-		"Originatee-Privacy-Hide-Number: no",
-	}
-
-	err = c.SendEvent("CHANNEL_HANGUP", headers, "")
+			cmd, err := sess.NextCommand(time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	if err != nil {
-		t.Logf("Got error sending request: '%v'", err)
-		t.Fail()
-	}
+			if cmd.Line != tt.line || len(cmd.Headers) != len(tt.headers) || string(cmd.Body) != tt.event.Body {
+				t.Fatalf("Unexpected command %+v", cmd)
+			}
 
-	// Test sending body
-	// SEND_INFO
-	// profile: external
-	// content-type: text/plain
-	// to-uri: sip:1@2.3.4.5
-	// from-uri: sip:1@1.2.3.4
-	// content-length: 15
-	//
-	// test
-	headers = []string{
-		"Hangup-Cause: NORMAL_CLEARING",
-		"Channel-Read-Codec-Name: PCMU",
-		"Channel-Read-Codec-Rate: 8000",
+			for k, v := range tt.headers {
+				if cmd.Headers[k] != v {
+					t.Fatalf("Expected %s to be %q, got %+v", k, v, cmd)
+				}
+			}
+		})
 	}
 
-	err = c.SendEvent("SEND_INFO", headers, "test")
-
-	if err != nil {
-		t.Logf("Got error sending request: '%v'", err)
-		t.Fail()
+	for _, e := range []OutgoingEvent{{}, {Name: "CUSTOM"}, {Name: "NOTIFY", Subclass: "myapp::ping"}, {Name: "SEND INFO"}, {Subclass: "my app"}} {
+		if _, err := c.SendEvent(e); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected %+v to be rejected, got %v", e, err)
+		}
 	}
 }
 
@@ -278,6 +254,133 @@ func TestSendMsg(t *testing.T) {
 	}
 }
 
+func TestSendMsgBody(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skipping auth
+	sess.NextCommand(time.Second)
+
+	// Body is binary and looks like headers & commands of its own, Content-Length keeps it in the body
+	body := "\x00\xff\r\n\ncall-command: hangup\n\nexit\n\n"
+
+	if _, err := c.SendMsg(map[string]string{"call-command": "unicast", "content-length": "1"}, "abc", body); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := sess.NextCommand(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Line != "sendmsg abc" || cmd.Headers["call-command"] != "unicast" || string(cmd.Body) != body {
+		t.Fatalf("Unexpected command %+v", cmd)
+	}
+
+	if cmd.Headers["Content-Length"] != strconv.Itoa(len(body)) || cmd.Headers["content-length"] != "" {
+		t.Fatalf("Expected computed Content-Length, got %v", cmd.Headers)
+	}
+
+	args := strings.Repeat("a", ExecuteArgBodyLength+1)
+
+	if _, err := c.ExecuteUUID("abc", "playback", args, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err = sess.NextCommand(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Headers["execute-app-arg"] != "" || cmd.Headers["content-type"] != "text/plain" || string(cmd.Body) != args {
+		t.Fatalf("Expected long args to be sent as body, got %+v", cmd.Headers)
+	}
+
+	// Nothing else (e.g. hangup or exit from the body) was sent
+	if cmd, err := sess.NextCommand(100 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected command %+v", cmd)
+	}
+}
+
+func TestSendMsgHeaderOrder(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+
+	go c.Handle()
+
+	msg := map[string]string{
+		"call-command":     "execute",
+		"execute-app-name": "playback",
+		"execute-app-arg":  "/tmp/test.wav",
+		"event-lock":       "true",
+		"loops":            "",
+	}
+
+	expected := "sendmsg abc\ncall-command: execute\nevent-lock: true\nexecute-app-arg: /tmp/test.wav\nexecute-app-name: playback\n\n"
+
+	for i := 0; i < 5; i++ {
+		go c.SendMsg(msg, "abc", "")
+
+		buf := make([]byte, len(expected))
+		if _, err := io.ReadFull(serverConn, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(buf) != expected {
+			t.Fatalf("Expected %q, got %q", expected, buf)
+		}
+
+		io.WriteString(serverConn, "Content-Type: command/reply\nReply-Text: +OK\n\n")
+	}
+}
+
+func TestSendMsgInjection(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	// Nobody reads from serverConn, anything written would block
+	c := &SocketConnection{Conn: clientConn, mtx: &sync.RWMutex{}}
+
+	tests := []struct {
+		name string
+		msg  map[string]string
+		uuid string
+	}{
+		{"uuid", map[string]string{"call-command": "hangup"}, "abc\ncall-command: hangup"},
+		{"uuid carriage return", map[string]string{"call-command": "hangup"}, "abc\r"},
+		{"key", map[string]string{"call-command": "execute", "x\nexecute-app-name": "system"}, "abc"},
+		{"value", map[string]string{"call-command": "execute", "execute-app-name": "playback\nexecute-app-name: system"}, "abc"},
+		{"value ending command", map[string]string{"call-command": "execute", "execute-app-arg": "a\n\napi status"}, "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.SendMsg(tt.msg, tt.uuid, ""); err == nil {
+				t.Fatal("Expected sendmsg to be rejected")
+			}
+		})
+	}
+}
+
 func TestReadMsg(t *testing.T) {
 	serverConn, clientConn := net.Pipe()
 	c := &SocketConnection{
@@ -568,3 +671,247 @@ func TestClose(t *testing.T) {
 //func TestReconnectIfNeeded(t *testing.T) {
 //	t.FailNow()
 //}
+
+// readSendMsg - Will read single sendmsg command from conn and return its headers
+func readSendMsg(r *bufio.Reader) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(headers) == 0 {
+				continue
+			}
+			return headers, nil
+		}
+
+		if kv := strings.SplitN(line, ": ", 2); len(kv) == 2 {
+			headers[kv[0]] = kv[1]
+		} else {
+			headers["command"] = line
+		}
+	}
+}
+
+// writeEvent - Will write text/event-plain event with given headers to conn
+func writeEvent(conn net.Conn, headers ...string) {
+	body := strings.Join(headers, "\n") + "\n\n"
+	fmt.Fprintf(conn, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s", len(body), body)
+}
+
+func TestExecuteWait(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+	defer serverConn.Close()
+
+	// Server
+	go func() {
+		r := bufio.NewReader(serverConn)
+		headers, err := readSendMsg(r)
+		if err != nil {
+			return
+		}
+
+		serverConn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n"))
+
+		writeEvent(serverConn, "Event-Name: CHANNEL_EXECUTE", "Application: play_and_get_digits", "Application-UUID: "+headers["event-uuid"])
+		writeEvent(serverConn, "Event-Name: CHANNEL_EXECUTE_COMPLETE", "Application-UUID: some-other-app")
+		writeEvent(serverConn,
+			"Event-Name: CHANNEL_EXECUTE_COMPLETE",
+			"Application: play_and_get_digits",
+			"Application-Response: _none_",
+			"Application-UUID: "+headers["event-uuid"],
+			"variable_pin: 1234",
+		)
+	}()
+
+	go c.Handle()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := c.ExecuteWait(ctx, "play_and_get_digits", "1 4 1 5000 # prompt.wav silence_stream://250 pin \\d+")
+	if err != nil {
+		t.Fatalf("Got error while waiting for application to complete: %s", err)
+	}
+
+	if res.Application != "play_and_get_digits" || res.Response != "_none_" || res.Variables["pin"] != "1234" {
+		t.Fatalf("Unexpected execute result: %+v", res)
+	}
+
+	// Events read while waiting must still be available to ReadMsg
+	msg, err := c.ReadMsg()
+	if err != nil || msg.GetHeader("Event-Name") != "CHANNEL_EXECUTE" {
+		t.Fatalf("Expected CHANNEL_EXECUTE to be kept for ReadMsg, got %v, %v", msg, err)
+	}
+}
+
+func TestExecuteWaitReadMsgOrder(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+	defer serverConn.Close()
+
+	names := []string{"DTMF", "CHANNEL_EXECUTE_COMPLETE", "CHANNEL_PROGRESS_MEDIA", "HEARTBEAT"}
+
+	// Server
+	go func() {
+		r := bufio.NewReader(serverConn)
+		headers, err := readSendMsg(r)
+		if err != nil {
+			return
+		}
+
+		writeEvent(serverConn, "Event-Name: DTMF")
+		serverConn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n"))
+		writeEvent(serverConn, "Event-Name: CHANNEL_EXECUTE_COMPLETE", "Application-UUID: "+headers["event-uuid"])
+		writeEvent(serverConn, "Event-Name: CHANNEL_PROGRESS_MEDIA")
+		writeEvent(serverConn, "Event-Name: HEARTBEAT")
+	}()
+
+	go c.Handle()
+
+	// Reader already blocked in ReadMsg gets every event, in order, while ExecuteWait runs
+	read := make(chan string, len(names))
+	go func() {
+		for range names {
+			msg, err := c.ReadMsg()
+			if err != nil {
+				return
+			}
+			read <- msg.GetHeader("Event-Name")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.ExecuteWait(ctx, "playback", "/tmp/test.wav"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		select {
+		case got := <-read:
+			if got != name {
+				t.Fatalf("Expected %s, got %s", name, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %s to be read with ReadMsg", name)
+		}
+	}
+}
+
+func TestExecuteWaitHangup(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+	defer serverConn.Close()
+
+	// Server
+	go func() {
+		r := bufio.NewReader(serverConn)
+		if _, err := readSendMsg(r); err != nil {
+			return
+		}
+
+		serverConn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n"))
+		writeEvent(serverConn, "Event-Name: CHANNEL_HANGUP", "Unique-ID: c3b923ab", "Hangup-Cause: NORMAL_CLEARING")
+	}()
+
+	go c.Handle()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.ExecuteUUIDWait(ctx, "c3b923ab", "playback", "/tmp/test.wav"); !errors.Is(err, ErrChannelHangup) {
+		t.Fatalf("Expected ErrChannelHangup, got %v", err)
+	}
+}
+
+func TestExecuteWaitContext(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+	defer serverConn.Close()
+
+	// Server
+	go func() {
+		r := bufio.NewReader(serverConn)
+		if _, err := readSendMsg(r); err != nil {
+			return
+		}
+
+		serverConn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n"))
+	}()
+
+	go c.Handle()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.ExecuteWait(ctx, "playback", "/tmp/test.wav"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestExecuteWaitArgs(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skipping auth
+	sess.NextCommand(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var injection *InjectionError
+	if _, err := c.ExecuteUUIDWait(ctx, "abc", "playback\nexecute-app-name: system", ""); !errors.As(err, &injection) || injection.Field != FieldAppName {
+		t.Fatalf("Expected app name to be rejected, got %v", err)
+	}
+
+	args := strings.Repeat("a", ExecuteArgBodyLength+1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ExecuteUUIDWait(ctx, "abc", "playback", args)
+		done <- err
+	}()
+
+	cmd, err := sess.NextCommand(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long args are sent as body, same as ExecuteUUID does
+	if cmd.Headers["execute-app-arg"] != "" || cmd.Headers["content-type"] != "text/plain" || string(cmd.Body) != args {
+		t.Fatalf("Expected long args to be sent as body, got %+v", cmd.Headers)
+	}
+
+	sess.SendEvent(esltest.NewEvent("CHANNEL_EXECUTE_COMPLETE", "Unique-ID", "abc", "Application-UUID", cmd.Headers["event-uuid"]))
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
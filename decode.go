@@ -0,0 +1,368 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// eventHeaderNames - Headers (nearly) every event comes with. Their names, and lower case of them headers are
+// indexed by, are shared instead of being allocated for every single event.
+var eventHeaderNames, foldedHeaderNames = func() (map[string]string, map[string]string) {
+	names := make(map[string]string)
+	folded := make(map[string]string)
+
+	for _, name := range []string{
+		"Content-Type", "Content-Disposition", "Reply-Text", "Socket-Mode", "Control", "Event-Name", "Event-Subclass", "Core-UUID", "FreeSWITCH-Hostname", "FreeSWITCH-Switchname", "FreeSWITCH-IPv4",
+		"FreeSWITCH-IPv6", "Event-Date-Local", "Event-Date-GMT", "Event-Date-Timestamp", "Event-Calling-File",
+		"Event-Calling-Function", "Event-Calling-Line-Number", "Event-Sequence", "Content-Length", "Unique-ID",
+		"Channel-State", "Channel-Call-State", "Channel-State-Number", "Channel-Name", "Call-Direction",
+		"Presence-Call-Direction", "Channel-HIT-Dialplan", "Channel-Presence-ID", "Channel-Call-UUID",
+		"Answer-State", "Hangup-Cause", "Caller-Direction", "Caller-Logical-Direction", "Caller-Username",
+		"Caller-Dialplan", "Caller-Caller-ID-Name", "Caller-Caller-ID-Number", "Caller-Orig-Caller-ID-Name",
+		"Caller-Orig-Caller-ID-Number", "Caller-Callee-ID-Name", "Caller-Callee-ID-Number", "Caller-Network-Addr",
+		"Caller-ANI", "Caller-Destination-Number", "Caller-Unique-ID", "Caller-Source", "Caller-Context",
+		"Caller-Channel-Name", "Caller-Profile-Index", "Caller-Profile-Created-Time", "Caller-Channel-Created-Time",
+		"Caller-Channel-Answered-Time", "Caller-Channel-Hangup-Time", "Caller-Screen-Bit", "Caller-Privacy-Hide-Name",
+		"Caller-Privacy-Hide-Number", "Application", "Application-Data", "Application-Response", "Application-UUID",
+		"Job-UUID", "Job-Command", "Job-Command-Arg", "DTMF-Digit", "DTMF-Duration", "DTMF-Source",
+		"Other-Leg-Unique-ID", "Other-Type", "variable_uuid", "variable_direction", "variable_call_uuid",
+		"variable_sip_from_user", "variable_sip_to_user", "variable_sip_call_id", "Event-Info", "Up-Time",
+		"FreeSWITCH-Version", "Uptime-msec", "Session-Count", "Max-Sessions", "Session-Per-Sec",
+		"Session-Per-Sec-Last", "Session-Per-Sec-Max", "Session-Per-Sec-FiveMin", "Session-Since-Startup",
+		"Session-Peak-Max", "Session-Peak-FiveMin", "Idle-CPU",
+	} {
+		names[name] = name
+		folded[name] = strings.ToLower(name)
+	}
+
+	return names, folded
+}()
+
+// headerName - Will return header name without allocating when it is a well known one
+func headerName(b []byte) string {
+	if name, ok := eventHeaderNames[string(b)]; ok {
+		return name
+	}
+
+	return string(b)
+}
+
+// foldHeaderName - Will return lower case of header name, the way headers are indexed for case-insensitive lookup
+func foldHeaderName(name string) string {
+	if folded, ok := foldedHeaderNames[name]; ok {
+		return folded
+	}
+
+	return strings.ToLower(name)
+}
+
+// decodeHeaderValue - Will percent decode header value the way url.QueryUnescape does, straight from the read
+// buffer. Values without % are only copied. Values that cannot be decoded are kept as they are.
+func decodeHeaderValue(v []byte) string {
+	if bytes.IndexByte(v, '%') < 0 {
+		return string(v)
+	}
+
+	for i := 0; i < len(v); i++ {
+		if v[i] == '%' {
+			if i+2 >= len(v) || !isHex(v[i+1]) || !isHex(v[i+2]) {
+				end := i + 3
+				if end > len(v) {
+					end = len(v)
+				}
+
+				Error(ECouldNotDecode, url.EscapeError(v[i:end]))
+				return string(v)
+			}
+			i += 2
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(v))
+
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '%':
+			b.WriteByte(unHex(v[i+1])<<4 | unHex(v[i+2]))
+			i += 2
+		case '+':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unHex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// decodeEventJSON - Will decode json object freeswitch sends events as straight into message headers, without going through
+// map[string]interface{}. Events are flat objects of strings, values of any other type (e.g. CHANNEL_EXECUTE_COMPLETE's
+// "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]) are validated and skipped.
+func decodeEventJSON(data []byte, m *Message) error {
+	s := jsonScanner{data: data}
+
+	if err := s.expect('{'); err != nil {
+		return err
+	}
+
+	if s.skipSpace(); s.peek() == '}' {
+		s.pos++
+		return s.end()
+	}
+
+	for {
+		s.skipSpace()
+
+		key, err := s.readString(true)
+		if err != nil {
+			return err
+		}
+
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+
+		if s.skipSpace(); s.peek() == '"' {
+			lit, plain, err := s.scanString()
+			if err != nil {
+				return err
+			}
+
+			// Values are unescaped once headers are looked at
+			f := headerField{name: key, key: key, encoded: lit[1 : len(lit)-1]}
+			if !plain {
+				f.encoded, f.enc = lit, valueJSON
+			}
+
+			m.setHeader(f)
+		} else {
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+
+			// Same key could have had string value earlier on, last value wins
+			m.deleteHeader(key)
+
+			Warn("Removed non-string property (%s)", key)
+		}
+
+		s.skipSpace()
+
+		switch s.next() {
+		case ',':
+			continue
+		case '}':
+			return s.end()
+		default:
+			return s.errorf("expected , or } after object value")
+		}
+	}
+}
+
+// jsonScanner - Reads through json document byte by byte
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+func (s *jsonScanner) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("invalid event json at offset %d: %s", s.pos, fmt.Sprintf(format, v...))
+}
+
+// peek - Will return next byte without consuming it, 0 at the end of data
+func (s *jsonScanner) peek() byte {
+	if s.pos >= len(s.data) {
+		return 0
+	}
+
+	return s.data[s.pos]
+}
+
+// next - Will consume next byte, 0 at the end of data
+func (s *jsonScanner) next() byte {
+	c := s.peek()
+	s.pos++
+
+	return c
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect - Will consume c, skipping whitespace before it
+func (s *jsonScanner) expect(c byte) error {
+	s.skipSpace()
+
+	if s.next() != c {
+		return s.errorf("expected %q", c)
+	}
+
+	return nil
+}
+
+// end - Will make sure only whitespace is left after top level object
+func (s *jsonScanner) end() error {
+	if s.skipSpace(); s.pos < len(s.data) {
+		return s.errorf("unexpected data after event object")
+	}
+
+	return nil
+}
+
+// scanString - Will go through json string making sure it is valid. Returns it with its quotes, together with
+// whether it can be taken as it is (plain ASCII, no escapes) or has to go through encoding/json.
+func (s *jsonScanner) scanString() (lit []byte, plain bool, err error) {
+	if s.next() != '"' {
+		return nil, false, s.errorf("expected string")
+	}
+
+	start, plain := s.pos-1, true
+
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+
+		switch {
+		case c == '"':
+			s.pos++
+			return s.data[start:s.pos], plain, nil
+		case c == '\\':
+			plain = false
+
+			if err := s.escape(); err != nil {
+				return nil, false, err
+			}
+		case c < 0x20:
+			return nil, false, s.errorf("control character in string")
+		case c >= utf8.RuneSelf:
+			plain = false
+			s.pos++
+		default:
+			s.pos++
+		}
+	}
+
+	return nil, false, s.errorf("unterminated string")
+}
+
+// escape - Will consume escape sequence (\n, \u00e9...) within json string
+func (s *jsonScanner) escape() error {
+	s.pos++
+
+	switch s.next() {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		return nil
+	case 'u':
+		for i := 0; i < 4; i++ {
+			if !isHex(s.next()) {
+				return s.errorf("invalid unicode escape")
+			}
+		}
+
+		return nil
+	}
+
+	return s.errorf("invalid escape")
+}
+
+// readString - Will read json string. Plain ASCII strings are taken as they are (shared when name is a well known
+// header name), anything with escapes or other unicode goes through encoding/json so that result is exactly what
+// json.Unmarshal would give.
+func (s *jsonScanner) readString(name bool) (string, error) {
+	lit, plain, err := s.scanString()
+	if err != nil {
+		return "", err
+	}
+
+	if plain && name {
+		return headerName(lit[1 : len(lit)-1]), nil
+	}
+
+	if plain {
+		return string(lit[1 : len(lit)-1]), nil
+	}
+
+	return decodeJSONString(lit), nil
+}
+
+// decodeJSONString - Will unescape json string literal (quotes included) already validated by scanString
+func decodeJSONString(lit []byte) string {
+	var v string
+	if err := json.Unmarshal(lit, &v); err != nil {
+		return string(lit)
+	}
+
+	return v
+}
+
+// skipValue - Will skip over any json value that is not a string
+func (s *jsonScanner) skipValue() error {
+	start, depth := s.pos, 0
+
+	for s.pos < len(s.data) {
+		switch c := s.data[s.pos]; c {
+		case '"':
+			if _, _, err := s.scanString(); err != nil {
+				return err
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return s.validate(start)
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return s.validate(start)
+			}
+		}
+
+		s.pos++
+	}
+
+	return s.validate(start)
+}
+
+// validate - Will make sure skipped value is valid json
+func (s *jsonScanner) validate(start int) error {
+	if !json.Valid(s.data[start:s.pos]) {
+		return s.errorf("invalid value")
+	}
+
+	return nil
+}
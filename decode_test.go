@@ -0,0 +1,132 @@
+package goesl
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHeaderValue(t *testing.T) {
+	tests := map[string]string{
+		"plain":                            "plain",
+		"a+b":                              "a+b",
+		"Wed,%2023%20Jan%202008%2018%3A48": "Wed, 23 Jan 2008 18:48",
+		"a+b%20c":                          "a b c",
+		"%E2%82%AC":                        "€",
+		"100%":                             "100%",
+		"%zz":                              "%zz",
+	}
+
+	for in, expected := range tests {
+		if v := decodeHeaderValue([]byte(in)); v != expected {
+			t.Errorf("Expected %q to decode as %q, got %q", in, expected, v)
+		}
+	}
+}
+
+func TestDecodeEventJSONScanner(t *testing.T) {
+	m := &Message{Headers: make(map[string]string)}
+
+	err := decodeEventJSON([]byte(` { "Event-Name" : "CHANNEL_EXECUTE_COMPLETE", "Caller-Caller-ID-Name":"José \"J\" ☎",
+		"variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"], "Count": 5, "Nested": {"a": [1, "}"]}, "_body": "line\n" } `), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values are only unescaped once headers are looked at
+	m.decode()
+
+	expected := map[string]string{"Event-Name": "CHANNEL_EXECUTE_COMPLETE", "Caller-Caller-ID-Name": `José "J" ☎`, "_body": "line\n"}
+
+	if len(m.Headers) != len(expected) {
+		t.Fatalf("Unexpected headers %v", m.Headers)
+	}
+
+	for k, v := range expected {
+		if m.Headers[k] != v {
+			t.Fatalf("Expected %s to be %q, got %q", k, v, m.Headers[k])
+		}
+	}
+
+	if names := m.HeaderNames(); strings.Join(names, ",") != "Event-Name,Caller-Caller-ID-Name,_body" {
+		t.Fatalf("Unexpected header order %v", names)
+	}
+
+	for _, invalid := range []string{``, `[]`, `{`, `{"a"}`, `{"a":}`, `{"a":"b",}`, `{"a":"b"} x`, `{"a":tru}`, `{"a":[1,]}`, "{\"a\":\"\x01\"}", `{"a":"\x"}`, `{"a":"\u12"}`, `{"a":"b\'"}`} {
+		if err := decodeEventJSON([]byte(invalid), &Message{Headers: make(map[string]string)}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func FuzzDecodeHeaderValue(f *testing.F) {
+	for _, seed := range []string{"plain", "a+b%20c", "%E2%82%AC", "100%", "%zz", "%%41"} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v := decodeHeaderValue(data)
+
+		if !strings.Contains(string(data), "%") {
+			if v != string(data) {
+				t.Fatalf("Value without escapes changed from %q to %q", data, v)
+			}
+			return
+		}
+
+		expected, err := url.QueryUnescape(string(data))
+		if err != nil {
+			expected = string(data)
+		}
+
+		if v != expected {
+			t.Fatalf("Expected %q to decode as %q, got %q", data, expected, v)
+		}
+	})
+}
+
+func FuzzDecodeEventJSON(f *testing.F) {
+	for _, seed := range []string{
+		`{"Event-Name":"HEARTBEAT","Up-Time":"0 years, 19 days"}`,
+		`{"a":["b","c"],"d":{"e":null},"f":1.5e3,"g":true}`,
+		`{"a":"é\n\"","a":"last"}`,
+		`{}`,
+		`null`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m := &Message{Headers: make(map[string]string)}
+		err := decodeEventJSON(data, m)
+		m.decode()
+		headers := m.Headers
+
+		var decoded map[string]interface{}
+		if json.Unmarshal(data, &decoded) != nil || decoded == nil {
+			if err == nil && decoded == nil {
+				t.Fatalf("Accepted invalid event json %q", data)
+			}
+			return
+		}
+
+		if err != nil {
+			t.Fatalf("Rejected valid event json %q: %s", data, err)
+		}
+
+		strs := 0
+		for k, v := range decoded {
+			if s, ok := v.(string); ok {
+				strs++
+				if headers[k] != s {
+					t.Fatalf("Expected %q to be %q, got %q", k, s, headers[k])
+				}
+			}
+		}
+
+		if strs != len(headers) || len(m.HeaderNames()) != len(headers) {
+			t.Fatalf("Expected %d headers, got %v", strs, headers)
+		}
+	})
+}
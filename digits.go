@@ -0,0 +1,164 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CollectDigitsOptions - How CollectDigits should gather DTMF from the channel. Zero values disable given limit.
+//
+// Timeout is how long we wait for the first digit (counting from the moment prompt finished playing) and
+// InterDigitTimeout how long we wait for every digit after it. Digit found in Terminators ends collection and is
+// not part of collected digits. Validate has to match all of the collected digits, not just part of them (as if it
+// was anchored with ^ and $). In case Prompt is set it's played before (and while) collecting and with BargeIn
+// playback is stopped (uuid_break) as soon as first digit is pressed.
+type CollectDigitsOptions struct {
+	UUID              string
+	MaxDigits         int
+	Terminators       string
+	Timeout           time.Duration
+	InterDigitTimeout time.Duration
+	Validate          *regexp.Regexp
+	Prompt            string
+	BargeIn           bool
+}
+
+// CollectDigits - Will gather DTMF digits pressed on the channel out of DTMF events. Connection must be subscribed
+// to channel events (e.g. myevents) and Handle() must be running. In case nothing was pressed before timeout
+// ErrDigitTimeout is returned and ErrInvalidDigits in case collected digits do not match opts.Validate. Caller
+// pressing terminator alone gets empty digits and no error, unless opts.Validate rejects them.
+func (c *SocketConnection) CollectDigits(ctx context.Context, opts CollectDigitsOptions) (string, error) {
+	var validate *regexp.Regexp
+	if opts.Validate != nil {
+		validate = regexp.MustCompile(`^(?:` + opts.Validate.String() + `)$`)
+	}
+
+	promptUUID := ""
+	if opts.Prompt != "" {
+		promptUUID = newUUID()
+	}
+
+	w := c.addWaiter(func(msg *Message) bool {
+		if msg.header("Content-Type") == "text/disconnect-notice" {
+			return true
+		}
+
+		if opts.UUID != "" && msg.header("Unique-ID") != opts.UUID {
+			return false
+		}
+
+		switch msg.header("Event-Name") {
+		case "DTMF", "CHANNEL_HANGUP", "CHANNEL_HANGUP_COMPLETE":
+			return true
+		case "CHANNEL_EXECUTE_COMPLETE":
+			return promptUUID != "" && msg.header("Application-UUID") == promptUUID
+		}
+
+		return false
+	})
+	defer c.removeWaiter(w)
+
+	playing := false
+
+	if opts.Prompt != "" {
+		if _, err := apiArg(opts.Prompt); err != nil {
+			return "", err
+		}
+
+		if _, err := c.executeUUID(ctx, opts.UUID, "playback", opts.Prompt, map[string]string{
+			"event-lock": "true",
+			"event-uuid": promptUUID,
+		}); err != nil {
+			return "", err
+		}
+
+		playing = true
+	}
+
+	var digits strings.Builder
+	terminated := false
+
+	for {
+		// Timers only start running once prompt is done
+		timeout := opts.Timeout
+		if digits.Len() > 0 {
+			timeout = opts.InterDigitTimeout
+		}
+
+		wctx, cancel := ctx, func() {}
+		if !playing && timeout > 0 {
+			wctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		msg, err := c.waitFor(wctx, w)
+		cancel()
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+
+			return digits.String(), err
+		}
+
+		switch msg.GetHeader("Event-Name") {
+		case "CHANNEL_EXECUTE_COMPLETE":
+			playing = false
+			continue
+		case "DTMF":
+		default:
+			return digits.String(), ErrChannelHangup
+		}
+
+		digit := msg.GetHeader("DTMF-Digit")
+
+		if playing && opts.BargeIn {
+			uuid := opts.UUID
+			if uuid == "" {
+				uuid = msg.GetHeader("Unique-ID")
+			}
+
+			if err := c.breakPlayback(uuid); err != nil {
+				return digits.String(), err
+			}
+			playing = false
+		}
+
+		if digit != "" && strings.Contains(opts.Terminators, digit) {
+			terminated = true
+			break
+		}
+
+		digits.WriteString(digit)
+
+		if opts.MaxDigits > 0 && digits.Len() >= opts.MaxDigits {
+			break
+		}
+	}
+
+	if digits.Len() == 0 && !terminated {
+		return "", ErrDigitTimeout
+	}
+
+	if validate != nil && !validate.MatchString(digits.String()) {
+		return digits.String(), ErrInvalidDigits
+	}
+
+	return digits.String(), nil
+}
+
+// breakPlayback - Will stop whatever is currently played to the channel. Executing break application would be
+// queued behind the playback it is meant to stop, uuid_break api is not.
+func (c *SocketConnection) breakPlayback(uuid string) error {
+	_, err := c.uuidApi("uuid_break", []string{uuid})
+	return err
+}
@@ -0,0 +1,158 @@
+package goesl
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func TestCollectDigitsBargeIn(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	apps := make(chan string, 2)
+
+	// Server
+	go func() {
+		cmd, err := sess.NextCommand(5 * time.Second)
+		if err != nil {
+			return
+		}
+		apps <- cmd.Headers["execute-app-name"]
+
+		sess.SendEvent(esltest.NewEvent("DTMF", "Unique-ID", "abc", "DTMF-Digit", "1"))
+
+		cmd, err = sess.NextCommand(5 * time.Second)
+		if err != nil {
+			return
+		}
+		apps <- cmd.Line
+
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "2"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "3"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "#"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	digits, err := c.CollectDigits(ctx, CollectDigitsOptions{
+		MaxDigits:   10,
+		Terminators: "#",
+		Prompt:      "/tmp/prompt.wav",
+		BargeIn:     true,
+		Validate:    regexp.MustCompile(`^\d{3}$`),
+	})
+	if err != nil {
+		t.Fatalf("Got error collecting digits: %s", err)
+	}
+
+	if digits != "123" {
+		t.Fatalf("Expected 123, got %q", digits)
+	}
+
+	if app := <-apps; app != "playback" {
+		t.Fatalf("Expected prompt playback, got %q", app)
+	}
+
+	if cmd := <-apps; cmd != "api uuid_break abc" {
+		t.Fatalf("Expected playback to be stopped with uuid_break, got %q", cmd)
+	}
+}
+
+func TestCollectDigitsTimeout(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	// Server
+	go func() {
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "4"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "2"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	digits, err := c.CollectDigits(ctx, CollectDigitsOptions{
+		Timeout:           time.Second,
+		InterDigitTimeout: 100 * time.Millisecond,
+	})
+	if err != nil || digits != "42" {
+		t.Fatalf("Expected 42, got %q, %v", digits, err)
+	}
+
+	_, err = c.CollectDigits(ctx, CollectDigitsOptions{Timeout: 100 * time.Millisecond})
+	if !errors.Is(err, ErrDigitTimeout) {
+		t.Fatalf("Expected ErrDigitTimeout, got %v", err)
+	}
+}
+
+func TestCollectDigitsInvalid(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	// Server
+	go func() {
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "*"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "9"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	digits, err := c.CollectDigits(ctx, CollectDigitsOptions{
+		MaxDigits: 2,
+		Validate:  regexp.MustCompile(`^\d+$`),
+	})
+	if !errors.Is(err, ErrInvalidDigits) || digits != "*9" {
+		t.Fatalf("Expected ErrInvalidDigits for *9, got %q, %v", digits, err)
+	}
+}
+
+func TestCollectDigitsTerminatorOnly(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	next := make(chan bool)
+
+	// Server
+	go func() {
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "#"))
+		<-next
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "1"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "2"))
+		sess.SendEvent(esltest.NewEvent("DTMF", "DTMF-Digit", "3"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	digits, err := c.CollectDigits(ctx, CollectDigitsOptions{Terminators: "#", Timeout: time.Second})
+	if err != nil || digits != "" {
+		t.Fatalf("Expected empty digits, got %q, %v", digits, err)
+	}
+
+	// Validation is not satisfied with partial match
+	time.AfterFunc(100*time.Millisecond, func() { close(next) })
+
+	digits, err = c.CollectDigits(ctx, CollectDigitsOptions{MaxDigits: 3, Validate: regexp.MustCompile(`\d{2}`)})
+	if !errors.Is(err, ErrInvalidDigits) || digits != "123" {
+		t.Fatalf("Expected ErrInvalidDigits for 123, got %q, %v", digits, err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// EventHandler - Func called with every event handler was registered for
+type EventHandler func(msg *Message)
+
+// Subscription - Returned by OnEvent & co. Use it to unregister handler.
+type Subscription struct {
+	d   *dispatcher
+	reg *registration
+}
+
+// Unregister - Will stop calling handler. Events already queued for it are skipped.
+func (s *Subscription) Unregister() {
+	atomic.StoreInt32(&s.reg.removed, 1)
+
+	s.d.Lock()
+	defer s.d.Unlock()
+
+	for i, reg := range s.d.handlers {
+		if reg == s.reg {
+			s.d.handlers = append(s.d.handlers[:i:i], s.d.handlers[i+1:]...)
+			break
+		}
+	}
+}
+
+// registration - Handler together with what it was registered for
+type registration struct {
+	match   func(msg *Message) bool
+	handler EventHandler
+	removed int32
+}
+
+// dispatch - Event together with handlers that should be called with it
+type dispatch struct {
+	msg  *Message
+	regs []*registration
+}
+
+// dispatcher - Calls registered handlers from a fixed set of workers. Events are assigned to workers by channel
+// uuid so that events of the same channel are handled one after another while different channels run in parallel.
+type dispatcher struct {
+	sync.Mutex
+	handlers    []*registration
+	concurrency int
+	workers     []chan dispatch
+	drop        bool
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{concurrency: DefaultHandlerConcurrency}
+}
+
+// SetHandlerConcurrency - Will set how many channels can be handled in parallel. Events of the same channel are
+// always handled one after another. Must be called before Handle().
+func (c *SocketConnection) SetHandlerConcurrency(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	c.d.Lock()
+	c.d.concurrency = workers
+	c.d.Unlock()
+}
+
+// SetDropUnhandled - Will drop events no handler matched, replies to Send and -ERR's nobody waits on instead of
+// handing them to ReadMsg. Use it when connection is consumed by handlers (and SendApi & co) only, otherwise ReadMsg
+// must keep on being read or handlers stop being called once unhandled messages pile up.
+func (c *SocketConnection) SetDropUnhandled(drop bool) {
+	c.d.Lock()
+	c.d.drop = drop
+	c.d.Unlock()
+}
+
+// dropsUnhandled - Will tell whether messages nobody takes should be dropped
+func (d *dispatcher) dropsUnhandled() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.drop
+}
+
+// OnEvent - Will call handler for every event with given name (e.g. CHANNEL_ANSWER). Events no handler matches go
+// to ReadMsg, which has to keep on being read unless SetDropUnhandled(true) was called.
+func (c *SocketConnection) OnEvent(name string, handler EventHandler) *Subscription {
+	return c.d.register(func(msg *Message) bool {
+		return msg.header("Event-Name") == name
+	}, handler)
+}
+
+// OnCustom - Will call handler for every CUSTOM event with given subclass (e.g. sofia::register)
+func (c *SocketConnection) OnCustom(subclass string, handler EventHandler) *Subscription {
+	return c.d.register(func(msg *Message) bool {
+		return msg.header("Event-Name") == "CUSTOM" && msg.header("Event-Subclass") == subclass
+	}, handler)
+}
+
+// OnAny - Will call handler for every event
+func (c *SocketConnection) OnAny(handler EventHandler) *Subscription {
+	return c.d.register(func(msg *Message) bool {
+		return true
+	}, handler)
+}
+
+// OnChannel - Will call handler for every event of channel with given uuid
+func (c *SocketConnection) OnChannel(uuid string, handler EventHandler) *Subscription {
+	return c.d.register(func(msg *Message) bool {
+		return msg.header("Unique-ID") == uuid
+	}, handler)
+}
+
+func (d *dispatcher) register(match func(msg *Message) bool, handler EventHandler) *Subscription {
+	reg := &registration{match: match, handler: handler}
+
+	d.Lock()
+	d.handlers = append(d.handlers, reg)
+	d.Unlock()
+
+	return &Subscription{d: d, reg: reg}
+}
+
+// dispatch - Will queue event for all of the handlers it matches. Returns false if no handler wanted it,
+// in which case event should be passed on to ReadMsg (or dropped, see SetDropUnhandled).
+func (c *SocketConnection) dispatch(msg *Message) bool {
+	if c.d == nil || !isEvent(msg) {
+		return false
+	}
+
+	d := c.d
+	d.Lock()
+
+	var regs []*registration
+	for _, reg := range d.handlers {
+		if reg.match(msg) {
+			regs = append(regs, reg)
+		}
+	}
+
+	if len(regs) == 0 {
+		d.Unlock()
+		return false
+	}
+
+	if d.workers == nil {
+		d.start()
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(msg.header("Unique-ID")))
+	worker := d.workers[h.Sum32()%uint32(len(d.workers))]
+
+	d.Unlock()
+
+	// Handlers share message among themselves
+	msg.share()
+
+	worker <- dispatch{msg: msg, regs: regs}
+
+	return true
+}
+
+// start - Will start workers. Must be called with lock held.
+func (d *dispatcher) start() {
+	d.workers = make([]chan dispatch, d.concurrency)
+
+	for i := range d.workers {
+		d.workers[i] = make(chan dispatch, 128)
+
+		go func(queue chan dispatch) {
+			for ev := range queue {
+				for _, reg := range ev.regs {
+					if atomic.LoadInt32(&reg.removed) == 0 {
+						callHandler(reg.handler, ev.msg)
+					}
+				}
+			}
+		}(d.workers[i])
+	}
+}
+
+// stop - Will stop workers once they're done with events already queued. Does not wait for them.
+func (d *dispatcher) stop() {
+	if d == nil {
+		return
+	}
+
+	d.Lock()
+	workers := d.workers
+	d.workers = nil
+	d.Unlock()
+
+	for _, queue := range workers {
+		close(queue)
+	}
+}
+
+// callHandler - Will call handler making sure its panic does not take down the whole connection
+func callHandler(handler EventHandler, msg *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			Error("Event handler panicked while handling %s: %v", msg.GetHeader("Event-Name"), r)
+		}
+	}()
+
+	msg.decode()
+	handler(msg)
+}
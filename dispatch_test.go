@@ -0,0 +1,280 @@
+package goesl
+
+import (
+	"hash/fnv"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func TestOnEvent(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	answers := make(chan string, 2)
+	customs := make(chan string, 1)
+
+	sub := c.OnEvent("CHANNEL_ANSWER", func(msg *Message) {
+		answers <- msg.GetHeader("Unique-ID")
+	})
+
+	c.OnCustom("sofia::register", func(msg *Message) {
+		customs <- msg.GetHeader("username")
+	})
+
+	go c.Handle()
+
+	go func() {
+		sess.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "a"))
+		sess.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "sofia::register", "username", "1000"))
+		sess.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "sofia::unregister", "username", "1000"))
+	}()
+
+	if uuid := <-answers; uuid != "a" {
+		t.Fatalf("Expected CHANNEL_ANSWER of a, got %q", uuid)
+	}
+
+	if username := <-customs; username != "1000" {
+		t.Fatalf("Expected sofia::register of 1000, got %q", username)
+	}
+
+	// Nobody registered for sofia::unregister so it must end up in ReadMsg
+	msg, err := c.ReadMsg()
+	if err != nil || msg.GetHeader("Event-Subclass") != "sofia::unregister" {
+		t.Fatalf("Expected unhandled event to go to ReadMsg, got %v, %v", msg, err)
+	}
+
+	sub.Unregister()
+
+	go sess.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "b"))
+
+	msg, err = c.ReadMsg()
+	if err != nil || msg.GetHeader("Unique-ID") != "b" {
+		t.Fatalf("Expected event to go to ReadMsg after Unregister, got %v, %v", msg, err)
+	}
+
+	select {
+	case uuid := <-answers:
+		t.Fatalf("Unregistered handler got called for %q", uuid)
+	default:
+	}
+}
+
+func TestOnChannelSerial(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	events := make(chan string, 10)
+
+	c.OnChannel("a", func(msg *Message) {
+		// Slow handler must not get events of the same channel out of order
+		time.Sleep(10 * time.Millisecond)
+		events <- msg.GetHeader("Event-Name")
+	})
+
+	go c.Handle()
+
+	names := []string{"CHANNEL_CREATE", "CHANNEL_ANSWER", "CHANNEL_BRIDGE", "CHANNEL_HANGUP"}
+
+	go func() {
+		for _, name := range names {
+			sess.SendEvent(esltest.NewEvent(name, "Unique-ID", "a"))
+		}
+	}()
+
+	for _, name := range names {
+		if got := <-events; got != name {
+			t.Fatalf("Expected %s, got %s", name, got)
+		}
+	}
+}
+
+func TestOnAnyParallel(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	c.SetHandlerConcurrency(4)
+
+	// Find channel uuid that lands on a different worker than a
+	worker := func(uuid string) uint32 {
+		h := fnv.New32a()
+		h.Write([]byte(uuid))
+		return h.Sum32() % 4
+	}
+
+	other := "b"
+	for i := 0; worker(other) == worker("a"); i++ {
+		other = string(rune('b' + i))
+	}
+
+	block := make(chan bool)
+	handled := make(chan string, 2)
+
+	c.OnAny(func(msg *Message) {
+		if msg.GetHeader("Unique-ID") == "a" {
+			<-block
+		}
+		handled <- msg.GetHeader("Unique-ID")
+	})
+
+	go c.Handle()
+
+	go func() {
+		sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "a"))
+		sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", other))
+	}()
+
+	select {
+	case uuid := <-handled:
+		if uuid != other {
+			t.Fatalf("Expected %s to be handled first, got %s", other, uuid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blocked handler of one channel stopped handling of other channels")
+	}
+
+	close(block)
+
+	if uuid := <-handled; uuid != "a" {
+		t.Fatalf("Expected a, got %s", uuid)
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	handled := make(chan bool, 1)
+
+	c.OnEvent("CHANNEL_CREATE", func(msg *Message) {
+		panic("handler bug")
+	})
+
+	c.OnEvent("CHANNEL_ANSWER", func(msg *Message) {
+		handled <- true
+	})
+
+	c.SetHandlerConcurrency(1)
+
+	go c.Handle()
+
+	go func() {
+		sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "a"))
+		sess.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "a"))
+	}()
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Panicking handler stopped event handling")
+	}
+}
+
+func TestHandlersOnly(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("status", "UP\n")
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Nobody ever calls ReadMsg on this connection
+	c.SetDropUnhandled(true)
+
+	answered := make(chan string, 1)
+	c.OnEvent("CHANNEL_ANSWER", func(msg *Message) {
+		answered <- msg.GetHeader("Unique-ID")
+	})
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replies to Send, -ERR's and unmatched events must not hold up handlers nor requests
+	if err := c.SendMany([]string{"event plain ALL", "bogus"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < DefaultEventQueueSize+10; i++ {
+		sess.SendEventAs(esltest.FormatPlain, esltest.NewEvent("HEARTBEAT"))
+	}
+
+	sess.SendEventAs(esltest.FormatPlain, esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "a"))
+
+	select {
+	case uuid := <-answered:
+		if uuid != "a" {
+			t.Fatalf("Expected CHANNEL_ANSWER of a, got %q", uuid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Unhandled messages stopped handlers from being called")
+	}
+
+	if m, err := c.SendApi("status"); err != nil || string(m.Body) != "UP\n" {
+		t.Fatalf("Expected api response, got %v, %v", m, err)
+	}
+}
+
+func TestOnEventFormats(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	answers := make(chan string, 3)
+	c.OnEvent("CHANNEL_ANSWER", func(msg *Message) {
+		answers <- msg.GetHeader("Unique-ID")
+	})
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formats := []string{esltest.FormatPlain, esltest.FormatJSON, esltest.FormatXML}
+
+	for _, format := range formats {
+		if err := sess.SendEventAs(format, esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", format)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Different channels are handled in parallel, in no particular order
+	handled := make(map[string]bool)
+	for range formats {
+		select {
+		case uuid := <-answers:
+			handled[uuid] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected CHANNEL_ANSWER sent as each of %v to be handled, got %v", formats, handled)
+		}
+	}
+
+	for _, format := range formats {
+		if !handled[format] {
+			t.Fatalf("CHANNEL_ANSWER sent as %s was not handled, got %v", format, handled)
+		}
+	}
+}
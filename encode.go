@@ -0,0 +1,262 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Encode - Will serialize message into ESL frame the way freeswitch frames it, so that parsing it gives back the same
+// message. Headers are written in HeaderNames() order with values percent encoded, Content-Length is computed out
+// of Body. Events (text/event-plain, text/event-json, text/event-xml) carry their headers and EventBody within the
+// frame body, in the format Content-Type says.
+func (m *Message) Encode() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := m.encode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (m *Message) encode(b *bytes.Buffer) error {
+	names := m.HeaderNames()
+
+	for _, name := range names {
+		if err := validateHeaderName(name); err != nil {
+			return err
+		}
+	}
+
+	contentType := m.GetHeader("Content-Type")
+
+	var body []byte
+
+	switch contentType {
+	case "text/event-plain":
+		body = m.encodeEventPlain(names)
+	case "text/event-json":
+		body = m.encodeEventJSON(names)
+	case "text/event-xml":
+		body = m.encodeEventXML(names)
+	default:
+		for _, name := range names {
+			if !strings.EqualFold(name, "Content-Length") {
+				writeHeader(b, name, encodeHeaderValue(m.GetHeader(name)))
+			}
+		}
+
+		if len(m.Body) > 0 {
+			writeHeader(b, "Content-Length", strconv.Itoa(len(m.Body)))
+		}
+
+		b.WriteString("\n")
+		b.Write(m.Body)
+
+		return nil
+	}
+
+	writeHeader(b, "Content-Length", strconv.Itoa(len(body)))
+	writeHeader(b, "Content-Type", contentType)
+	b.WriteString("\n")
+	b.Write(body)
+
+	return nil
+}
+
+// eventHeaders - Will return names of headers that belong to the event itself, not to the frame carrying it
+func eventHeaders(names []string) []string {
+	headers := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if !strings.EqualFold(name, "Content-Type") && !strings.EqualFold(name, "Content-Length") {
+			headers = append(headers, name)
+		}
+	}
+
+	return headers
+}
+
+// encodeEventPlain - Will write event headers followed by Content-Length of event body, if any, and the body
+func (m *Message) encodeEventPlain(names []string) []byte {
+	var b bytes.Buffer
+
+	body := m.EventBody()
+
+	for _, name := range eventHeaders(names) {
+		writeHeader(&b, name, encodeHeaderValue(m.GetHeader(name)))
+	}
+
+	if len(body) > 0 {
+		writeHeader(&b, "Content-Length", strconv.Itoa(len(body)))
+	}
+
+	b.WriteString("\n")
+	b.Write(body)
+
+	return b.Bytes()
+}
+
+// encodeEventJSON - Will write event as flat json object keeping header order. Body goes into _body.
+func (m *Message) encodeEventJSON(names []string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("{")
+
+	write := func(k, v string) {
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+
+		key, _ := json.Marshal(k)
+		value, _ := json.Marshal(v)
+
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+
+	for _, name := range eventHeaders(names) {
+		write(name, m.GetHeader(name))
+	}
+
+	if body := m.EventBody(); len(body) > 0 {
+		write("Content-Length", strconv.Itoa(len(body)))
+		write("_body", string(body))
+	}
+
+	b.WriteString("}")
+
+	return b.Bytes()
+}
+
+// encodeEventXML - Will write event as <event><headers>...</headers><body>...</body></event>
+func (m *Message) encodeEventXML(names []string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("<event>\n  <headers>\n")
+
+	write := func(k, v string) {
+		b.WriteString("    <" + k + ">")
+		xml.EscapeText(&b, []byte(v))
+		b.WriteString("</" + k + ">\n")
+	}
+
+	body := m.EventBody()
+
+	for _, name := range eventHeaders(names) {
+		write(name, m.GetHeader(name))
+	}
+
+	if len(body) > 0 {
+		write("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	b.WriteString("  </headers>\n")
+
+	if len(body) > 0 {
+		b.WriteString("  <body>")
+		xml.EscapeText(&b, body)
+		b.WriteString("</body>\n")
+	}
+
+	b.WriteString("</event>")
+
+	return b.Bytes()
+}
+
+// encodeCommand - Will serialize command we send to freeswitch: command line (api status, sendmsg <uuid>...)
+// followed by headers of m, if any, and its body with Content-Length computed out of it. Unlike Encode, values are
+// written as they are (freeswitch does not decode them) so anything that would break the frame is rejected.
+func encodeCommand(line string, m *Message) ([]byte, error) {
+	if err := validateValue(FieldCommand, line); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString(line)
+	b.WriteString("\n")
+
+	if m != nil {
+		for _, name := range m.HeaderNames() {
+			if strings.EqualFold(name, "Content-Length") {
+				continue
+			}
+
+			if err := validateHeaderName(name); err != nil {
+				return nil, err
+			}
+
+			if err := validateValue(FieldHeaderValue, m.GetHeader(name)); err != nil {
+				return nil, err
+			}
+
+			writeHeader(&b, name, m.GetHeader(name))
+		}
+
+		if len(m.Body) > 0 {
+			writeHeader(&b, "Content-Length", strconv.Itoa(len(m.Body)))
+		}
+	}
+
+	b.WriteString("\n")
+
+	if m != nil {
+		b.Write(m.Body)
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeHeader - Will write single header line
+func writeHeader(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(value)
+	b.WriteString("\n")
+}
+
+// encodeHeaderValue - Will percent encode header value the way freeswitch does (switch_url_encode), so that
+// decodeHeaderValue gives it back as it was
+func encodeHeaderValue(v string) string {
+	const unsafe = " <>#%{}|\\^~[]`'\";?:@=&+,"
+
+	n := 0
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; c < 0x20 || c >= 0x7f || strings.IndexByte(unsafe, c) >= 0 {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return v
+	}
+
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.Grow(len(v) + 2*n)
+
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; c < 0x20 || c >= 0x7f || strings.IndexByte(unsafe, c) >= 0 {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+			continue
+		}
+
+		b.WriteByte(v[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package goesl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	eventHeaders := [][2]string{
+		{"Event-Name", "BACKGROUND_JOB"},
+		{"Job-UUID", "7f4db78a-17d7-11dd-b7a0-db4edd065621"},
+		{"Job-Command", "originate"},
+		{"Job-Command-Arg", "sofia/default/1005 '&park'"},
+		{"Event-Date-Local", "2008-05-02 07:37:03"},
+		{"Caller-Caller-ID-Name", "José \"J\" <1000> 100% a+b"},
+		{"variable_sip_h_X-Tags", "a=1;b=2,c=[3]"},
+	}
+
+	tests := []struct {
+		name    string
+		headers [][2]string
+		body    string
+	}{
+		{"command/reply", [][2]string{{"Content-Type", "command/reply"}, {"Reply-Text", "+OK Job-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621"}}, ""},
+		{"api/response", [][2]string{{"Content-Type", "api/response"}}, "UP 0 years, 19 days\n1 session(s)\n"},
+		{"text/event-plain", append([][2]string{{"Content-Type", "text/event-plain"}}, eventHeaders...), "+OK 7f4db78a-17d7-11dd-b7a0-db4edd065621\n"},
+		{"text/event-plain without body", append([][2]string{{"Content-Type", "text/event-plain"}}, eventHeaders...), ""},
+		{"text/event-json", append([][2]string{{"Content-Type", "text/event-json"}}, eventHeaders...), "<b>body</b> & \"quotes\"\n"},
+		{"text/event-xml", append([][2]string{{"Content-Type", "text/event-xml"}}, eventHeaders...), "<b>body</b> & more\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{Headers: make(map[string]string), Body: []byte(tt.body)}
+			for _, h := range tt.headers {
+				m.setHeader(headerField{name: h[0], key: h[0], raw: h[1]})
+			}
+
+			b, err := m.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed := newMessage(reader(string(b)), MessageLimits{})
+			if err := parsed.Parse(); err != nil {
+				t.Fatalf("Could not parse %q: %s", b, err)
+			}
+
+			if string(parsed.EventBody()) != tt.body {
+				t.Fatalf("Expected body %q, got %q", tt.body, parsed.EventBody())
+			}
+
+			// Received event encodes its event body, not the frame body carrying it
+			if tt.name == "text/event-xml" {
+				again, err := parsed.Encode()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				reparsed := newMessage(reader(string(again)), MessageLimits{})
+				if err := reparsed.Parse(); err != nil || string(reparsed.EventBody()) != tt.body {
+					t.Fatalf("Unexpected re-encoded event %q: %v", again, err)
+				}
+			}
+
+			var names []string
+			for _, name := range parsed.HeaderNames() {
+				if name != "Content-Type" && name != "Content-Length" {
+					names = append(names, name)
+				}
+			}
+
+			var expected []string
+			for _, h := range tt.headers {
+				if h[0] != "Content-Type" {
+					expected = append(expected, h[0])
+				}
+
+				if v := parsed.GetHeader(h[0]); h[0] != "Content-Type" && v != h[1] {
+					t.Fatalf("Expected %s to be %q, got %q in %q", h[0], h[1], v, b)
+				}
+			}
+
+			if strings.Join(names, ",") != strings.Join(expected, ",") {
+				t.Fatalf("Expected headers %v, got %v", expected, names)
+			}
+		})
+	}
+}
+
+func TestEncodeContentLength(t *testing.T) {
+	m := &Message{Headers: map[string]string{"Content-Type": "api/response", "Content-Length": "1"}, Body: []byte("+OK")}
+
+	b, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "Content-Type: api/response\nContent-Length: 3\n\n+OK" {
+		t.Fatalf("Unexpected frame %q", b)
+	}
+}
+
+func TestEncodeInvalidHeaderName(t *testing.T) {
+	for _, name := range []string{"", "Event Name", "Event-Name:", "Event\nName"} {
+		m := &Message{Headers: map[string]string{"Content-Type": "text/event-plain", name: "x"}}
+
+		if _, err := m.Encode(); err == nil {
+			t.Errorf("Expected header name %q to be rejected", name)
+		}
+
+		if _, err := encodeCommand("sendevent CUSTOM", m); err == nil {
+			t.Errorf("Expected header name %q to be rejected in command", name)
+		}
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	b, err := encodeCommand("api status", nil)
+	if err != nil || string(b) != "api status\n\n" {
+		t.Fatalf("Unexpected command %q (%v)", b, err)
+	}
+
+	m := &Message{Headers: map[string]string{"call-command": "execute", "execute-app-name": "playback", "content-length": "1"}, Body: []byte("ok\n")}
+
+	b, err = encodeCommand("sendmsg", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "sendmsg\ncall-command: execute\nexecute-app-name: playback\nContent-Length: 3\n\nok\n"
+	if string(b) != expected {
+		t.Fatalf("Expected %q, got %q", expected, b)
+	}
+
+	for _, line := range []string{"api status\n", "api status\r"} {
+		if _, err := encodeCommand(line, nil); err == nil {
+			t.Errorf("Expected command %q to be rejected", line)
+		}
+	}
+
+	if _, err := encodeCommand("sendmsg", &Message{Headers: map[string]string{"execute-app-arg": "a\nb"}}); err == nil {
+		t.Error("Expected header value with new line to be rejected")
+	}
+}
+
+func FuzzEncodeHeaderValue(f *testing.F) {
+	for _, seed := range []string{"plain", "a+b c", "100%", "José \"J\"", "\r\n\x00", "%20"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, v string) {
+		encoded := encodeHeaderValue(v)
+
+		if strings.ContainsAny(encoded, "\r\n :") {
+			t.Fatalf("Encoded value %q can break the frame", encoded)
+		}
+
+		if decoded := decodeHeaderValue([]byte(encoded)); decoded != v {
+			t.Fatalf("Expected %q to round trip, got %q", v, decoded)
+		}
+	})
+}
@@ -6,8 +6,13 @@
 
 package goesl
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 var (
-	EInvalidCommandProvided  = "Invalid command provided. Command cannot contain \\r and/or \\n. Provided command is: %s"
 	ECouldNotReadMIMEHeaders = "Error while reading MIME headers: %s"
 	EInvalidContentLength    = "Unable to get size of content-length: %s"
 	EUnsuccessfulReply       = "Got error while reading from reply command: %s"
@@ -20,5 +25,91 @@ var (
 	EUnexpectedAuthHeader    = "Expected auth/request content type. Got %s"
 	EInvalidPassword         = "Could not authenticate against freeswitch with provided password: %s"
 	ECouldNotCreateMessage   = "Error while creating new message: %s"
-	ECouldNotSendEvent       = "Must send at least one event header, detected `%d` header"
+	ENotAnEvent              = "Message is not an event (no Event-Name header). Content type is: %s"
+	ECouldNotParseShow       = "Could not parse output of show %s: %s"
+	ENotACallcenterEvent     = "Expected CUSTOM callcenter::info event. Got %s %s"
+	ELimitExceeded           = "Message %s of %d exceeds limit of %d"
+	EInjectionAttempt        = "Invalid %s provided. It cannot contain \\r, \\n and/or \\x00 nor anything else that would break ESL command. Provided value is: %q"
 )
+
+// Kept for compatibility only, nothing returns them anymore
+var (
+	// Deprecated: Invalid values are reported with *InjectionError (EInjectionAttempt).
+	EInvalidCommandProvided = "Invalid command provided. Command cannot contain \\r and/or \\n. Provided command is: %s"
+
+	// Deprecated: SendEvent takes OutgoingEvent, which can be sent without headers.
+	ECouldNotSendEvent = "Must send at least one event header, detected `%d` header"
+)
+
+var (
+	// ErrNoSuchChannel - Freeswitch replied that channel (uuid) we asked about does not exist
+	ErrNoSuchChannel = errors.New("no such channel")
+
+	// ErrChannelHangup - Channel hung up while we were waiting on it (e.g. for application to complete)
+	ErrChannelHangup = errors.New("channel hangup")
+
+	// ErrDigitTimeout - No digits were pressed before timeout
+	ErrDigitTimeout = errors.New("timed out waiting for digits")
+
+	// ErrInvalidDigits - Collected digits did not pass validation
+	ErrInvalidDigits = errors.New("invalid digits")
+
+	// ErrEventQueueOverflow - Events were not handled fast enough and connection was closed (OverflowDisconnect)
+	ErrEventQueueOverflow = errors.New("event queue overflow")
+
+	// ErrInvalidArgument - Argument cannot be passed to freeswitch as-is (contains new lines, spaces where a single word is expected...)
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrCommandInjection - Value would break out of ESL command it is part of (new line would start new header or
+	// command). Actual error is *InjectionError.
+	ErrCommandInjection = errors.New("command injection")
+
+	// ErrConnectionClosed - Connection went away (or Handle() stopped reading it) before request got its reply
+	ErrConnectionClosed = errors.New("connection closed")
+
+	// ErrPoolClosed - Request was made against ClientPool that was closed
+	ErrPoolClosed = errors.New("client pool closed")
+
+	// ErrNoSuchConference - Freeswitch replied that conference we asked about does not exist
+	ErrNoSuchConference = errors.New("no such conference")
+
+	// ErrNoSuchMember - Freeswitch replied that conference member we asked about does not exist
+	ErrNoSuchMember = errors.New("no such conference member")
+
+	// ErrNoSuchGateway - Freeswitch does not know sofia gateway we asked about
+	ErrNoSuchGateway = errors.New("no such gateway")
+
+	// ErrContentTooLarge - Received message announced Content-Length larger than allowed (MessageLimits)
+	ErrContentTooLarge = errors.New("content too large")
+
+	// ErrLimitExceeded - Received message crossed one of MessageLimits. Actual error is *LimitError.
+	ErrLimitExceeded = errors.New("message limit exceeded")
+)
+
+// ReplyError - Freeswitch replied with -ERR to command/reply or api/response. Reply holds the text after -ERR.
+type ReplyError struct {
+	Reply string
+}
+
+// Error - Keeps same representation as EUnsuccessfulReply
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf(EUnsuccessfulReply, e.Reply)
+}
+
+// Is - Makes errors.Is(err, ErrNoSuchChannel) & co. work against freeswitch replies
+func (e *ReplyError) Is(target error) bool {
+	reply := strings.ToLower(e.Reply)
+
+	switch target {
+	case ErrNoSuchChannel:
+		return strings.HasPrefix(reply, "no such channel")
+	case ErrNoSuchConference:
+		return strings.HasPrefix(reply, "conference ") && strings.HasSuffix(reply, " not found")
+	case ErrNoSuchMember:
+		return strings.HasPrefix(reply, "non-existant id") || strings.HasPrefix(reply, "non-existent id")
+	case ErrNoSuchGateway:
+		return strings.HasPrefix(reply, "invalid gateway")
+	}
+
+	return false
+}
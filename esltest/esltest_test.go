@@ -0,0 +1,262 @@
+package esltest_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl"
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func client(t *testing.T, srv *esltest.Server, password string) *goesl.Client {
+	t.Helper()
+
+	host, port := srv.HostPort()
+
+	c, err := goesl.NewClient(host, port, password, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	go c.Handle()
+
+	return c
+}
+
+func TestAuth(t *testing.T) {
+	srv := esltest.NewServer("secret")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	if _, err := goesl.NewClient(host, port, "ClueCon", 5); err == nil {
+		t.Fatal("Expected wrong password to be rejected")
+	}
+
+	client(t, srv, "secret")
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Session of the rejected client is never accepted
+	cmd, err := sess.NextCommand(time.Second)
+	if err != nil || cmd.Line != "auth secret" {
+		t.Fatalf("Expected auth command, got %+v, %v", cmd, err)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("uuid_exists abc", "true")
+	srv.HandleAPIFunc(func(cmd string) (string, bool) {
+		if strings.HasPrefix(cmd, "uuid_getvar ") {
+			return "_undef_", true
+		}
+		return "", false
+	})
+
+	c := client(t, srv, esltest.DefaultPassword)
+
+	ok, err := c.UUIDExists("abc")
+	if err != nil || !ok {
+		t.Fatalf("Expected channel to exist, got %v, %v", ok, err)
+	}
+
+	if _, ok, err := c.UUIDGetVar("abc", "foo"); err != nil || ok {
+		t.Fatalf("Expected variable to not be set, got %v, %v", ok, err)
+	}
+
+	if _, err := c.SendApi("reloadxml"); err == nil || !strings.Contains(err.Error(), "reloadxml Command not found!") {
+		t.Fatalf("Expected command not found error, got %v", err)
+	}
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"auth ClueCon", "api uuid_exists abc", "api uuid_getvar abc foo", "api reloadxml"} {
+		if cmd, err := sess.NextCommand(time.Second); err != nil || cmd.Line != expected {
+			t.Fatalf("Expected %q, got %+v, %v", expected, cmd, err)
+		}
+	}
+}
+
+func TestBgAPI(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("status", "UP 0 years, 0 days\n")
+
+	c := client(t, srv, esltest.DefaultPassword)
+
+	if err := c.Send("event json BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := c.ReadMsg(); err != nil || m.GetHeader("Reply-Text") != "+OK event listener enabled json" {
+		t.Fatalf("Unexpected reply %v, %v", m, err)
+	}
+
+	if err := c.BgApi("status"); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := c.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := c.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.GetHeader("Event-Name") != "BACKGROUND_JOB" || reply.GetHeader("Reply-Text") != "+OK Job-UUID: "+job.GetHeader("Job-UUID") {
+		t.Fatalf("Unexpected job event %v for reply %v", job, reply)
+	}
+
+	if string(job.EventBody()) != "UP 0 years, 0 days\n" || job.GetHeader("Job-Command") != "status" {
+		t.Fatalf("Unexpected job event %v", job)
+	}
+}
+
+func TestSendEvent(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	c := client(t, srv, esltest.DefaultPassword)
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "abc", "Caller-Caller-ID-Name", "John Doe <1000>")
+
+	for _, format := range []string{esltest.FormatPlain, esltest.FormatJSON, esltest.FormatXML} {
+		if err := sess.SendEventAs(format, e); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := c.ReadMsg()
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		if m.GetHeader("Event-Name") != "CHANNEL_ANSWER" || m.GetHeader("Caller-Caller-ID-Name") != "John Doe <1000>" || m.GetHeader("Unique-ID") != "abc" {
+			t.Fatalf("%s: unexpected event %v", format, m.Headers)
+		}
+	}
+
+	if err := sess.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := c.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetHeader("Content-Type") != "text/disconnect-notice" {
+		t.Fatalf("Expected disconnect notice, got %v", m.Headers)
+	}
+}
+
+func TestSendMsgHandler(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleCommand("sendmsg", func(s *esltest.Session, cmd esltest.Command) string {
+		if cmd.Args() != "abc" {
+			return "-ERR invalid session id [" + cmd.Args() + "]"
+		}
+		return "+OK"
+	})
+
+	c := client(t, srv, esltest.DefaultPassword)
+
+	if _, err := c.SendMsg(map[string]string{"call-command": "hangup"}, "abc", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.SendMsg(map[string]string{"call-command": "hangup"}, "xyz", ""); err == nil {
+		t.Fatal("Expected sendmsg to unknown channel to fail")
+	}
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skipping auth
+	sess.NextCommand(time.Second)
+
+	cmd, err := sess.NextCommand(time.Second)
+	if err != nil || cmd.Name() != "sendmsg" || cmd.Headers["call-command"] != "hangup" {
+		t.Fatalf("Unexpected command %+v, %v", cmd, err)
+	}
+}
+
+func TestDialOutbound(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	server, err := goesl.NewOutboundServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go server.Start()
+	defer server.Stop()
+
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	data := esltest.ChannelData("0dd4e4f7-36ed-a04d-a8f7-7aebb683af50")
+	data["Channel-Name"] = "sofia/internal/john@127.0.0.1"
+
+	var sess *esltest.Session
+	for i := 0; i < 50; i++ {
+		if sess, err = srv.DialOutbound(addr, data); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := <-server.Conns
+	defer conn.Close()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := conn.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.GetHeader("Event-Name") != "CHANNEL_DATA" || m.GetHeader("Channel-Name") != "sofia/internal/john@127.0.0.1" || m.GetCallUUID() != "0dd4e4f7-36ed-a04d-a8f7-7aebb683af50" {
+		t.Fatalf("Unexpected channel data %v", m.Headers)
+	}
+
+	if cmd, err := sess.NextCommand(time.Second); err != nil || cmd.Line != "connect" {
+		t.Fatalf("Expected connect, got %+v, %v", cmd, err)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package esltest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event formats client can subscribe to with `event <format> ...`
+const (
+	FormatPlain = "plain"
+	FormatJSON  = "json"
+	FormatXML   = "xml"
+)
+
+// Event - Event fake freeswitch sends to the client. Headers are written with Event-Name first and the rest
+// in sorted order so that frames are the same from run to run.
+type Event struct {
+	Name    string
+	Headers map[string]string
+	Body    string
+}
+
+// NewEvent - Will create event with given name and headers passed as key, value pairs
+func NewEvent(name string, headers ...string) Event {
+	e := Event{Name: name, Headers: make(map[string]string, len(headers)/2)}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		e.Headers[headers[i]] = headers[i+1]
+	}
+
+	return e
+}
+
+// fields - Will return event headers in the order they are written out. Content-Length is added for events
+// with body, same as freeswitch does.
+func (e Event) fields() [][2]string {
+	keys := make([]string, 0, len(e.Headers))
+	for k := range e.Headers {
+		if k != "Event-Name" && k != "Content-Length" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	fields := [][2]string{{"Event-Name", e.Name}}
+	for _, k := range keys {
+		fields = append(fields, [2]string{k, e.Headers[k]})
+	}
+
+	if e.Body != "" {
+		fields = append(fields, [2]string{"Content-Length", strconv.Itoa(len(e.Body))})
+	}
+
+	return fields
+}
+
+// Plain - Will return event as text/event-plain frame. Header values are url encoded.
+func (e Event) Plain() string {
+	var b strings.Builder
+
+	for _, f := range e.fields() {
+		b.WriteString(f[0] + ": " + urlEncode(f[1]) + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(e.Body)
+
+	return frame("text/event-plain", b.String())
+}
+
+// JSON - Will return event as text/event-json frame. Body is carried in _body.
+func (e Event) JSON() string {
+	m := make(map[string]string, len(e.Headers)+2)
+
+	for _, f := range e.fields() {
+		m[f[0]] = f[1]
+	}
+
+	if e.Body != "" {
+		m["_body"] = e.Body
+	}
+
+	b, _ := json.Marshal(m)
+
+	return frame("text/event-json", string(b))
+}
+
+// XML - Will return event as text/event-xml frame
+func (e Event) XML() string {
+	var b strings.Builder
+
+	b.WriteString("<event>\n  <headers>\n")
+
+	for _, f := range e.fields() {
+		b.WriteString("    <" + f[0] + ">")
+		xml.EscapeText(&b, []byte(f[1]))
+		b.WriteString("</" + f[0] + ">\n")
+	}
+
+	b.WriteString("  </headers>\n")
+
+	if e.Body != "" {
+		b.WriteString("  <body>")
+		xml.EscapeText(&b, []byte(e.Body))
+		b.WriteString("</body>\n")
+	}
+
+	b.WriteString("</event>")
+
+	return frame("text/event-xml", b.String())
+}
+
+// Frame - Will return event in given format (FormatPlain, FormatJSON or FormatXML)
+func (e Event) Frame(format string) (string, error) {
+	switch format {
+	case FormatPlain, "":
+		return e.Plain(), nil
+	case FormatJSON:
+		return e.JSON(), nil
+	case FormatXML:
+		return e.XML(), nil
+	}
+
+	return "", fmt.Errorf("unknown event format %q", format)
+}
+
+// frame - Will build ESL frame of given content type around body
+func frame(contentType string, body string) string {
+	return "Content-Length: " + strconv.Itoa(len(body)) + "\nContent-Type: " + contentType + "\n\n" + body
+}
+
+// urlEncode - Will encode header value the way freeswitch does (switch_url_encode). Unlike url.QueryEscape
+// spaces become %20 and slashes are kept as-is.
+func urlEncode(v string) string {
+	const unsafe = " <>#%{}|\\^~[]`'\";?:@=&+,"
+
+	var b strings.Builder
+
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+
+		if c < 0x20 || c >= 0x7f || strings.IndexByte(unsafe, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package esltest
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChannelData - Will return synthetic data of inbound sofia channel with given uuid (random one when empty),
+// the way freeswitch replies to connect in outbound mode. Change or add whatever the test needs.
+func ChannelData(uuid string) map[string]string {
+	if uuid == "" {
+		uuid = newUUID()
+	}
+
+	return map[string]string{
+		"Event-Name":                "CHANNEL_DATA",
+		"Unique-ID":                 uuid,
+		"Channel-Name":              "sofia/internal/1000@127.0.0.1",
+		"Channel-State":             "CS_EXECUTE",
+		"Channel-Call-State":        "RINGING",
+		"Answer-State":              "ringing",
+		"Call-Direction":            "inbound",
+		"Caller-Direction":          "inbound",
+		"Caller-Username":           "1000",
+		"Caller-Dialplan":           "XML",
+		"Caller-Caller-ID-Name":     "1000",
+		"Caller-Caller-ID-Number":   "1000",
+		"Caller-Network-Addr":       "127.0.0.1",
+		"Caller-Destination-Number": "5000",
+		"Caller-Unique-ID":          uuid,
+		"Caller-Context":            "default",
+		"Caller-Channel-Name":       "sofia/internal/1000@127.0.0.1",
+		"Socket-Mode":               "async",
+		"Control":                   "full",
+		"variable_direction":        "inbound",
+		"variable_uuid":             uuid,
+		"variable_sip_from_user":    "1000",
+		"variable_sip_to_user":      "5000",
+	}
+}
+
+// DialOutbound - Will connect to outbound server listening on addr (e.g. goesl.OutboundServer) the way
+// freeswitch `socket` application does. Client's connect is replied with data (ChannelData("") when nil).
+// Session is served by this server's handlers and closed along with the server.
+func (s *Server) DialOutbound(addr string, data map[string]string) (*Session, error) {
+	if data == nil {
+		data = ChannelData("")
+	}
+
+	if data["Unique-ID"] == "" {
+		data["Unique-ID"] = newUUID()
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := s.newSession(conn, true)
+	if sess == nil {
+		return nil, net.ErrClosed
+	}
+
+	sess.data = data
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer sess.Close()
+		sess.serve()
+	}()
+
+	return sess, nil
+}
+
+// connect - Will reply to connect with channel data. Keys are sorted so that reply is the same from run to run.
+func (s *Session) connect() error {
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	b.WriteString("Content-Type: command/reply\nReply-Text: +OK\n")
+
+	for _, k := range keys {
+		b.WriteString(k + ": " + urlEncode(s.data[k]) + "\n")
+	}
+
+	b.WriteString("\n")
+
+	return s.SendRaw(b.String())
+}
@@ -0,0 +1,222 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+// Package esltest provides fake freeswitch event socket for testing ESL clients. Server plays freeswitch in
+// inbound mode (clients connect and authenticate against it) while Server.DialOutbound plays freeswitch in
+// outbound mode (connects into goesl.OutboundServer the way `socket` dialplan application does).
+package esltest
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPassword - Password freeswitch event socket comes configured with
+const DefaultPassword = "ClueCon"
+
+// ErrTimeout - Nothing happened within given timeout
+var ErrTimeout = errors.New("esltest: timeout")
+
+// APIHandler - Will return response to api command (e.g. "uuid_exists abc"). Returning false lets next handler,
+// or default "-ERR <command> Command not found!" response, take over.
+type APIHandler func(cmd string) (response string, ok bool)
+
+// CommandHandler - Will return Reply-Text of command/reply to the command (e.g. "+OK" or "-ERR no such channel")
+type CommandHandler func(s *Session, cmd Command) string
+
+// Server - Fake freeswitch listening for inbound ESL connections on localhost
+type Server struct {
+	Password string
+
+	listener net.Listener
+
+	mtx      sync.Mutex
+	api      map[string]string
+	apiFuncs []APIHandler
+	commands map[string]CommandHandler
+	sessions []*Session
+	closed   bool
+
+	accepted chan *Session
+	wg       sync.WaitGroup
+}
+
+// NewServer - Will start fake freeswitch on random localhost port. Empty password means DefaultPassword.
+// Panics in case it cannot listen, same as httptest.NewServer does.
+func NewServer(password string) *Server {
+	if password == "" {
+		password = DefaultPassword
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("esltest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		Password: password,
+		listener: l,
+		api:      make(map[string]string),
+		commands: make(map[string]CommandHandler),
+		accepted: make(chan *Session, 64),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Addr - Will return host:port server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// HostPort - Will return host and port server listens on, handy for goesl.NewClient
+func (s *Server) HostPort() (string, uint) {
+	host, port, _ := net.SplitHostPort(s.Addr())
+	p, _ := strconv.Atoi(port)
+
+	return host, uint(p)
+}
+
+// HandleAPI - Will respond to api (and bgapi) command matching cmd exactly with response
+func (s *Server) HandleAPI(cmd string, response string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.api[cmd] = response
+}
+
+// HandleAPIFunc - Will ask fn for response to api (and bgapi) commands not handled by HandleAPI
+func (s *Server) HandleAPIFunc(fn APIHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.apiFuncs = append(s.apiFuncs, fn)
+}
+
+// HandleCommand - Will use fn to reply to the command with given name (e.g. sendmsg, sendevent, linger)
+// instead of built-in reply
+func (s *Server) HandleCommand(name string, fn CommandHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.commands[name] = fn
+}
+
+// Accept - Will return next session that got authenticated. Sessions dialed with DialOutbound are not returned here.
+func (s *Server) Accept(timeout time.Duration) (*Session, error) {
+	select {
+	case sess := <-s.accepted:
+		return sess, nil
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	}
+}
+
+// Close - Will stop listening and close all of the sessions
+func (s *Server) Close() {
+	s.mtx.Lock()
+	s.closed = true
+	sessions := s.sessions
+	s.sessions = nil
+	s.mtx.Unlock()
+
+	s.listener.Close()
+
+	for _, sess := range sessions {
+		sess.Close()
+	}
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		sess := s.newSession(conn, false)
+		if sess == nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sess.serveInbound()
+		}()
+	}
+}
+
+// newSession - Will create and keep track of new session. Nil is returned once server got closed.
+func (s *Server) newSession(conn net.Conn, outbound bool) *Session {
+	sess := newSession(s, conn, outbound)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		conn.Close()
+		return nil
+	}
+
+	s.sessions = append(s.sessions, sess)
+
+	return sess
+}
+
+// removeSession - Will forget session once its connection got closed
+func (s *Server) removeSession(sess *Session) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, v := range s.sessions {
+		if v == sess {
+			s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// apiResponse - Will return response to api command out of registered handlers
+func (s *Server) apiResponse(cmd string) string {
+	s.mtx.Lock()
+	resp, ok := s.api[cmd]
+	funcs := append([]APIHandler(nil), s.apiFuncs...)
+	s.mtx.Unlock()
+
+	if ok {
+		return resp
+	}
+
+	for _, fn := range funcs {
+		if resp, ok := fn(cmd); ok {
+			return resp
+		}
+	}
+
+	name := strings.SplitN(cmd, " ", 2)[0]
+
+	return "-ERR " + name + " Command not found!\n"
+}
+
+// commandHandler - Will return handler registered for command, if any
+func (s *Server) commandHandler(name string) CommandHandler {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.commands[name]
+}
@@ -0,0 +1,51 @@
+package esltest
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func (s *Server) sessionCount() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.sessions)
+}
+
+func TestServerForgetsClosedSessions(t *testing.T) {
+	srv := NewServer("")
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", srv.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := bufio.NewReader(conn)
+		if _, err := r.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Write([]byte("auth " + DefaultPassword + "\n\n"))
+
+		sess, err := srv.Accept(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Close()
+
+		select {
+		case <-sess.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Expected session to be closed once client went away")
+		}
+	}
+
+	if n := srv.sessionCount(); n != 0 {
+		t.Fatalf("Expected closed sessions to be removed, %d left", n)
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package esltest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DisconnectNotice - Body of text/disconnect-notice freeswitch sends before closing the connection
+const DisconnectNotice = "Disconnected, goodbye.\nSee you at ClueCon! http://www.cluecon.com/\n"
+
+// Command - Single command client sent. Line is the first line (e.g. "api uuid_exists abc"), Headers are lines
+// following it (sendmsg, sendevent, bgapi Job-UUID...) and Body is whatever Content-Length header announced.
+type Command struct {
+	Line    string
+	Headers map[string]string
+	Body    []byte
+}
+
+// Name - Will return command name (first word of the line)
+func (c Command) Name() string {
+	return strings.SplitN(c.Line, " ", 2)[0]
+}
+
+// Args - Will return everything after command name
+func (c Command) Args() string {
+	parts := strings.SplitN(c.Line, " ", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// Session - Single client connection fake freeswitch is serving. Commands client sent are recorded and can be
+// read with NextCommand.
+type Session struct {
+	srv      *Server
+	conn     net.Conn
+	r        *bufio.Reader
+	outbound bool
+	data     map[string]string
+
+	wmtx sync.Mutex
+
+	mtx    sync.Mutex
+	format string
+	events map[string]bool
+	cmds   []Command
+
+	cmdReady chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newSession(srv *Server, conn net.Conn, outbound bool) *Session {
+	return &Session{
+		srv:      srv,
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		outbound: outbound,
+		events:   make(map[string]bool),
+		cmdReady: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Outbound - Will check if session was dialed with DialOutbound
+func (s *Session) Outbound() bool {
+	return s.outbound
+}
+
+// ChannelData - Will return channel data outbound session replies to connect with
+func (s *Session) ChannelData() map[string]string {
+	return s.data
+}
+
+// Format - Will return event format client subscribed with (plain, json or xml). Plain until client subscribes.
+func (s *Session) Format() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.format == "" {
+		return FormatPlain
+	}
+
+	return s.format
+}
+
+// Subscribed - Will check if client subscribed to the event, either by its name or with ALL
+func (s *Session) Subscribed(name string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.events[name] || s.events["ALL"]
+}
+
+// NextCommand - Will return next command client sent, waiting up to timeout for it. io.EOF is returned once
+// session is closed and all of the commands were read.
+func (s *Session) NextCommand(timeout time.Duration) (Command, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mtx.Lock()
+		if len(s.cmds) > 0 {
+			cmd := s.cmds[0]
+			s.cmds = s.cmds[1:]
+			s.mtx.Unlock()
+			return cmd, nil
+		}
+		s.mtx.Unlock()
+
+		select {
+		case <-s.cmdReady:
+		case <-s.done:
+			s.mtx.Lock()
+			empty := len(s.cmds) == 0
+			s.mtx.Unlock()
+
+			if empty {
+				return Command{}, io.EOF
+			}
+		case <-deadline.C:
+			return Command{}, ErrTimeout
+		}
+	}
+}
+
+// SendEvent - Will send event in the format client subscribed with
+func (s *Session) SendEvent(e Event) error {
+	return s.SendEventAs(s.Format(), e)
+}
+
+// SendEventAs - Will send event in given format (FormatPlain, FormatJSON or FormatXML) regardless of subscription
+func (s *Session) SendEventAs(format string, e Event) error {
+	f, err := e.Frame(format)
+	if err != nil {
+		return err
+	}
+
+	return s.SendRaw(f)
+}
+
+// SendRaw - Will write frame to the client as-is. Use it to simulate anything not covered by other helpers.
+func (s *Session) SendRaw(frame string) error {
+	s.wmtx.Lock()
+	defer s.wmtx.Unlock()
+
+	_, err := io.WriteString(s.conn, frame)
+	return err
+}
+
+// SendDisconnectNotice - Will send text/disconnect-notice the way freeswitch does when it is about to hang up on us
+func (s *Session) SendDisconnectNotice() error {
+	return s.SendRaw("Content-Type: text/disconnect-notice\nContent-Disposition: disconnect\nContent-Length: " +
+		strconv.Itoa(len(DisconnectNotice)) + "\n\n" + DisconnectNotice)
+}
+
+// Disconnect - Will send disconnect notice and close the session
+func (s *Session) Disconnect() error {
+	err := s.SendDisconnectNotice()
+	s.Close()
+
+	return err
+}
+
+// Close - Will close connection with the client
+func (s *Session) Close() error {
+	var err error
+
+	s.once.Do(func() {
+		err = s.conn.Close()
+		s.srv.removeSession(s)
+		close(s.done)
+	})
+
+	return err
+}
+
+// Done - Will return channel closed once session is closed
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// reply - Will send command/reply with given Reply-Text and extra headers (key, value pairs)
+func (s *Session) reply(text string, headers ...string) error {
+	var b strings.Builder
+
+	b.WriteString("Content-Type: command/reply\nReply-Text: " + text + "\n")
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		b.WriteString(headers[i] + ": " + headers[i+1] + "\n")
+	}
+
+	b.WriteString("\n")
+
+	return s.SendRaw(b.String())
+}
+
+// apiResponse - Will send api/response with given body
+func (s *Session) apiResponse(body string) error {
+	return s.SendRaw("Content-Type: api/response\nContent-Length: " + strconv.Itoa(len(body)) + "\n\n" + body)
+}
+
+// readCommand - Will read next command. Commands are a line followed by optional headers and end with empty line.
+func (s *Session) readCommand() (Command, error) {
+	cmd := Command{Headers: make(map[string]string)}
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return cmd, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// Clients sending \r\n\r\n after every command would otherwise produce empty commands
+			if cmd.Line == "" {
+				continue
+			}
+			break
+		}
+
+		if cmd.Line == "" {
+			cmd.Line = line
+			continue
+		}
+
+		if i := strings.Index(line, ":"); i > 0 {
+			cmd.Headers[line[:i]] = strings.TrimLeft(line[i+1:], " ")
+		}
+	}
+
+	if l, err := strconv.Atoi(cmd.Headers["Content-Length"]); err == nil && l > 0 {
+		cmd.Body = make([]byte, l)
+		if _, err := io.ReadFull(s.r, cmd.Body); err != nil {
+			return cmd, err
+		}
+	}
+
+	return cmd, nil
+}
+
+// record - Will remember command so that it can be read with NextCommand
+func (s *Session) record(cmd Command) {
+	s.mtx.Lock()
+	s.cmds = append(s.cmds, cmd)
+	s.mtx.Unlock()
+
+	select {
+	case s.cmdReady <- struct{}{}:
+	default:
+	}
+}
+
+// serveInbound - Will authenticate the client and then serve its commands
+func (s *Session) serveInbound() {
+	defer s.Close()
+
+	if err := s.SendRaw("Content-Type: auth/request\n\n"); err != nil {
+		return
+	}
+
+	cmd, err := s.readCommand()
+	if err != nil {
+		return
+	}
+
+	s.record(cmd)
+
+	if cmd.Name() != "auth" || cmd.Args() != s.srv.Password {
+		s.reply("-ERR invalid")
+		s.SendDisconnectNotice()
+		return
+	}
+
+	if err := s.reply("+OK accepted"); err != nil {
+		return
+	}
+
+	select {
+	case s.srv.accepted <- s:
+	case <-s.done:
+		return
+	}
+
+	s.serve()
+}
+
+// serve - Will read and reply to commands until client goes away or session is closed
+func (s *Session) serve() {
+	for {
+		cmd, err := s.readCommand()
+		if err != nil {
+			return
+		}
+
+		s.record(cmd)
+
+		if !s.handle(cmd) {
+			return
+		}
+	}
+}
+
+// handle - Will reply to command. False is returned once session should be closed.
+func (s *Session) handle(cmd Command) bool {
+	name := cmd.Name()
+
+	if fn := s.srv.commandHandler(name); fn != nil {
+		return s.reply(fn(s, cmd)) == nil
+	}
+
+	var err error
+
+	switch name {
+	case "api":
+		err = s.apiResponse(s.srv.apiResponse(cmd.Args()))
+	case "bgapi":
+		err = s.bgapi(cmd)
+	case "event":
+		err = s.subscribe(cmd)
+	case "nixevent":
+		s.mtx.Lock()
+		for _, e := range strings.Fields(cmd.Args()) {
+			delete(s.events, e)
+		}
+		s.mtx.Unlock()
+		err = s.reply("+OK events nixed")
+	case "noevents":
+		s.mtx.Lock()
+		s.events = make(map[string]bool)
+		s.mtx.Unlock()
+		err = s.reply("+OK no longer listening for events")
+	case "myevents":
+		s.mtx.Lock()
+		if f := strings.Fields(cmd.Args()); len(f) > 0 && (f[len(f)-1] == FormatJSON || f[len(f)-1] == FormatXML) {
+			s.format = f[len(f)-1]
+		}
+		s.events["ALL"] = true
+		s.mtx.Unlock()
+		err = s.reply("+OK Events Enabled")
+	case "connect":
+		if !s.outbound {
+			err = s.reply("-ERR command not found")
+			break
+		}
+		err = s.connect()
+	case "sendevent":
+		err = s.reply("+OK " + newUUID())
+	case "sendmsg", "linger", "nolinger", "filter", "divert_events", "resume", "log", "nolog":
+		err = s.reply("+OK")
+	case "exit":
+		s.reply("+OK bye")
+		s.SendDisconnectNotice()
+		return false
+	default:
+		err = s.reply("-ERR command not found")
+	}
+
+	return err == nil
+}
+
+// bgapi - Will reply with Job-UUID and, in case client is subscribed to it, send BACKGROUND_JOB with api response
+func (s *Session) bgapi(cmd Command) error {
+	job := cmd.Headers["Job-UUID"]
+	if job == "" {
+		job = newUUID()
+	}
+
+	if err := s.reply("+OK Job-UUID: "+job, "Job-UUID", job); err != nil {
+		return err
+	}
+
+	if !s.Subscribed("BACKGROUND_JOB") {
+		return nil
+	}
+
+	e := NewEvent("BACKGROUND_JOB", "Job-UUID", job, "Job-Command", strings.SplitN(cmd.Args(), " ", 2)[0])
+	if parts := strings.SplitN(cmd.Args(), " ", 2); len(parts) == 2 {
+		e.Headers["Job-Command-Arg"] = parts[1]
+	}
+	e.Body = s.srv.apiResponse(cmd.Args())
+
+	return s.SendEvent(e)
+}
+
+// subscribe - Will handle `event <format> <names...>`
+func (s *Session) subscribe(cmd Command) error {
+	fields := strings.Fields(cmd.Args())
+	if len(fields) == 0 {
+		return s.reply("-ERR missing event format")
+	}
+
+	format := fields[0]
+	if format != FormatPlain && format != FormatJSON && format != FormatXML {
+		return s.reply("-ERR invalid event format " + format)
+	}
+
+	s.mtx.Lock()
+	s.format = format
+	for _, e := range fields[1:] {
+		s.events[e] = true
+	}
+	s.mtx.Unlock()
+
+	return s.reply("+OK event listener enabled " + format)
+}
+
+// newUUID - Will generate random (version 4) uuid for jobs and channels
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
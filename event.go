@@ -0,0 +1,382 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypedEvent - Implemented by Event and all of the concrete events DecodeEvent returns
+type TypedEvent interface {
+	Base() *Event
+}
+
+// Event - Fields every freeswitch event has. Message it was decoded from is kept for anything not covered here.
+type Event struct {
+	Name      string
+	Subclass  string
+	UUID      string
+	Timestamp time.Time
+	Sequence  uint64
+
+	Message *Message
+}
+
+// Base - Will return event itself so that concrete events embedding *Event satisfy TypedEvent
+func (e *Event) Base() *Event {
+	return e
+}
+
+// Header - Will return header of underlying message
+func (e *Event) Header(key string) string {
+	return e.Message.GetHeader(key)
+}
+
+// Variables - Will return channel variables (variable_* headers) carried by the event
+func (e *Event) Variables() Variables {
+	return channelVariables(e.Message)
+}
+
+// NewEvent - Will build Event out of message. Message must be an event e.g. have Event-Name header.
+func NewEvent(m *Message) (*Event, error) {
+	name := m.GetHeader("Event-Name")
+	if name == "" {
+		return nil, fmt.Errorf(ENotAnEvent, m.GetHeader("Content-Type"))
+	}
+
+	e := &Event{
+		Name:     name,
+		Subclass: m.GetHeader("Event-Subclass"),
+		UUID:     m.GetHeader("Unique-ID"),
+		Message:  m,
+	}
+
+	if ts, err := strconv.ParseInt(m.GetHeader("Event-Date-Timestamp"), 10, 64); err == nil {
+		e.Timestamp = time.UnixMicro(ts)
+	}
+
+	if seq, err := strconv.ParseUint(m.GetHeader("Event-Sequence"), 10, 64); err == nil {
+		e.Sequence = seq
+	}
+
+	return e, nil
+}
+
+// Variables - Channel variables with variable_ prefix stripped and helpers to read them as typed values
+type Variables map[string]string
+
+// Get - Will return variable value or "" if variable is not set
+func (v Variables) Get(name string) string {
+	return v[name]
+}
+
+// Bool - Will return true for the same values freeswitch considers true (switch_true: true, t, yes, on, enabled,
+// active, allow or number with non zero integer part)
+func (v Variables) Bool(name string) bool {
+	value := v[name]
+
+	switch strings.ToLower(value) {
+	case "true", "t", "yes", "on", "enabled", "active", "allow":
+		return true
+	}
+
+	// Number is optional sign followed by digits and dots, only its integer part counts (atoi)
+	number := strings.TrimLeft(value, "+-")
+	if len(value)-len(number) > 1 {
+		return false
+	}
+
+	for _, c := range number {
+		if c != '.' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		number = number[:i]
+	}
+
+	return strings.Trim(number, "0") != ""
+}
+
+// Int - Will return variable as integer
+func (v Variables) Int(name string) (int64, error) {
+	return strconv.ParseInt(v[name], 10, 64)
+}
+
+// Float - Will return variable as float
+func (v Variables) Float(name string) (float64, error) {
+	return strconv.ParseFloat(v[name], 64)
+}
+
+// Seconds - Will return variable holding number of seconds (billsec, duration...) as duration
+func (v Variables) Seconds(name string) (time.Duration, error) {
+	i, err := v.Int(name)
+	return time.Duration(i) * time.Second, err
+}
+
+// Time - Will return variable holding unix time as time. Variables ending with _uepoch are in microseconds,
+// everything else is expected to be in seconds (_epoch). 0 is returned as zero time.
+func (v Variables) Time(name string) (time.Time, error) {
+	i, err := v.Int(name)
+	if err != nil || i == 0 {
+		return time.Time{}, err
+	}
+
+	if strings.HasSuffix(name, "_uepoch") {
+		return time.UnixMicro(i), nil
+	}
+
+	return time.Unix(i, 0), nil
+}
+
+// CallerProfile - Caller profile freeswitch attaches to channel events. Prefix tells which profile it is
+// (Caller, Other-Leg, Originator, Originatee...)
+type CallerProfile struct {
+	Direction         string
+	Username          string
+	Dialplan          string
+	CallerIDName      string
+	CallerIDNumber    string
+	CalleeIDName      string
+	CalleeIDNumber    string
+	ANI               string
+	NetworkAddr       string
+	DestinationNumber string
+	UniqueID          string
+	Source            string
+	Context           string
+	ChannelName       string
+	ProfileIndex      string
+}
+
+// NewCallerProfile - Will read caller profile with given prefix (e.g. Caller) out of message headers
+func NewCallerProfile(m *Message, prefix string) CallerProfile {
+	h := func(name string) string {
+		return m.GetHeader(prefix + "-" + name)
+	}
+
+	return CallerProfile{
+		Direction:         h("Direction"),
+		Username:          h("Username"),
+		Dialplan:          h("Dialplan"),
+		CallerIDName:      h("Caller-ID-Name"),
+		CallerIDNumber:    h("Caller-ID-Number"),
+		CalleeIDName:      h("Callee-ID-Name"),
+		CalleeIDNumber:    h("Callee-ID-Number"),
+		ANI:               h("ANI"),
+		NetworkAddr:       h("Network-Addr"),
+		DestinationNumber: h("Destination-Number"),
+		UniqueID:          h("Unique-ID"),
+		Source:            h("Source"),
+		Context:           h("Context"),
+		ChannelName:       h("Channel-Name"),
+		ProfileIndex:      h("Profile-Index"),
+	}
+}
+
+// ChannelEvent - Fields shared by all of the CHANNEL_* events
+type ChannelEvent struct {
+	*Event
+
+	ChannelName      string
+	ChannelState     string
+	ChannelCallState string
+	AnswerState      string
+	CallDirection    string
+	Caller           CallerProfile
+	Vars             Variables
+}
+
+func newChannelEvent(e *Event) ChannelEvent {
+	return ChannelEvent{
+		Event:            e,
+		ChannelName:      e.Header("Channel-Name"),
+		ChannelState:     e.Header("Channel-State"),
+		ChannelCallState: e.Header("Channel-Call-State"),
+		AnswerState:      e.Header("Answer-State"),
+		CallDirection:    e.Header("Call-Direction"),
+		Caller:           NewCallerProfile(e.Message, "Caller"),
+		Vars:             e.Variables(),
+	}
+}
+
+// ChannelCreateEvent - CHANNEL_CREATE
+type ChannelCreateEvent struct {
+	ChannelEvent
+}
+
+// ChannelAnswerEvent - CHANNEL_ANSWER
+type ChannelAnswerEvent struct {
+	ChannelEvent
+}
+
+// ChannelBridgeEvent - CHANNEL_BRIDGE and CHANNEL_UNBRIDGE. OtherLegUUID is uuid of the channel we got bridged
+// with (or unbridged from).
+type ChannelBridgeEvent struct {
+	ChannelEvent
+
+	OtherLegUUID string
+	OtherLeg     CallerProfile
+}
+
+// ChannelUnbridgeEvent - CHANNEL_UNBRIDGE
+type ChannelUnbridgeEvent struct {
+	ChannelBridgeEvent
+}
+
+// ChannelHangupEvent - CHANNEL_HANGUP
+type ChannelHangupEvent struct {
+	ChannelEvent
+
+	HangupCause string
+}
+
+// ChannelHangupCompleteEvent - CHANNEL_HANGUP_COMPLETE. Duration and BillSec are taken from channel variables.
+type ChannelHangupCompleteEvent struct {
+	ChannelHangupEvent
+
+	Duration time.Duration
+	BillSec  time.Duration
+}
+
+// ChannelExecuteEvent - CHANNEL_EXECUTE
+type ChannelExecuteEvent struct {
+	ChannelEvent
+
+	Application     string
+	ApplicationData string
+	ApplicationUUID string
+}
+
+// ChannelExecuteCompleteEvent - CHANNEL_EXECUTE_COMPLETE
+type ChannelExecuteCompleteEvent struct {
+	ChannelExecuteEvent
+
+	ApplicationResponse string
+}
+
+// DTMFEvent - DTMF. Duration is in samples (8kHz), same as freeswitch reports it.
+type DTMFEvent struct {
+	ChannelEvent
+
+	Digit    string
+	Duration int
+	Source   string
+}
+
+// BackgroundJobEvent - BACKGROUND_JOB. Result is what api command returned (event body).
+type BackgroundJobEvent struct {
+	*Event
+
+	JobUUID       string
+	JobCommand    string
+	JobCommandArg string
+	Result        string
+}
+
+// CustomEvent - CUSTOM. Subclass tells what kind of custom event it is (e.g. sofia::register).
+type CustomEvent struct {
+	*Event
+}
+
+// DecodeEvent - Will decode message into one of the concrete events (e.g. *ChannelHangupEvent). Events without
+// a concrete type are returned as *Event. Works the same for plain, json and xml events.
+func DecodeEvent(m *Message) (TypedEvent, error) {
+	e, err := NewEvent(m)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Name {
+	case "CHANNEL_CREATE":
+		return &ChannelCreateEvent{newChannelEvent(e)}, nil
+	case "CHANNEL_ANSWER":
+		return &ChannelAnswerEvent{newChannelEvent(e)}, nil
+	case "CHANNEL_BRIDGE":
+		return newChannelBridgeEvent(e), nil
+	case "CHANNEL_UNBRIDGE":
+		return &ChannelUnbridgeEvent{*newChannelBridgeEvent(e)}, nil
+	case "CHANNEL_HANGUP":
+		return newChannelHangupEvent(e), nil
+	case "CHANNEL_HANGUP_COMPLETE":
+		ev := &ChannelHangupCompleteEvent{ChannelHangupEvent: *newChannelHangupEvent(e)}
+		ev.Duration, _ = ev.Vars.Seconds("duration")
+		ev.BillSec, _ = ev.Vars.Seconds("billsec")
+		return ev, nil
+	case "CHANNEL_EXECUTE":
+		return newChannelExecuteEvent(e), nil
+	case "CHANNEL_EXECUTE_COMPLETE":
+		return &ChannelExecuteCompleteEvent{
+			ChannelExecuteEvent: *newChannelExecuteEvent(e),
+			ApplicationResponse: e.Header("Application-Response"),
+		}, nil
+	case "DTMF":
+		duration, _ := strconv.Atoi(e.Header("DTMF-Duration"))
+		return &DTMFEvent{
+			ChannelEvent: newChannelEvent(e),
+			Digit:        e.Header("DTMF-Digit"),
+			Duration:     duration,
+			Source:       e.Header("DTMF-Source"),
+		}, nil
+	case "BACKGROUND_JOB":
+		return &BackgroundJobEvent{
+			Event:         e,
+			JobUUID:       e.Header("Job-UUID"),
+			JobCommand:    e.Header("Job-Command"),
+			JobCommandArg: e.Header("Job-Command-Arg"),
+			Result:        string(m.EventBody()),
+		}, nil
+	case "CUSTOM":
+		switch e.Subclass {
+		case CallcenterInfo:
+			return newCallcenterEvent(e), nil
+		case ValetParkingInfo:
+			return newValetEvent(e), nil
+		case FifoInfo:
+			return newFifoEvent(e), nil
+		case ConferenceMaintenance:
+			return newConferenceEvent(e), nil
+		}
+
+		return &CustomEvent{e}, nil
+	}
+
+	return e, nil
+}
+
+func newChannelBridgeEvent(e *Event) *ChannelBridgeEvent {
+	other := e.Header("Other-Leg-Unique-ID")
+	if other == "" {
+		other = e.Header("Bridge-B-Unique-ID")
+	}
+
+	return &ChannelBridgeEvent{
+		ChannelEvent: newChannelEvent(e),
+		OtherLegUUID: other,
+		OtherLeg:     NewCallerProfile(e.Message, "Other-Leg"),
+	}
+}
+
+func newChannelHangupEvent(e *Event) *ChannelHangupEvent {
+	return &ChannelHangupEvent{
+		ChannelEvent: newChannelEvent(e),
+		HangupCause:  e.Header("Hangup-Cause"),
+	}
+}
+
+func newChannelExecuteEvent(e *Event) *ChannelExecuteEvent {
+	return &ChannelExecuteEvent{
+		ChannelEvent:    newChannelEvent(e),
+		Application:     e.Header("Application"),
+		ApplicationData: e.Header("Application-Data"),
+		ApplicationUUID: e.Header("Application-UUID"),
+	}
+}
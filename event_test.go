@@ -0,0 +1,219 @@
+package goesl
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// plainEvent - Will build text/event-plain frame out of event headers and optional body
+func plainEvent(headers string, body string) string {
+	if body != "" {
+		headers += "Content-Length: " + strconv.Itoa(len(body)) + "\n"
+	}
+
+	return frame("text/event-plain", headers+"\n"+body)
+}
+
+// frame - Will build ESL frame with given content type and body
+func frame(contentType string, body string) string {
+	return "Content-Length: " + strconv.Itoa(len(body)) + "\nContent-Type: " + contentType + "\n\n" + body
+}
+
+var (
+	HangupCompleteEvent = plainEvent(`Event-Name: CHANNEL_HANGUP_COMPLETE
+Core-UUID: 596ab2fd-14c5-44b5-a02b-93ffb7cd5dd6
+Event-Date-Timestamp: 1696367616134783
+Event-Sequence: 1545554
+Unique-ID: 0dd4e4f7-36ed-a04d-a8f7-7aebb683af50
+Channel-Name: sofia/internal/1000%40192.168.0.58
+Channel-State: CS_REPORTING
+Channel-Call-State: HANGUP
+Answer-State: hangup
+Call-Direction: inbound
+Hangup-Cause: NORMAL_CLEARING
+Caller-Caller-ID-Name: John%20Doe
+Caller-Caller-ID-Number: 1000
+Caller-Destination-Number: 541
+Caller-Unique-ID: 0dd4e4f7-36ed-a04d-a8f7-7aebb683af50
+Caller-Context: default
+variable_duration: 65
+variable_billsec: 60
+variable_answer_uepoch: 1696367556134783
+variable_sip_authorized: true
+`, "")
+
+	DTMFJSONEvent = frame("text/event-json", `{"Event-Name":"DTMF","Unique-ID":"0dd4e4f7","Event-Date-Timestamp":"1696367616134783","DTMF-Digit":"#","DTMF-Duration":"2000","DTMF-Source":"RTP","Event-Sequence":"10"}`)
+
+	BridgeXMLEvent = frame("text/event-xml", `<event>
+  <headers>
+    <Event-Name>CHANNEL_BRIDGE</Event-Name>
+    <Unique-ID>0dd4e4f7</Unique-ID>
+    <Other-Leg-Unique-ID>f66e8e31</Other-Leg-Unique-ID>
+    <Other-Leg-Caller-ID-Number>1001</Other-Leg-Caller-ID-Number>
+  </headers>
+</event>
+`)
+)
+
+func TestDecodeEventPlain(t *testing.T) {
+	m, err := NewMessage(reader(HangupCompleteEvent), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := te.(*ChannelHangupCompleteEvent)
+	if !ok {
+		t.Fatalf("Expected *ChannelHangupCompleteEvent, got %T", te)
+	}
+
+	if e.Name != "CHANNEL_HANGUP_COMPLETE" || e.UUID != "0dd4e4f7-36ed-a04d-a8f7-7aebb683af50" || e.Sequence != 1545554 {
+		t.Fatalf("Unexpected event fields: %+v", e.Base())
+	}
+
+	if !e.Timestamp.Equal(time.UnixMicro(1696367616134783)) {
+		t.Fatalf("Unexpected event timestamp: %v", e.Timestamp)
+	}
+
+	if e.HangupCause != "NORMAL_CLEARING" || e.Caller.CallerIDName != "John Doe" || e.ChannelName != "sofia/internal/1000@192.168.0.58" {
+		t.Fatalf("Unexpected channel fields: %+v", e.ChannelHangupEvent)
+	}
+
+	if e.Duration != 65*time.Second || e.BillSec != 60*time.Second {
+		t.Fatalf("Unexpected durations: %v %v", e.Duration, e.BillSec)
+	}
+
+	if !e.Vars.Bool("sip_authorized") {
+		t.Fatal("Expected sip_authorized to be true")
+	}
+
+	if answered, err := e.Vars.Time("answer_uepoch"); err != nil || !answered.Equal(time.UnixMicro(1696367556134783)) {
+		t.Fatalf("Unexpected answer time: %v, %v", answered, err)
+	}
+}
+
+func TestVariablesBool(t *testing.T) {
+	vars := Variables{}
+	expected := map[string]bool{
+		"true": true, "TRUE": true, "t": true, "yes": true, "on": true, "enabled": true, "active": true, "allow": true,
+		"1": true, "-1": true, "+2": true, "007": true, "1.5": true,
+		"y": false, "false": false, "0": false, "00": false, "0.5": false, "-": false, "": false, "1a": false, "--1": false,
+	}
+
+	for value := range expected {
+		vars[value] = value
+	}
+
+	for value, b := range expected {
+		if vars.Bool(value) != b {
+			t.Errorf("Expected %q to be %v", value, b)
+		}
+	}
+}
+
+func TestDecodeEventJSON(t *testing.T) {
+	m, err := NewMessage(reader(DTMFJSONEvent), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := te.(*DTMFEvent)
+	if !ok {
+		t.Fatalf("Expected *DTMFEvent, got %T", te)
+	}
+
+	if e.Digit != "#" || e.Duration != 2000 || e.Source != "RTP" || e.UUID != "0dd4e4f7" || e.Sequence != 10 {
+		t.Fatalf("Unexpected DTMF event: %+v", e)
+	}
+}
+
+func TestDecodeEventXML(t *testing.T) {
+	m, err := NewMessage(reader(BridgeXMLEvent), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := te.(*ChannelBridgeEvent)
+	if !ok {
+		t.Fatalf("Expected *ChannelBridgeEvent, got %T", te)
+	}
+
+	if e.UUID != "0dd4e4f7" || e.OtherLegUUID != "f66e8e31" || e.OtherLeg.CallerIDNumber != "1001" {
+		t.Fatalf("Unexpected bridge event: %+v", e)
+	}
+}
+
+func TestDecodeEventBackgroundJob(t *testing.T) {
+	m, err := NewMessage(reader(plainEvent("Event-Name: BACKGROUND_JOB\nJob-UUID: 7f4db78a\nJob-Command: originate\n", "+OK 7f4de4bc\n")), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := te.(*BackgroundJobEvent)
+	if !ok || e.JobUUID != "7f4db78a" || e.JobCommand != "originate" || e.Result != "+OK 7f4de4bc\n" {
+		t.Fatalf("Unexpected background job event: %T %+v", te, te)
+	}
+}
+
+func TestDecodeEventCustom(t *testing.T) {
+	m, err := NewMessage(reader(plainEvent("Event-Name: CUSTOM\nEvent-Subclass: sofia%3A%3Aregister\n", "")), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, ok := te.(*CustomEvent); !ok || e.Subclass != "sofia::register" {
+		t.Fatalf("Unexpected custom event: %T %+v", te, te)
+	}
+}
+
+func TestDecodeEventConference(t *testing.T) {
+	m, err := NewMessage(reader(plainEvent("Event-Name: CUSTOM\nEvent-Subclass: conference%3A%3Amaintenance\nConference-Name: 3000\nAction: add-member\nMember-ID: 4\nMember-Type: moderator\n", "")), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, ok := te.(*ConferenceEvent); !ok || e.Conference != "3000" || e.Action != "add-member" || e.MemberID != 4 || e.MemberType != "moderator" {
+		t.Fatalf("Unexpected conference event: %T %+v", te, te)
+	}
+}
+
+func TestDecodeEventNotAnEvent(t *testing.T) {
+	m, err := NewMessage(reader(EchoResponse), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodeEvent(m); err == nil {
+		t.Fatal("Expected api/response to not decode as event")
+	}
+}
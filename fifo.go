@@ -0,0 +1,214 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FifoInfo - Subclass of CUSTOM events mod_fifo reports callers and consumers coming and going with
+const FifoInfo = "fifo::info"
+
+// Fifo - Single fifo as reported by `fifo list`
+type Fifo struct {
+	Name             string        `xml:"name,attr"`
+	ConsumerCount    int           `xml:"consumer_count,attr"`
+	CallerCount      int           `xml:"caller_count,attr"`
+	WaitingCount     int           `xml:"waiting_count,attr"`
+	Importance       int           `xml:"importance,attr"`
+	OutboundPerCycle int           `xml:"outbound_per_cycle,attr"`
+	RingTimeout      int           `xml:"ring_timeout,attr"`
+	DefaultLag       int           `xml:"default_lag,attr"`
+	OutboundPriority int           `xml:"outbound_priority,attr"`
+	OutboundStrategy string        `xml:"outbound_strategy,attr"`
+	Callers          []FifoChannel `xml:"callers>caller"`
+	Consumers        []FifoChannel `xml:"consumers>consumer"`
+	Bridges          []FifoBridge  `xml:"bridges>bridge"`
+}
+
+// FifoChannel - Caller waiting in fifo or consumer serving it
+type FifoChannel struct {
+	UUID           string `xml:"uuid,attr"`
+	Status         string `xml:"status,attr"`
+	Timestamp      string `xml:"timestamp,attr"`
+	CallerIDName   string `xml:"caller_profile>caller_id_name"`
+	CallerIDNumber string `xml:"caller_profile>caller_id_number"`
+}
+
+// FifoBridge - Caller and consumer fifo bridged together
+type FifoBridge struct {
+	FifoName         string `xml:"fifo_name,attr"`
+	BridgeStart      string `xml:"bridge_start,attr"`
+	CallerUUID       string `xml:"caller>uuid"`
+	CallerIDName     string `xml:"caller>caller_id_name"`
+	CallerIDNumber   string `xml:"caller>caller_id_number"`
+	ConsumerUUID     string `xml:"consumer>uuid"`
+	ConsumerOutgoing string `xml:"consumer>outgoing_uuid"`
+}
+
+// FifoCount - Single line of `fifo count`
+type FifoCount struct {
+	Name             string
+	Consumers        int
+	Callers          int
+	Waiting          int
+	RingingConsumers int
+	IdleConsumers    int
+}
+
+type fifoReport struct {
+	Fifos []Fifo `xml:"fifo"`
+}
+
+// FifoEvent - CUSTOM fifo::info. Action tells what happened (push, abort, consumer_start, caller_pop,
+// bridge-caller-start...).
+type FifoEvent struct {
+	*Event
+	Fifo   string
+	Action string
+}
+
+// fifoArgs - Will build fifo app args. Announce and music are optional, undef keeps fifo defaults.
+func fifoArgs(words []string, announce string, music string) (string, error) {
+	if announce != "" || music != "" {
+		for _, v := range []string{announce, music} {
+			if v == "" {
+				v = "undef"
+			}
+			words = append(words, v)
+		}
+	}
+
+	for _, w := range words {
+		if _, err := apiWord(w); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// ExecuteFifoIn - Helper designed to put active ESL session into fifo as a caller. Announce (played to consumer)
+// and music (played while waiting) are optional.
+func (sc *SocketConnection) ExecuteFifoIn(name string, announce string, music string, sync bool) (m *Message, err error) {
+	args, err := fifoArgs([]string{name, "in"}, announce, music)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("fifo", args, sync)
+}
+
+// ExecuteFifoOut - Helper designed to make active ESL session consumer of the fifo. With wait set it keeps
+// waiting for callers, otherwise it gives up when fifo is empty. Announce and music are optional.
+func (sc *SocketConnection) ExecuteFifoOut(name string, wait bool, announce string, music string, sync bool) (m *Message, err error) {
+	mode := "nowait"
+	if wait {
+		mode = "wait"
+	}
+
+	args, err := fifoArgs([]string{name, "out", mode}, announce, music)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("fifo", args, sync)
+}
+
+// ParseFifoList - Will parse output of `fifo list`
+func ParseFifoList(body []byte) ([]Fifo, error) {
+	var report fifoReport
+	if err := decodeXMLReply(body, &report); err != nil {
+		return nil, err
+	}
+
+	if report.Fifos == nil {
+		return []Fifo{}, nil
+	}
+
+	return report.Fifos, nil
+}
+
+// ParseFifoCount - Will parse output of `fifo count`. Lines are name:consumers:callers:waiting:ringing:idle.
+func ParseFifoCount(body []byte) ([]FifoCount, error) {
+	counts := []FifoCount{}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// Name is the only field that could contain : (e.g. sip uri), numbers are taken from the end
+		fields := strings.Split(line, ":")
+		if len(fields) < 6 {
+			return nil, &ReplyError{Reply: line}
+		}
+
+		n := len(fields) - 5
+		nums := make([]int, 5)
+
+		for i, f := range fields[n:] {
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, &ReplyError{Reply: line}
+			}
+			nums[i] = v
+		}
+
+		counts = append(counts, FifoCount{
+			Name:             strings.Join(fields[:n], ":"),
+			Consumers:        nums[0],
+			Callers:          nums[1],
+			Waiting:          nums[2],
+			RingingConsumers: nums[3],
+			IdleConsumers:    nums[4],
+		})
+	}
+
+	return counts, nil
+}
+
+// FifoList - Will return fifos along with their callers, consumers and bridges. Name is optional, all fifos are
+// returned when empty.
+func (sc *SocketConnection) FifoList(name string) ([]Fifo, error) {
+	cmd, err := apiCommand("fifo list", nil, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFifoList(m.Body)
+}
+
+// FifoCount - Will return caller and consumer counts of fifos. Name is optional, all fifos are returned when empty.
+func (sc *SocketConnection) FifoCount(name string) ([]FifoCount, error) {
+	cmd, err := apiCommand("fifo count", nil, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFifoCount(m.Body)
+}
+
+func newFifoEvent(e *Event) *FifoEvent {
+	return &FifoEvent{
+		Event:  e,
+		Fifo:   e.Header("FIFO-Name"),
+		Action: e.Header("FIFO-Action"),
+	}
+}
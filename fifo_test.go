@@ -0,0 +1,122 @@
+package goesl
+
+import (
+	"errors"
+	"testing"
+)
+
+var fifoList = `<fifo_report>
+  <fifo name="support" consumer_count="1" caller_count="1" waiting_count="1" importance="0" outbound_per_cycle="1" ring_timeout="60" default_lag="30" outbound_priority="5" outbound_strategy="ringall">
+    <outbound>
+    </outbound>
+    <callers>
+      <caller uuid="0dd4e4f7-36ed-a04d-a8f7-7aebb683af50" status="WAITING" timestamp="2023-10-03 14:48:36">
+        <caller_profile>
+          <caller_id_name>John Doe</caller_id_name>
+          <caller_id_number>1000</caller_id_number>
+        </caller_profile>
+      </caller>
+    </callers>
+    <bridges>
+    </bridges>
+    <consumers>
+      <consumer uuid="6c1ef0b4-8f9b-4a6e-a3e5-6d0f8b0bd3d3" status="WAITING" timestamp="2023-10-03 14:40:00">
+        <caller_profile>
+          <caller_id_name>Agent</caller_id_name>
+          <caller_id_number>2000</caller_id_number>
+        </caller_profile>
+      </consumer>
+    </consumers>
+  </fifo>
+</fifo_report>
+`
+
+func TestFifoArgs(t *testing.T) {
+	tests := map[string]struct {
+		words    []string
+		announce string
+		music    string
+	}{
+		"support in":                          {[]string{"support", "in"}, "", ""},
+		"support in undef local_stream://moh": {[]string{"support", "in"}, "", "local_stream://moh"},
+		"support out wait announce.wav undef": {[]string{"support", "out", "wait"}, "announce.wav", ""},
+	}
+
+	for expected, tt := range tests {
+		args, err := fifoArgs(tt.words, tt.announce, tt.music)
+		if err != nil || args != expected {
+			t.Fatalf("Expected %q, got %q, %v", expected, args, err)
+		}
+	}
+
+	if _, err := fifoArgs([]string{"my fifo", "in"}, "", ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestParseFifoList(t *testing.T) {
+	fifos, err := ParseFifoList([]byte(fifoList))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fifos) != 1 {
+		t.Fatalf("Expected 1 fifo, got %+v", fifos)
+	}
+
+	f := fifos[0]
+	if f.Name != "support" || f.CallerCount != 1 || f.RingTimeout != 60 || f.OutboundStrategy != "ringall" {
+		t.Fatalf("Unexpected fifo: %+v", f)
+	}
+
+	if len(f.Callers) != 1 || f.Callers[0].CallerIDName != "John Doe" || f.Callers[0].Status != "WAITING" {
+		t.Fatalf("Unexpected callers: %+v", f.Callers)
+	}
+
+	if len(f.Consumers) != 1 || f.Consumers[0].CallerIDNumber != "2000" {
+		t.Fatalf("Unexpected consumers: %+v", f.Consumers)
+	}
+}
+
+func TestFifoCount(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "support:1:2:2:0:1\nsales@192.168.0.1:5060:0:0:0:0:0\n"
+	})
+
+	go c.Handle()
+
+	counts, err := c.FifoCount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "fifo count" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	expected := []FifoCount{
+		{Name: "support", Consumers: 1, Callers: 2, Waiting: 2, IdleConsumers: 1},
+		{Name: "sales@192.168.0.1:5060"},
+	}
+
+	if len(counts) != len(expected) || counts[0] != expected[0] || counts[1] != expected[1] {
+		t.Fatalf("Expected %+v, got %+v", expected, counts)
+	}
+
+	if _, err := ParseFifoCount([]byte("-USAGE: fifo count")); !errors.As(err, new(*ReplyError)) {
+		t.Fatalf("Expected reply error, got %v", err)
+	}
+}
+
+func TestDecodeFifoEvent(t *testing.T) {
+	m := channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: fifo%3A%3Ainfo\nUnique-ID: a\nFIFO-Name: support\nFIFO-Action: push\n")
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ev, ok := te.(*FifoEvent); !ok || ev.Fifo != "support" || ev.Action != "push" {
+		t.Fatalf("Unexpected event: %+v", te)
+	}
+}
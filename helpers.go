@@ -6,8 +6,25 @@
 
 package goesl
 
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dtmfDigits - Digits send_dtmf can send
+var dtmfDigits = regexp.MustCompile(`^[0-9*#A-Da-dwW]+$`)
+
 // Set - Helper that you can use to execute SET application against active ESL session
 func (sc *SocketConnection) ExecuteSet(key string, value string, sync bool) (m *Message, err error) {
+	if err := validateVariable(key); err != nil {
+		return nil, err
+	}
+
 	return sc.Execute("set", key+"="+value, sync)
 }
 
@@ -30,6 +47,17 @@ func (sc *SocketConnection) Api(command string) error {
 	return sc.Send("api " + command)
 }
 
+// SendApi - Will send api command and wait for its api/response. Response body is available as m.Body.
+// In case freeswitch replies with -ERR, *ReplyError is returned. Handle() must be running for response to arrive.
+func (sc *SocketConnection) SendApi(command string) (m *Message, err error) {
+	return sc.SendApiContext(context.Background(), command)
+}
+
+// SendApiContext - Same as SendApi but gives up waiting for response once ctx is done
+func (sc *SocketConnection) SendApiContext(ctx context.Context, command string) (m *Message, err error) {
+	return sc.request(ctx, "api "+command, nil)
+}
+
 // BgApi - Helper designed to attach bgapi in front of the command so that you do not need to write it
 func (sc *SocketConnection) BgApi(command string) error {
 	return sc.Send("bgapi " + command)
@@ -45,3 +73,545 @@ func (sc *SocketConnection) Connect() error {
 func (sc *SocketConnection) Exit() error {
 	return sc.Send("exit")
 }
+
+// NotifyEvent - Will build NOTIFY event making sofia send SIP NOTIFY of given event package (e.g. check-sync)
+// to user@host registered on the profile. Extra headers (to-uri, call-id, uuid...) can be added to e.Headers.
+func NotifyEvent(profile, user, host, eventString, contentType, body string) OutgoingEvent {
+	return OutgoingEvent{
+		Name: "NOTIFY",
+		Headers: map[string]string{
+			"profile":      profile,
+			"user":         user,
+			"host":         host,
+			"event-string": eventString,
+			"content-type": contentType,
+		},
+		Body: body,
+	}
+}
+
+// SendMessageEvent - Will build SEND_MESSAGE event making sofia send SIP MESSAGE to user@host registered on the profile
+func SendMessageEvent(profile, user, host, contentType, body string) OutgoingEvent {
+	return OutgoingEvent{
+		Name: "SEND_MESSAGE",
+		Headers: map[string]string{
+			"profile":      profile,
+			"user":         user,
+			"host":         host,
+			"content-type": contentType,
+		},
+		Body: body,
+	}
+}
+
+// MessageWaitingEvent - Will build MESSAGE_WAITING event updating message waiting indicator of account (user@host)
+func MessageWaitingEvent(account string, newMessages, oldMessages int) OutgoingEvent {
+	waiting := "no"
+	if newMessages > 0 {
+		waiting = "yes"
+	}
+
+	return OutgoingEvent{
+		Name: "MESSAGE_WAITING",
+		Headers: map[string]string{
+			"MWI-Messages-Waiting": waiting,
+			"MWI-Message-Account":  account,
+			"MWI-Voice-Message":    fmt.Sprintf("%d/%d (0/0)", newMessages, oldMessages),
+		},
+	}
+}
+
+// executeApp - Will validate application args before executing application against active ESL session
+func (sc *SocketConnection) executeApp(app string, args string, sync bool) (m *Message, err error) {
+	if _, err := apiArg(args); err != nil {
+		return nil, err
+	}
+
+	return sc.Execute(app, args, sync)
+}
+
+// ExecutePlayback - Helper designed to play file (or any other freeswitch sound uri) against active ESL session
+func (sc *SocketConnection) ExecutePlayback(file string, sync bool) (m *Message, err error) {
+	return sc.executeApp("playback", file, sync)
+}
+
+// Bridge - Arguments of bridge application. Endpoints are dialed simultaneously unless Failover is set,
+// in which case they are tried one after another. Vars are set on all of the B legs.
+type Bridge struct {
+	Endpoints []string
+	Failover  bool
+	Vars      map[string]string
+}
+
+// String - Will return bridge dial string e.g. {a=b}sofia/gateway/gw1/1000,user/1001
+func (b Bridge) String() string {
+	sep := ","
+	if b.Failover {
+		sep = "|"
+	}
+
+	return channelVars(b.Vars) + strings.Join(b.Endpoints, sep)
+}
+
+// ExecuteBridge - Helper designed to bridge active ESL session to one or more endpoints
+func (sc *SocketConnection) ExecuteBridge(b Bridge, sync bool) (m *Message, err error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("bridge", b.String(), sync)
+}
+
+// validate - Will make sure dial string is built out of what it says. Endpoint containing , or | (or :_: used by
+// enterprise originate) would dial more than it was asked to.
+func (b Bridge) validate() error {
+	if len(b.Endpoints) == 0 {
+		return fmt.Errorf("%w: bridge needs at least one endpoint", ErrInvalidArgument)
+	}
+
+	for _, e := range b.Endpoints {
+		if e == "" || strings.ContainsAny(e, ",|") || strings.Contains(e, ":_:") {
+			return fmt.Errorf("%w: bridge endpoint %q", ErrInvalidArgument, e)
+		}
+	}
+
+	return validateChannelVars(b.Vars)
+}
+
+// Record - Arguments of record application. Zero values are left for freeswitch to decide.
+type Record struct {
+	Path             string
+	TimeLimit        time.Duration
+	SilenceThreshold int
+	SilenceHits      int
+}
+
+// String - Will return record arguments e.g. /tmp/rec.wav 20 200 3. Path with spaces is quoted.
+func (r Record) String() string {
+	args := []string{quoted(r.Path)}
+
+	if r.TimeLimit > 0 || r.SilenceThreshold > 0 || r.SilenceHits > 0 {
+		args = append(args, strconv.Itoa(int(r.TimeLimit/time.Second)))
+	}
+
+	if r.SilenceThreshold > 0 || r.SilenceHits > 0 {
+		args = append(args, strconv.Itoa(r.SilenceThreshold))
+	}
+
+	if r.SilenceHits > 0 {
+		args = append(args, strconv.Itoa(r.SilenceHits))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// ExecuteRecord - Helper designed to record active ESL session until hangup, silence or time limit
+func (sc *SocketConnection) ExecuteRecord(r Record, sync bool) (m *Message, err error) {
+	if _, err := quoteArg(r.Path); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("record", r.String(), sync)
+}
+
+// ExecuteRecordSession - Helper designed to record whole active ESL session in the background
+func (sc *SocketConnection) ExecuteRecordSession(path string, sync bool) (m *Message, err error) {
+	return sc.executeApp("record_session", path, sync)
+}
+
+// PlayAndGetDigits - Arguments of play_and_get_digits application. Collected digits are stored in VarName.
+// Terminators defaults to none, InvalidFile to a short silence and Regexp to any digits.
+type PlayAndGetDigits struct {
+	Min               int
+	Max               int
+	Tries             int
+	Timeout           time.Duration
+	Terminators       string
+	File              string
+	InvalidFile       string
+	VarName           string
+	Regexp            string
+	DigitTimeout      time.Duration
+	TransferOnFailure string
+}
+
+// String - Will return play_and_get_digits arguments e.g. 1 4 3 5000 # prompt.wav silence_stream://250 pin \d+.
+// Files and regexp with spaces are quoted.
+func (p PlayAndGetDigits) String() string {
+	terminators := p.Terminators
+	if terminators == "" {
+		terminators = "none"
+	}
+
+	invalidFile := p.InvalidFile
+	if invalidFile == "" {
+		invalidFile = "silence_stream://250"
+	}
+
+	pattern := p.Regexp
+	if pattern == "" {
+		pattern = `\d+`
+	}
+
+	args := []string{
+		strconv.Itoa(p.Min),
+		strconv.Itoa(p.Max),
+		strconv.Itoa(p.Tries),
+		strconv.Itoa(int(p.Timeout / time.Millisecond)),
+		terminators,
+		quoted(p.File),
+		quoted(invalidFile),
+		p.VarName,
+		quoted(pattern),
+	}
+
+	if p.DigitTimeout > 0 || p.TransferOnFailure != "" {
+		args = append(args, strconv.Itoa(int(p.DigitTimeout/time.Millisecond)))
+	}
+
+	if p.TransferOnFailure != "" {
+		args = append(args, p.TransferOnFailure)
+	}
+
+	return strings.Join(args, " ")
+}
+
+// ExecutePlayAndGetDigits - Helper designed to play prompt and collect digits against active ESL session
+func (sc *SocketConnection) ExecutePlayAndGetDigits(p PlayAndGetDigits, sync bool) (m *Message, err error) {
+	if p.File == "" || p.VarName == "" {
+		return nil, fmt.Errorf("%w: play_and_get_digits needs file and variable name", ErrInvalidArgument)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("play_and_get_digits", p.String(), sync)
+}
+
+// validate - Will make sure none of the args spills over into the next one. Files and regexp get quoted, variable
+// name and terminators cannot contain spaces.
+func (p PlayAndGetDigits) validate() error {
+	for _, arg := range []string{p.File, p.InvalidFile, p.Regexp} {
+		if arg == "" {
+			continue
+		}
+
+		if _, err := quoteArg(arg); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range []string{p.VarName, p.Terminators} {
+		if arg == "" {
+			continue
+		}
+
+		if _, err := apiWord(arg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read - Arguments of read application. Collected digits are stored in VarName. Terminators defaults to none.
+type Read struct {
+	Min         int
+	Max         int
+	File        string
+	VarName     string
+	Timeout     time.Duration
+	Terminators string
+}
+
+// String - Will return read arguments e.g. 1 4 prompt.wav pin 5000 #. File with spaces is quoted.
+func (r Read) String() string {
+	terminators := r.Terminators
+	if terminators == "" {
+		terminators = "none"
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(r.Min),
+		strconv.Itoa(r.Max),
+		quoted(r.File),
+		r.VarName,
+		strconv.Itoa(int(r.Timeout / time.Millisecond)),
+		terminators,
+	}, " ")
+}
+
+// ExecuteRead - Helper designed to play prompt and read digits against active ESL session
+func (sc *SocketConnection) ExecuteRead(r Read, sync bool) (m *Message, err error) {
+	if r.File == "" || r.VarName == "" {
+		return nil, fmt.Errorf("%w: read needs file and variable name", ErrInvalidArgument)
+	}
+
+	if _, err := quoteArg(r.File); err != nil {
+		return nil, err
+	}
+
+	for _, arg := range []string{r.VarName, r.Terminators} {
+		if arg == "" {
+			continue
+		}
+
+		if _, err := apiWord(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	return sc.executeApp("read", r.String(), sync)
+}
+
+// ExecuteSleep - Helper designed to pause active ESL session for given duration
+func (sc *SocketConnection) ExecuteSleep(d time.Duration, sync bool) (m *Message, err error) {
+	return sc.executeApp("sleep", strconv.Itoa(int(d/time.Millisecond)), sync)
+}
+
+// ExecuteTransfer - Helper designed to transfer active ESL session to destination. Dialplan and context are optional.
+func (sc *SocketConnection) ExecuteTransfer(dest string, dialplan string, context string, sync bool) (m *Message, err error) {
+	if context != "" && dialplan == "" {
+		dialplan = "XML"
+	}
+
+	args := []string{dest}
+	for _, arg := range []string{dialplan, context} {
+		if arg != "" {
+			args = append(args, arg)
+		}
+	}
+
+	for _, arg := range args {
+		if _, err := apiWord(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	return sc.executeApp("transfer", strings.Join(args, " "), sync)
+}
+
+// ExecutePark - Helper designed to park active ESL session
+func (sc *SocketConnection) ExecutePark(sync bool) (m *Message, err error) {
+	return sc.executeApp("park", "", sync)
+}
+
+// ExecuteExport - Helper designed to set variable on active ESL session and on any channel bridged to it later on
+func (sc *SocketConnection) ExecuteExport(key string, value string, sync bool) (m *Message, err error) {
+	if err := validateVariable(key); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("export", key+"="+value, sync)
+}
+
+// ExecuteMultiset - Helper designed to set many variables at once against active ESL session
+func (sc *SocketConnection) ExecuteMultiset(vars map[string]string, sync bool) (m *Message, err error) {
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("%w: multiset needs at least one variable", ErrInvalidArgument)
+	}
+
+	for k := range vars {
+		if err := validateVariable(k); err != nil {
+			return nil, err
+		}
+	}
+
+	args, err := multisetArgs(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("multiset", args, sync)
+}
+
+// ExecuteUnset - Helper designed to unset variable on active ESL session
+func (sc *SocketConnection) ExecuteUnset(key string, sync bool) (m *Message, err error) {
+	if err := validateVariable(key); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("unset", key, sync)
+}
+
+// ExecuteRingReady - Helper designed to send ringing (180) without media against active ESL session
+func (sc *SocketConnection) ExecuteRingReady(sync bool) (m *Message, err error) {
+	return sc.executeApp("ring_ready", "", sync)
+}
+
+// ExecutePreAnswer - Helper designed to establish early media (183) against active ESL session
+func (sc *SocketConnection) ExecutePreAnswer(sync bool) (m *Message, err error) {
+	return sc.executeApp("pre_answer", "", sync)
+}
+
+// ExecuteSendDTMF - Helper designed to send DTMF digits against active ESL session. Duration of 0 uses freeswitch default.
+// Digits are 0-9, *, #, A-D and w/W (half a second/one second pause).
+func (sc *SocketConnection) ExecuteSendDTMF(digits string, duration time.Duration, sync bool) (m *Message, err error) {
+	if !dtmfDigits.MatchString(digits) {
+		return nil, fmt.Errorf("%w: dtmf digits %q", ErrInvalidArgument, digits)
+	}
+
+	if duration > 0 {
+		digits += "@" + strconv.Itoa(int(duration/time.Millisecond))
+	}
+
+	return sc.executeApp("send_dtmf", digits, sync)
+}
+
+// ExecuteStartDTMF - Helper designed to start inband DTMF detection against active ESL session
+func (sc *SocketConnection) ExecuteStartDTMF(sync bool) (m *Message, err error) {
+	return sc.executeApp("start_dtmf", "", sync)
+}
+
+// Conference - Arguments of conference application. Profile, Pin and Flags are optional.
+type Conference struct {
+	Name    string
+	Profile string
+	Pin     string
+	Flags   []string
+}
+
+// String - Will return conference arguments e.g. room@default+1234+flags{mute|moderator}
+func (c Conference) String() string {
+	args := c.Name
+
+	if c.Profile != "" {
+		args += "@" + c.Profile
+	}
+
+	if c.Pin != "" {
+		args += "+" + c.Pin
+	}
+
+	if len(c.Flags) > 0 {
+		args += "+flags{" + strings.Join(c.Flags, "|") + "}"
+	}
+
+	return args
+}
+
+// ExecuteConference - Helper designed to put active ESL session into conference
+func (sc *SocketConnection) ExecuteConference(c Conference, sync bool) (m *Message, err error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("conference", c.String(), sync)
+}
+
+// validate - Will make sure conference args are built out of what they say. @ and + separate profile, pin and
+// flags, so name containing them (or profile or pin containing +) would join different conference.
+func (c Conference) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("%w: conference needs a name", ErrInvalidArgument)
+	}
+
+	if strings.ContainsAny(c.Name, "@+") || strings.Contains(c.Profile, "+") || strings.Contains(c.Pin, "+") {
+		return fmt.Errorf("%w: conference %q", ErrInvalidArgument, c.String())
+	}
+
+	for _, flag := range c.Flags {
+		if flag == "" || strings.ContainsAny(flag, "|{}") {
+			return fmt.Errorf("%w: conference flag %q", ErrInvalidArgument, flag)
+		}
+	}
+
+	return nil
+}
+
+// ExecuteSpeak - Helper designed to speak text with given TTS engine and voice against active ESL session
+func (sc *SocketConnection) ExecuteSpeak(engine string, voice string, text string, sync bool) (m *Message, err error) {
+	// Text is whatever follows the second |, engine and voice cannot contain it
+	if engine == "" || strings.Contains(engine, "|") || strings.Contains(voice, "|") {
+		return nil, fmt.Errorf("%w: speak engine %q and voice %q", ErrInvalidArgument, engine, voice)
+	}
+
+	return sc.executeApp("speak", engine+"|"+voice+"|"+text, sync)
+}
+
+// quoted - Will return arg quoted the way quoteArg does it, or as it is in case it cannot be quoted (Execute* helpers
+// reject such args before they get this far)
+func quoted(arg string) string {
+	if q, err := quoteArg(arg); err == nil {
+		return q
+	}
+
+	return arg
+}
+
+// validateChannelVars - Will check channel variables can be put within {}. Values with , or space get quoted, but
+// there is no way to escape quote or brackets within the value, so those are rejected.
+func validateChannelVars(vars map[string]string) error {
+	for k, v := range vars {
+		if err := validateVariable(k); err != nil {
+			return err
+		}
+
+		if strings.ContainsAny(k, ",'{}[]") || strings.ContainsAny(v, "'{}[]") {
+			return fmt.Errorf("%w: channel variable %s=%q", ErrInvalidArgument, k, v)
+		}
+	}
+
+	return nil
+}
+
+// channelVars - Will format channel variables as {a=b,c='d e'} with keys in sorted order. Use validateChannelVars
+// before passing them on.
+func channelVars(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := vars[k]
+		if strings.ContainsAny(v, ", ") {
+			v = "'" + v + "'"
+		}
+
+		pairs = append(pairs, k+"="+v)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// multisetArgs - Will format variables for multiset with keys in sorted order. In case any of the values
+// contains space, freeswitch ^^ syntax is used to switch delimiter to character not found in any of the pairs.
+// Pairs containing all of the delimiters cannot be told apart and are rejected.
+func multisetArgs(vars map[string]string) (string, error) {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+vars[k])
+	}
+
+	joined := strings.Join(pairs, "")
+
+	for _, delim := range []string{" ", "|", ":", ";", "~", "!"} {
+		if strings.Contains(joined, delim) {
+			continue
+		}
+
+		if delim == " " {
+			return strings.Join(pairs, delim), nil
+		}
+
+		return "^^" + delim + strings.Join(pairs, delim), nil
+	}
+
+	return "", fmt.Errorf("%w: multiset variables contain all of the delimiters", ErrInvalidArgument)
+}
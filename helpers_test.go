@@ -0,0 +1,160 @@
+package goesl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBridgeString(t *testing.T) {
+	b := Bridge{
+		Endpoints: []string{"sofia/gateway/gw1/1000", "user/1001"},
+		Vars:      map[string]string{"origination_caller_id_name": "John Doe", "call_timeout": "30"},
+	}
+
+	expected := "{call_timeout=30,origination_caller_id_name='John Doe'}sofia/gateway/gw1/1000,user/1001"
+	if b.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, b.String())
+	}
+
+	b.Failover = true
+	b.Vars = nil
+
+	if b.String() != "sofia/gateway/gw1/1000|user/1001" {
+		t.Fatalf("Unexpected failover dial string %q", b.String())
+	}
+}
+
+func TestPlayAndGetDigitsString(t *testing.T) {
+	p := PlayAndGetDigits{
+		Min:         1,
+		Max:         4,
+		Tries:       3,
+		Timeout:     5 * time.Second,
+		Terminators: "#",
+		File:        "prompt.wav",
+		VarName:     "pin",
+	}
+
+	expected := `1 4 3 5000 # prompt.wav silence_stream://250 pin \d+`
+	if p.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, p.String())
+	}
+
+	p.TransferOnFailure = "operator XML default"
+	expected = `1 4 3 5000 # prompt.wav silence_stream://250 pin \d+ 0 operator XML default`
+	if p.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, p.String())
+	}
+
+	p = PlayAndGetDigits{Min: 1, Max: 1, Tries: 1, File: "/sounds/enter pin.wav", InvalidFile: "/sounds/bad pin.wav", VarName: "pin", Regexp: `\d| \*`}
+	expected = `1 1 1 0 none '/sounds/enter pin.wav' '/sounds/bad pin.wav' pin '\d| \*'`
+	if p.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, p.String())
+	}
+}
+
+func TestRecordString(t *testing.T) {
+	tests := map[string]Record{
+		"/tmp/rec.wav":          {Path: "/tmp/rec.wav"},
+		"/tmp/rec.wav 20":       {Path: "/tmp/rec.wav", TimeLimit: 20 * time.Second},
+		"/tmp/rec.wav 0 200 3":  {Path: "/tmp/rec.wav", SilenceThreshold: 200, SilenceHits: 3},
+		"/tmp/rec.wav 20 200":   {Path: "/tmp/rec.wav", TimeLimit: 20 * time.Second, SilenceThreshold: 200},
+		"/tmp/rec.wav 20 200 3": {Path: "/tmp/rec.wav", TimeLimit: 20 * time.Second, SilenceThreshold: 200, SilenceHits: 3},
+		"'/tmp/my rec.wav' 20":  {Path: "/tmp/my rec.wav", TimeLimit: 20 * time.Second},
+	}
+
+	for expected, r := range tests {
+		if r.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, r.String())
+		}
+	}
+}
+
+func TestConferenceString(t *testing.T) {
+	c := Conference{Name: "room", Profile: "default", Pin: "1234", Flags: []string{"mute", "moderator"}}
+
+	if c.String() != "room@default+1234+flags{mute|moderator}" {
+		t.Fatalf("Unexpected conference args %q", c.String())
+	}
+}
+
+func TestMultisetArgs(t *testing.T) {
+	if args, err := multisetArgs(map[string]string{"b": "2", "a": "1"}); err != nil || args != "a=1 b=2" {
+		t.Fatalf("Unexpected multiset args %q", args)
+	}
+
+	if args, err := multisetArgs(map[string]string{"a": "hello world", "b": "2"}); err != nil || args != "^^|a=hello world|b=2" {
+		t.Fatalf("Unexpected multiset args %q", args)
+	}
+
+	if _, err := multisetArgs(map[string]string{"a": "x y|z:w;v~u!t"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestExecuteInvalidArgument(t *testing.T) {
+	c := newSocketConnection(nil)
+
+	if _, err := c.ExecutePlayback("/tmp/test.wav\n\nevent-lock: false", false); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+
+	for _, b := range []Bridge{
+		{},
+		{Endpoints: []string{"user/1000,user/1001"}},
+		{Endpoints: []string{"user/1000|user/1001"}},
+		{Endpoints: []string{"user/1000:_:user/1001"}},
+		{Endpoints: []string{"user/1000"}, Vars: map[string]string{"a": "it's"}},
+		{Endpoints: []string{"user/1000"}, Vars: map[string]string{"a": "b}user/1001"}},
+		{Endpoints: []string{"user/1000"}, Vars: map[string]string{"a": "[b]"}},
+		{Endpoints: []string{"user/1000"}, Vars: map[string]string{"a,b": "c"}},
+	} {
+		if _, err := c.ExecuteBridge(b, false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected ErrInvalidArgument for %+v, got %v", b, err)
+		}
+	}
+
+	if _, err := c.ExecuteTransfer("1000 XML", "", "", false); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+
+	if _, err := c.ExecuteRecord(Record{Path: "/tmp/it's.wav"}, false); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+
+	for _, p := range []PlayAndGetDigits{
+		{File: "it's.wav", VarName: "pin"},
+		{File: "prompt.wav", InvalidFile: "it's.wav", VarName: "pin"},
+		{File: "prompt.wav", VarName: "my pin"},
+		{File: "prompt.wav", VarName: "pin", Terminators: "# *"},
+	} {
+		if _, err := c.ExecutePlayAndGetDigits(p, false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected ErrInvalidArgument for %+v, got %v", p, err)
+		}
+	}
+
+	for _, args := range [][2]string{{"flite|x", "kal"}, {"flite", "kal|x"}, {"", "kal"}} {
+		if _, err := c.ExecuteSpeak(args[0], args[1], "hello", false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected ErrInvalidArgument for %v, got %v", args, err)
+		}
+	}
+
+	for _, digits := range []string{"", "12 3", "1@500", "12E"} {
+		if _, err := c.ExecuteSendDTMF(digits, 0, false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected ErrInvalidArgument for %q, got %v", digits, err)
+		}
+	}
+
+	for _, conf := range []Conference{
+		{},
+		{Name: "room@other"},
+		{Name: "room+1234"},
+		{Name: "room", Profile: "default+1234"},
+		{Name: "room", Flags: []string{"mute|deaf"}},
+	} {
+		if _, err := c.ExecuteConference(conf, false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected ErrInvalidArgument for %+v, got %v", conf, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"fmt"
+)
+
+// Names of message limits reported in LimitError
+const (
+	LimitBodySize   = "body size"
+	LimitHeaders    = "header count"
+	LimitLineLength = "header line length"
+)
+
+// MessageLimits - How large messages freeswitch sends are allowed to be. Zero values fall back to MaxContentLength,
+// DefaultMaxHeaders and DefaultMaxLineLength. Message crossing any of them fails with *LimitError, before any more
+// of it is read, and connection is closed as there's no telling where the next message starts.
+type MessageLimits struct {
+	MaxBodySize   int
+	MaxHeaders    int
+	MaxLineLength int
+}
+
+// withDefaults - Will fill in defaults for limits that are not set
+func (l MessageLimits) withDefaults() MessageLimits {
+	if l.MaxBodySize < 1 {
+		l.MaxBodySize = MaxContentLength
+	}
+
+	if l.MaxHeaders < 1 {
+		l.MaxHeaders = DefaultMaxHeaders
+	}
+
+	if l.MaxLineLength < 1 {
+		l.MaxLineLength = DefaultMaxLineLength
+	}
+
+	return l
+}
+
+// LimitError - Message freeswitch sent crossed one of MessageLimits. Limit is one of LimitBodySize, LimitHeaders
+// or LimitLineLength.
+type LimitError struct {
+	Limit string
+	Size  int
+	Max   int
+}
+
+// Error - Will describe which limit was crossed
+func (e *LimitError) Error() string {
+	return fmt.Sprintf(ELimitExceeded, e.Limit, e.Size, e.Max)
+}
+
+// Is - Makes errors.Is(err, ErrLimitExceeded) work for any limit and errors.Is(err, ErrContentTooLarge) for body size
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded || (target == ErrContentTooLarge && e.Limit == LimitBodySize)
+}
+
+// SetMessageLimits - Will change limits of messages read from the connection. Must be called before Handle().
+func (c *SocketConnection) SetMessageLimits(limits MessageLimits) {
+	c.limits = limits
+}
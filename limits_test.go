@@ -0,0 +1,83 @@
+package goesl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageLimits(t *testing.T) {
+	manyHeaders := "Content-Type: command/reply\n" + strings.Repeat("X-Header: a\n", 10) + "\n"
+	jsonEvent := `{"Event-Name":"HEARTBEAT","A":"1","B":"2","C":"3"}`
+
+	tests := []struct {
+		name   string
+		input  string
+		limits MessageLimits
+		limit  string
+	}{
+		{"body size", "Content-Type: api/response\nContent-Length: 11\n\nhello world", MessageLimits{MaxBodySize: 10}, LimitBodySize},
+		{"header count", manyHeaders, MessageLimits{MaxHeaders: 10}, LimitHeaders},
+		{"event header count", "Content-Type: text/event-json\nContent-Length: " + strconv.Itoa(len(jsonEvent)) + "\n\n" + jsonEvent, MessageLimits{MaxHeaders: 3}, LimitHeaders},
+		{"line length", "Content-Type: command/reply\nReply-Text: +OK " + strings.Repeat("a", 100) + "\n\n", MessageLimits{MaxLineLength: 64}, LimitLineLength},
+		{"line longer than buffer", "Content-Type: command/reply\nReply-Text: " + strings.Repeat("a", 2*ReadBufferSize) + "\n\n", MessageLimits{}, LimitLineLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newMessage(reader(tt.input), tt.limits).Parse()
+
+			var lerr *LimitError
+			if !errors.As(err, &lerr) || !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("Expected LimitError, got %v", err)
+			}
+
+			if lerr.Limit != tt.limit || lerr.Size <= lerr.Max {
+				t.Fatalf("Unexpected limit error %+v", lerr)
+			}
+
+			if errors.Is(err, ErrContentTooLarge) != (tt.limit == LimitBodySize) {
+				t.Fatalf("Only body size should be ErrContentTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageLimitsBoundary(t *testing.T) {
+	limits := MessageLimits{MaxBodySize: 11, MaxHeaders: 2, MaxLineLength: len("Content-Type: api/response")}
+
+	msg := newMessage(reader("Content-Type: api/response\r\nContent-Length: 11\r\n\r\nhello world"), limits)
+	if err := msg.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg.Body) != "hello world" {
+		t.Fatalf("Unexpected body %q", msg.Body)
+	}
+}
+
+func TestMessageLimitsTeardown(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	c.SetMessageLimits(MessageLimits{MaxBodySize: 16})
+
+	go c.Handle()
+
+	go sess.SendRaw("Content-Type: api/response\nContent-Length: 1024\n\n" + strings.Repeat("a", 1024))
+
+	_, err := c.ReadMsg()
+	if !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("Expected ErrContentTooLarge, got %v", err)
+	}
+
+	// Connection is closed right away, without reading the rest of the body
+	select {
+	case <-sess.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected connection to be closed")
+	}
+}
@@ -8,157 +8,641 @@ package goesl
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/textproto"
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Message - Freeswitch Message that is received by GoESL. Message struct is here to help with parsing message
 // and dumping its contents. In addition to that it's here to make sure received message is in fact message we wish/can support
 type Message struct {
 	Headers map[string]string
-	Body    []byte
 
-	r  *bufio.Reader
-	tr *textproto.Reader
+	// Body - Frame body as freeswitch sent it. Events (text/event-plain, text/event-json and text/event-xml alike)
+	// carry their headers within it, encoded in the format Content-Type says. Body of the event itself (e.g.
+	// BACKGROUND_JOB result) is returned by EventBody.
+	Body []byte
+
+	r         *bufio.Reader
+	buf       []byte
+	eventBody []byte
+	fields    []headerField
+	index     map[string]int
+	limits    MessageLimits
+	once      sync.Once
+	shared    int32
+}
+
+// valueEncoding - What it takes to decode received header value
+type valueEncoding uint8
+
+const (
+	valuePlain valueEncoding = iota
+	valuePercent
+	valueJSON
+)
+
+// headerField - Header as it was received. Name keeps its original case, key is what the header is stored under in
+// Headers: canonical for headers of the message itself (as textproto always did) and original case for event
+// headers (as json events always had). Raw values of event headers point into message body buffer (encoded) and are
+// only decoded once headers are looked at.
+type headerField struct {
+	name    string
+	key     string
+	raw     string
+	encoded []byte
+	enc     valueEncoding
+}
+
+// rawValue - Will return value as it was received. Json strings are not percent encoded, raw value is the string itself.
+func (f headerField) rawValue() string {
+	switch {
+	case f.enc == valueJSON:
+		return f.value()
+	case f.encoded != nil:
+		return string(f.encoded)
+	}
+
+	return f.raw
+}
+
+// value - Will return decoded value
+func (f headerField) value() string {
+	switch f.enc {
+	case valuePercent:
+		if f.encoded != nil {
+			return decodeHeaderValue(f.encoded)
+		}
+
+		return decodeHeaderValue([]byte(f.raw))
+	case valueJSON:
+		return decodeJSONString(f.encoded)
+	}
+
+	if f.encoded != nil {
+		return string(f.encoded)
+	}
+
+	return f.raw
+}
+
+// messagePool - Released messages waiting to be reused together with their Headers map and body buffer
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return &Message{Headers: make(map[string]string), index: make(map[string]int)}
+	},
 }
 
 // String - Will return message representation as string
 func (m *Message) String() string {
+	m.decode()
+
 	return fmt.Sprintf("%v body=%s", m.Headers, m.Body)
 }
 
-// GetCallUUID - Will return Caller-Unique-ID
+// EventBody - Will return body of the event itself (e.g. BACKGROUND_JOB result), empty in case event has none. Body of
+// events holds event headers as well, messages that are not events (and events built by hand) have it in Body.
+func (m *Message) EventBody() []byte {
+	if m.eventBody != nil {
+		return m.eventBody
+	}
+
+	return m.Body
+}
+
+// GetCallUUID - Will return Caller-Unique-ID, or Unique-ID of events that do not carry caller profile
 func (m *Message) GetCallUUID() string {
-	return m.GetHeader("Caller-Unique-ID")
+	if uuid := m.GetHeader("Caller-Unique-ID"); uuid != "" {
+		return uuid
+	}
+
+	return m.GetHeader("Unique-ID")
 }
 
-// GetHeader - Will return message header value, or "" if the key is not set.
+// GetHeader - Will return message header value, or "" if the key is not set. Keys are matched case-insensitively
+// so that Caller-Unique-ID and Caller-Unique-Id are the same header whatever format event came in.
 func (m *Message) GetHeader(key string) string {
-	return m.Headers[key]
+	m.decode()
+
+	if v, ok := m.Headers[key]; ok {
+		return v
+	}
+
+	if i, ok := m.index[foldHeaderName(key)]; ok {
+		return m.Headers[m.fields[i].key]
+	}
+
+	// Headers added to the map by hand are not indexed
+	if len(m.Headers) > len(m.fields) {
+		for k, v := range m.Headers {
+			if strings.EqualFold(k, key) {
+				return v
+			}
+		}
+	}
+
+	return ""
+}
+
+// header - Will return decoded value of received header without decoding the rest of them. It never touches
+// Headers, so reader can route message with it while message is already being looked at elsewhere.
+func (m *Message) header(key string) string {
+	if len(m.fields) == 0 {
+		return m.GetHeader(key)
+	}
+
+	if i, ok := m.index[foldHeaderName(key)]; ok {
+		return m.fields[i].value()
+	}
+
+	return ""
+}
+
+// decode - Will decode received headers into Headers. It happens once, the first time headers are looked at
+// (GetHeader, ReadMsg, handlers...), so messages nobody looks at are never decoded and reader does not spend its
+// time on it.
+func (m *Message) decode() {
+	m.once.Do(func() {
+		if len(m.fields) == 0 {
+			return
+		}
+
+		if m.Headers == nil {
+			m.Headers = make(map[string]string, len(m.fields))
+		}
+
+		for _, f := range m.fields {
+			m.Headers[f.key] = f.value()
+		}
+	})
+}
+
+// RawHeader - Will return header value as it was received, before percent decoding (e.g. "John%20Doe" instead of
+// "John Doe"). Keys are matched case-insensitively.
+func (m *Message) RawHeader(key string) string {
+	m.decode()
+
+	if i, ok := m.index[foldHeaderName(key)]; ok {
+		if _, ok := m.Headers[m.fields[i].key]; ok {
+			return m.fields[i].rawValue()
+		}
+	}
+
+	return m.GetHeader(key)
+}
+
+// HeaderNames - Will return header names, in their original case, in the order they were received. Headers added
+// to Headers map by hand come last, sorted.
+func (m *Message) HeaderNames() []string {
+	m.decode()
+
+	names := make([]string, 0, len(m.Headers))
+	received := make(map[string]bool, len(m.fields))
+
+	for _, f := range m.fields {
+		if _, ok := m.Headers[f.key]; ok {
+			names = append(names, f.name)
+			received[f.key] = true
+		}
+	}
+
+	if len(names) == len(m.Headers) {
+		return names
+	}
+
+	var added []string
+
+	for k := range m.Headers {
+		if !received[k] {
+			added = append(added, k)
+		}
+	}
+
+	sort.Strings(added)
+
+	return append(names, added...)
+}
+
+// setHeader - Will add received header keeping track of the order headers were received in. Header that is already
+// set, whatever the case of its name, is replaced but keeps its place.
+func (m *Message) setHeader(f headerField) {
+	if m.index == nil {
+		m.index = make(map[string]int)
+	}
+
+	folded := foldHeaderName(f.name)
+
+	if i, ok := m.index[folded]; ok {
+		m.fields[i] = f
+		return
+	}
+
+	m.index[folded] = len(m.fields)
+	m.fields = append(m.fields, f)
+}
+
+// addHeader - Will add header out of its percent encoded wire value. Owned values are part of message's own buffer
+// and can be referenced instead of copied.
+func (m *Message) addHeader(name, key string, v []byte, owned bool) {
+	f := headerField{name: name, key: key}
+
+	if bytes.IndexByte(v, '%') >= 0 {
+		f.enc = valuePercent
+	}
+
+	if owned {
+		f.encoded = v
+	} else {
+		f.raw = string(v)
+	}
+
+	m.setHeader(f)
+}
+
+// hasHeader - Will check if header was received, whatever the case of its name
+func (m *Message) hasHeader(name string) bool {
+	_, ok := m.index[foldHeaderName(name)]
+	return ok
+}
+
+// deleteHeader - Will remove received header
+func (m *Message) deleteHeader(name string) {
+	folded := foldHeaderName(name)
+
+	i, ok := m.index[folded]
+	if !ok {
+		return
+	}
+
+	m.fields = append(m.fields[:i], m.fields[i+1:]...)
+	delete(m.index, folded)
+
+	for j := i; j < len(m.fields); j++ {
+		m.index[foldHeaderName(m.fields[j].name)] = j
+	}
+}
+
+// resetHeaders - Will remove all of the headers
+func (m *Message) resetHeaders() {
+	for k := range m.Headers {
+		delete(m.Headers, k)
+	}
+
+	for k := range m.index {
+		delete(m.index, k)
+	}
+
+	m.fields = m.fields[:0]
+}
+
+// isEvent - Will check if message is an event (text/event-plain, text/event-json...) and not a reply. Json events
+// lose headers of the frame carrying them (Content-Type included), those are told by Event-Name alone.
+func isEvent(m *Message) bool {
+	if contentType := m.header("Content-Type"); contentType != "" {
+		return strings.HasPrefix(contentType, "text/event-")
+	}
+
+	return m.header("Event-Name") != ""
+}
+
+// isReply - Will tell whether message is freeswitch's reply to command we sent
+func isReply(m *Message) bool {
+	switch m.header("Content-Type") {
+	case "command/reply", "api/response":
+		return true
+	}
+
+	return false
 }
 
 // Parse - Will parse out message received from Freeswitch and basically build it accordingly for later use.
 // However, in case of any issues func will return error.
 func (m *Message) Parse() error {
 
-	cmr, err := m.tr.ReadMIMEHeader()
+	limits := m.limits.withDefaults()
+
+	err := m.readHeaders(limits)
 
 	if err != nil && err.Error() != "EOF" {
 		Error(ECouldNotReadMIMEHeaders, err)
 		return err
 	}
 
-	if cmr.Get("Content-Type") == "" {
+	msgType := m.header("Content-Type")
+
+	if msgType == "" {
 		Debug("Not accepting message because of empty content type. Just whatever with it ...")
 		return fmt.Errorf("Parse EOF")
 	}
 
 	// Will handle content length by checking if appropriate length is here and if it is then
 	// we are going to read it into body
-	if lv := cmr.Get("Content-Length"); lv != "" {
-		l, err := strconv.Atoi(lv)
+	if lv := m.header("Content-Length"); lv != "" {
+		if err := m.readBody(lv, limits); err != nil {
+			return err
+		}
+	}
 
-		if err != nil {
-			Error(EInvalidContentLength, err)
+	Debug("Got message content (type: %s). Searching if we can handle it ...", msgType)
+
+	if !StringInSlice(msgType, AvailableMessageTypes) {
+		return fmt.Errorf(EUnsupportedMessageType, msgType, AvailableMessageTypes)
+	}
+
+	switch msgType {
+	case "text/disconnect-notice":
+		for _, f := range m.fields {
+			Debug("Message (header: %s) -> (value: %v)", f.name, f.value())
+		}
+	case "command/reply":
+		reply := m.header("Reply-Text")
+
+		if strings.HasPrefix(reply, "-ERR") {
+			return &ReplyError{Reply: strings.TrimSpace(reply[4:])}
+		}
+	case "api/response":
+		if bytes.HasPrefix(m.Body, []byte("-ERR")) {
+			return &ReplyError{Reply: strings.TrimSpace(string(m.Body[4:]))}
+		}
+	case "text/event-json":
+		// Headers of the message itself (Content-Type, Content-Length) are not part of the event
+		m.resetHeaders()
+
+		if err := decodeEventJSON(m.Body, m); err != nil {
 			return err
 		}
 
-		m.Body = make([]byte, l)
+		// Header values still point into json body, event body gets its own buffer
+		m.eventBody = []byte(m.header("_body"))
+		m.deleteHeader("_body")
 
-		// TODO
-		// Add context here for timeout handling
-		// If bad Content-Length is passed
-		// this will hang forever
-		if _, err := io.ReadFull(m.r, m.Body); err != nil {
-			Error(ECouldNotReadyBody, err)
+	case "text/event-xml":
+		if err := m.parseEventXML(); err != nil {
 			return err
 		}
+
+	case "text/event-plain":
+		// Event headers are within the body, followed by event body in case event has one (BACKGROUND_JOB etc.)
+		if err := m.parseEventPlain(); err != nil {
+			Debug("Could not parse 'text/event-plain' body as event headers (%s). Leaving m.Body as is", err)
+		}
 	}
 
-	msgType := cmr.Get("Content-Type")
+	// Event headers come from the body, count could only be checked once they got decoded
+	if len(m.fields) > limits.MaxHeaders {
+		return &LimitError{Limit: LimitHeaders, Size: len(m.fields), Max: limits.MaxHeaders}
+	}
 
-	Debug("Got message content (type: %s). Searching if we can handle it ...", msgType)
+	return nil
+}
 
-	if !StringInSlice(msgType, AvailableMessageTypes) {
-		return fmt.Errorf(EUnsupportedMessageType, msgType, AvailableMessageTypes)
+// readHeaders - Will read message headers up to the empty line. They are stored under canonical keys (Job-Uuid),
+// same as textproto always did, and first value wins. Reading stops with *LimitError as soon as a line or header count crosses
+// the limits.
+func (m *Message) readHeaders(limits MessageLimits) error {
+	for count := 0; ; count++ {
+		line, err := m.readLine(limits.MaxLineLength)
+		if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			return nil
+		}
+
+		if count >= limits.MaxHeaders {
+			return &LimitError{Limit: LimitHeaders, Size: count + 1, Max: limits.MaxHeaders}
+		}
+
+		i := bytes.IndexByte(line, ':')
+		if i <= 0 || bytes.ContainsAny(line[:i], " \t") {
+			return textproto.ProtocolError("malformed MIME header line: " + string(line))
+		}
+
+		if name := headerName(line[:i]); !m.hasHeader(name) {
+			m.addHeader(name, textproto.CanonicalMIMEHeaderKey(name), bytes.Trim(line[i+1:], " \t"), false)
+		}
 	}
+}
+
+// readLine - Will read single line without its line ending. Line is only valid until next read.
+func (m *Message) readLine(max int) ([]byte, error) {
+	var line []byte
 
-	// Assing message headers IF message is not type of event-json
-	if msgType != "text/event-json" {
-		for k, v := range cmr {
+	for {
+		chunk, err := m.r.ReadSlice('\n')
 
-			m.Headers[k] = v[0]
+		// Line ending does not count against the limit
+		if n := len(line) + len(bytes.TrimRight(chunk, "\r\n")); n > max {
+			return nil, &LimitError{Limit: LimitLineLength, Size: n, Max: max}
+		}
 
-			// Will attempt to decode if % is discovered within the string itself
-			if strings.Contains(v[0], "%") {
-				m.Headers[k], err = url.QueryUnescape(v[0])
+		if line == nil && err != bufio.ErrBufferFull {
+			line = chunk
+		} else {
+			line = append(line, chunk...)
+		}
 
-				if err != nil {
-					Error(ECouldNotDecode, err)
-					continue
-				}
-			}
+		if err == bufio.ErrBufferFull {
+			continue
 		}
+
+		// Last line without line ending is still a line, EOF comes with the next read
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+
+		return bytes.TrimRight(line, "\r\n"), nil
 	}
+}
 
-	switch msgType {
-	case "text/disconnect-notice":
-		for k, v := range cmr {
-			Debug("Message (header: %s) -> (value: %v)", k, v)
+// readBody - Will read Content-Length bytes of body into m.Body reusing buffer of released message when possible
+func (m *Message) readBody(lv string, limits MessageLimits) error {
+	l, err := strconv.Atoi(lv)
+
+	if err != nil {
+		Error(EInvalidContentLength, err)
+		return err
+	}
+
+	if l < 0 {
+		Error(EInvalidContentLength, lv)
+		return fmt.Errorf(EInvalidContentLength, lv)
+	}
+
+	if l > limits.MaxBodySize {
+		err := &LimitError{Limit: LimitBodySize, Size: l, Max: limits.MaxBodySize}
+		Error(ECouldNotReadyBody, err)
+		return err
+	}
+
+	// TODO
+	// Add context here for timeout handling
+	// If bad Content-Length is passed
+	// this will hang forever
+	if l > ReadBufferSize {
+		// Large body grows as data arrives instead of trusting Content-Length up front, so a frame announcing
+		// more than it sends costs no more memory than was actually received
+		body := bytes.NewBuffer(make([]byte, 0, ReadBufferSize))
+		_, err = io.CopyN(body, m.r, int64(l))
+		m.Body = body.Bytes()
+	} else {
+		// Buffer is kept for messages read after this one is released
+		if l > cap(m.buf) {
+			m.buf = make([]byte, l)
 		}
-	case "command/reply":
-		reply := cmr.Get("Reply-Text")
 
-		if strings.Contains(reply, "-ERR") {
-			return fmt.Errorf(EUnsuccessfulReply, reply[5:])
+		m.Body = m.buf[:l]
+
+		// Only what was actually read is kept, reused buffer holds data of some other message
+		n, rerr := io.ReadFull(m.r, m.Body)
+		m.Body, err = m.Body[:n], rerr
+	}
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
-	case "api/response":
-		if strings.Contains(string(m.Body), "-ERR") {
-			return fmt.Errorf(EUnsuccessfulReply, string(m.Body)[5:])
+
+		Error(ECouldNotReadyBody, err)
+		return err
+	}
+
+	return nil
+}
+
+// parseEventPlain - Will add event headers found in text/event-plain body to m.Headers (keeping their original case,
+// same as text/event-json does). m.Body is left as freeswitch sent it, event body alone is available through
+// EventBody. Headers are left untouched unless the whole body could be parsed.
+func (m *Message) parseEventPlain() error {
+	rest, length, err := scanEventPlain(m.Body, nil)
+	if err != nil {
+		return err
+	}
+
+	body := m.Body[len(m.Body)-len(rest):][:0]
+
+	if length != "" {
+		l, err := strconv.Atoi(length)
+		if err != nil || l < 0 || l > len(rest) {
+			return fmt.Errorf(EInvalidContentLength, length)
 		}
-	case "text/event-json":
-		// OK, what is missing here is a way to interpret other JSON types - it expects string only (understandably
-		// because the FS events are generally "string: string") - extract into empty interface and migrate only strings.
-		// i.e. Event CHANNEL_EXECUTE_COMPLETE - "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]
-		var decoded map[string]interface{}
 
-		if err := json.Unmarshal(m.Body, &decoded); err != nil {
+		body = rest[:l]
+	}
+
+	scanEventPlain(m.Body, m)
+
+	m.eventBody = body
+
+	return nil
+}
+
+// scanEventPlain - Will go through event header lines at the beginning of text/event-plain body, adding them to
+// message m unless it is nil. Returns what follows the headers together with event's own Content-Length, which
+// is not added as it would take place of Content-Length of the message itself.
+func scanEventPlain(body []byte, m *Message) (rest []byte, length string, err error) {
+	for len(body) > 0 {
+		line := body
+
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line, body = body[:i], body[i+1:]
+		} else {
+			body = nil
+		}
+
+		line = bytes.TrimRight(line, "\r")
+
+		if len(line) == 0 {
+			break
+		}
+
+		i := bytes.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, "", fmt.Errorf("malformed event header line: %q", line)
+		}
+
+		k, v := line[:i], bytes.TrimLeft(line[i+1:], " ")
+
+		if string(k) == "Content-Length" {
+			length = string(v)
+			continue
+		}
+
+		if m != nil {
+			name := headerName(k)
+			m.addHeader(name, name, v, true)
+		}
+	}
+
+	return body, length, nil
+}
+
+// parseEventXML - Will add event headers found in text/event-xml body to m.Headers. m.Body is left as freeswitch
+// sent it, event body alone is available through EventBody. Body looks like <event><headers><Event-Name>...</Event-Name>...</headers><body>...</body></event>
+func (m *Message) parseEventXML() error {
+	d := xml.NewDecoder(bytes.NewReader(m.Body))
+	var headers [][2]string
+	body := []byte("")
+
+	var path []string
+	var text strings.Builder
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
 			return err
 		}
 
-		// Copy back in:
-		for k, v := range decoded {
-			switch v := v.(type) {
-			case string:
-				m.Headers[k] = v
-			default:
-				//delete(m.Headers, k)
-				Warn("Removed non-string property (%s)", k)
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch {
+			case len(path) == 3 && path[1] == "headers":
+				headers = append(headers, [2]string{t.Name.Local, text.String()})
+			case len(path) == 2 && path[1] == "body":
+				body = []byte(text.String())
 			}
-		}
 
-		if v := m.Headers["_body"]; v != "" {
-			m.Body = []byte(v)
-			delete(m.Headers, "_body")
-		} else {
-			m.Body = []byte("")
+			text.Reset()
+			path = path[:len(path)-1]
 		}
+	}
 
-	case "text/event-plain":
-		Debug("Content-Type is 'text/event-plain'. Nothing more to do with m.Body")
+	if len(headers) == 0 {
+		return fmt.Errorf("no event headers found in xml body")
+	}
+
+	for _, h := range headers {
+		m.setHeader(headerField{name: h[0], key: h[0], raw: h[1]})
 	}
 
+	m.deleteHeader("Content-Length")
+
+	m.eventBody = body
+
 	return nil
 }
 
 // Dump - Will return message prepared to be dumped out. It's like prettify message for output
 func (m *Message) Dump() (resp string) {
+	m.decode()
+
 	var keys []string
 
 	for k := range m.Headers {
@@ -176,21 +660,58 @@ func (m *Message) Dump() (resp string) {
 	return
 }
 
+// Release - Will hand message back to be reused for messages read later on, sparing allocations on busy connections.
+// Calling it is optional. Once released, message (its Headers and Body included) must not be used in any way.
+// Only the one message was handed to can release it, e.g. messages returned by ReadMsg and SendApi & co. Messages
+// handed to handlers (OnEvent & co) or ExecuteWait can be shared among them and are never released.
+func (m *Message) Release() {
+	if m == nil || atomic.LoadInt32(&m.shared) == 1 {
+		return
+	}
+
+	m.resetHeaders()
+
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+
+	m.Body = nil
+	m.eventBody = nil
+	m.r = nil
+	m.limits = MessageLimits{}
+	m.once = sync.Once{}
+
+	messagePool.Put(m)
+}
+
+// share - Will mark message as handed to more than one consumer, Release does nothing on it from now on
+func (m *Message) share() {
+	atomic.StoreInt32(&m.shared, 1)
+}
+
 // NewMessage - Will build and execute parsing against received freeswitch message.
 // As return will give brand new Message{} for you to use it.
 func NewMessage(r *bufio.Reader, autoParse bool) (*Message, error) {
 
-	msg := Message{
-		r:       r,
-		tr:      textproto.NewReader(r),
-		Headers: make(map[string]string),
-	}
+	msg := newMessage(r, MessageLimits{})
 
 	if autoParse {
-		if err := msg.Parse(); err != nil {
-			return &msg, err
+		err := msg.Parse()
+		msg.decode()
+
+		if err != nil {
+			return msg, err
 		}
 	}
 
-	return &msg, nil
+	return msg, nil
+}
+
+// newMessage - Will take message from the pool to be read from r within given limits
+func newMessage(r *bufio.Reader, limits MessageLimits) *Message {
+	msg := messagePool.Get().(*Message)
+	msg.r = r
+	msg.limits = limits
+
+	return msg
 }
@@ -2,6 +2,13 @@ package goesl
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/textproto"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -115,10 +122,538 @@ func TestMessageParse(t *testing.T) {
 	}
 }
 
-func TestString(t *testing.T) {}
+func TestString(t *testing.T) {
+	fsMsg, err := NewMessage(reader(EchoResponse), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := fsMsg.String(); !strings.Contains(s, "Content-Type:api/response") || !strings.HasSuffix(s, "body=hi") {
+		t.Fatalf("Unexpected string representation %q", s)
+	}
+}
+
+func TestGetCallUUID(t *testing.T) {
+	event := "Event-Name: CHANNEL_ANSWER\nUnique-ID: a\nCaller-Unique-ID: 7f4db78a-17d7-11dd-b7a0-db4edd065621\n\n"
+
+	fsMsg, err := NewMessage(reader("Content-Length: "+strconv.Itoa(len(event))+"\nContent-Type: text/event-plain\n\n"+event), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uuid := fsMsg.GetCallUUID(); uuid != "7f4db78a-17d7-11dd-b7a0-db4edd065621" {
+		t.Fatalf("Unexpected call uuid %q", uuid)
+	}
+
+	if uuid := (&Message{Headers: map[string]string{}}).GetCallUUID(); uuid != "" {
+		t.Fatalf("Expected no call uuid, got %q", uuid)
+	}
+}
+
+func TestGetHeader(t *testing.T) {
+	fsMsg, err := NewMessage(reader(ShutdownMessage), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := fsMsg.GetHeader("Event-Name"); v != "SHUTDOWN" {
+		t.Fatalf("Unexpected Event-Name %q", v)
+	}
+
+	// Percent encoded values are decoded
+	if v := fsMsg.GetHeader("Event-Date-GMT"); v != "Wed, 23 Jan 2008 18:48:13 GMT" {
+		t.Fatalf("Unexpected Event-Date-GMT %q", v)
+	}
+
+	if v := fsMsg.GetHeader("event-date-gmt"); v != "Wed, 23 Jan 2008 18:48:13 GMT" {
+		t.Fatalf("Expected case-insensitive lookup, got %q", v)
+	}
+
+	if v := fsMsg.GetHeader("Missing-Header"); v != "" {
+		t.Fatalf("Expected missing header to be empty, got %q", v)
+	}
+}
+
+func TestHeaderCaseAcrossFormats(t *testing.T) {
+	uuid := "0dd4e4f7-36ed-a04d-a8f7-7aebb683af50"
+	plain := "Event-Name: CHANNEL_ANSWER\nCaller-Unique-ID: " + uuid + "\nCaller-Caller-ID-Name: John%20Doe\n\n"
+	jsonEvent := `{"Event-Name":"CHANNEL_ANSWER","Caller-Unique-ID":"` + uuid + `","Caller-Caller-ID-Name":"John Doe"}`
+	xmlEvent := "<event><headers><Event-Name>CHANNEL_ANSWER</Event-Name><Caller-Unique-ID>" + uuid +
+		"</Caller-Unique-ID><Caller-Caller-ID-Name>John Doe</Caller-Caller-ID-Name></headers></event>"
+
+	frames := map[string]string{
+		// Channel data outbound connect is replied with comes in message headers themselves
+		"command/reply":    "Content-Type: command/reply\nReply-Text: +OK\n" + plain,
+		"text/event-plain": "Content-Length: " + strconv.Itoa(len(plain)) + "\nContent-Type: text/event-plain\n\n" + plain,
+		"text/event-json":  "Content-Length: " + strconv.Itoa(len(jsonEvent)) + "\nContent-Type: text/event-json\n\n" + jsonEvent,
+		"text/event-xml":   "Content-Length: " + strconv.Itoa(len(xmlEvent)) + "\nContent-Type: text/event-xml\n\n" + xmlEvent,
+	}
+
+	for format, frame := range frames {
+		fsMsg, err := NewMessage(reader(frame), true)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		// Headers of the message itself are stored canonical, same as textproto always did. Event headers keep
+		// their original case.
+		key := "Caller-Unique-ID"
+		if format == "command/reply" {
+			key = "Caller-Unique-Id"
+		}
+
+		if _, ok := fsMsg.Headers[key]; !ok {
+			t.Fatalf("%s: expected %s header, got %v", format, key, fsMsg.Headers)
+		}
+
+		if fsMsg.GetCallUUID() != uuid || fsMsg.GetHeader("CALLER-UNIQUE-ID") != uuid || fsMsg.GetHeader("Caller-Unique-Id") != uuid {
+			t.Fatalf("%s: unexpected call uuid %q", format, fsMsg.GetCallUUID())
+		}
+
+		if fsMsg.GetHeader("caller-caller-id-name") != "John Doe" {
+			t.Fatalf("%s: unexpected caller name %q", format, fsMsg.GetHeader("caller-caller-id-name"))
+		}
+	}
+}
+
+func TestRawHeader(t *testing.T) {
+	event := "Event-Name: CHANNEL_ANSWER\nUnique-ID: abc\nCaller-Caller-ID-Name: John%20Doe\nContent-Length: 2\n\nhi"
+
+	fsMsg, err := NewMessage(reader("Content-Length: "+strconv.Itoa(len(event))+"\nContent-Type: text/event-plain\n\n"+event), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := fsMsg.RawHeader("caller-caller-id-name"); v != "John%20Doe" {
+		t.Fatalf("Unexpected raw value %q", v)
+	}
+
+	if v := fsMsg.GetHeader("Caller-Caller-ID-Name"); v != "John Doe" {
+		t.Fatalf("Unexpected decoded value %q", v)
+	}
+
+	if v := fsMsg.RawHeader("Unique-ID"); v != "abc" {
+		t.Fatalf("Unexpected raw value %q", v)
+	}
+
+	// Events without caller profile still have call uuid
+	if uuid := fsMsg.GetCallUUID(); uuid != "abc" {
+		t.Fatalf("Unexpected call uuid %q", uuid)
+	}
+
+	// Content-Length is the one of the message, event's own is not added
+	if names := fsMsg.HeaderNames(); strings.Join(names, ",") != "Content-Length,Content-Type,Event-Name,Unique-ID,Caller-Caller-ID-Name" {
+		t.Fatalf("Unexpected header order %v", names)
+	}
+
+	fsMsg.Headers["X-Added"] = "1"
+	delete(fsMsg.Headers, "Unique-ID")
+
+	if names := fsMsg.HeaderNames(); strings.Join(names, ",") != "Content-Length,Content-Type,Event-Name,Caller-Caller-ID-Name,X-Added" {
+		t.Fatalf("Unexpected header order %v", names)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"negative content length", "Content-Type: api/response\nContent-Length: -1\n\n", nil},
+		{"invalid content length", "Content-Type: api/response\nContent-Length: two\n\nhi", nil},
+		{"content length overflow", "Content-Type: api/response\nContent-Length: 99999999999999999999\n\n", nil},
+		{"content too large", "Content-Type: api/response\nContent-Length: " + strconv.Itoa(MaxContentLength+1) + "\n\n", ErrContentTooLarge},
+		{"truncated body", "Content-Type: api/response\nContent-Length: 10\n\nhi", io.ErrUnexpectedEOF},
+		{"missing content type", "Content-Length: 2\n\nhi", nil},
+		{"unsupported content type", "Content-Type: text/html\nContent-Length: 2\n\nhi", nil},
+		{"invalid json", "Content-Type: text/event-json\nContent-Length: 2\n\n{\"", nil},
+		{"invalid xml", "Content-Type: text/event-xml\nContent-Length: 7\n\n<event>", nil},
+		{"malformed header", "Content-Type api/response\n\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMessage(reader(tt.input), true)
+			if err == nil {
+				t.Fatal("Expected error, got nothing")
+			}
 
-func TestGetCallUUID(t *testing.T) {}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParseLargeContentLength(t *testing.T) {
+	// Body announcing more than it has must not be allocated up front
+	allocs := testing.AllocsPerRun(10, func() {
+		NewMessage(reader("Content-Type: api/response\nContent-Length: "+strconv.Itoa(MaxContentLength)+"\n\nhi"), true)
+	})
+
+	if allocs > 100 {
+		t.Fatalf("Unexpected number of allocations %v", allocs)
+	}
+}
+
+func TestParseEventPlainContentLength(t *testing.T) {
+	event := "Event-Name: BACKGROUND_JOB\nContent-Length: 1000000\n\n+OK"
+	buf := reader("Content-Length: " + strconv.Itoa(len(event)) + "\nContent-Type: text/event-plain\n\n" + event)
+
+	fsMsg, err := NewMessage(buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestGetHeader(t *testing.T) {}
+	// Event headers could not be parsed out so body is left as is
+	if string(fsMsg.Body) != event {
+		t.Fatalf("Unexpected body %q", fsMsg.Body)
+	}
+}
+
+func FuzzNewMessage(f *testing.F) {
+	event := "Event-Name: BACKGROUND_JOB\nJob-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621\nJob-Command: originate\nContent-Length: 41\n\n+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n"
+	xmlEvent := "<event><headers><Event-Name>CHANNEL_ANSWER</Event-Name><Unique-ID>abc</Unique-ID></headers></event>"
+
+	for _, seed := range []string{
+		ShutdownMessage,
+		EchoResponse,
+		HeartbeatMessage,
+		"Content-Type: auth/request\n\n",
+		"Content-Type: command/reply\nReply-Text: +OK accepted\n\n",
+		"Content-Type: command/reply\nReply-Text: -ERR invalid\n\n",
+		"Content-Type: command/reply\nReply-Text: +OK Job-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621\nJob-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621\n\n",
+		"Content-Type: api/response\nContent-Length: 20\n\n-ERR no such channel",
+		"Content-Type: text/disconnect-notice\nContent-Disposition: disconnect\nContent-Length: 67\n\nDisconnected, goodbye.\nSee you at ClueCon! http://www.cluecon.com/\n",
+		"Content-Length: " + strconv.Itoa(len(event)) + "\nContent-Type: text/event-plain\n\n" + event,
+		"Content-Length: " + strconv.Itoa(len(xmlEvent)) + "\nContent-Type: text/event-xml\n\n" + xmlEvent,
+		"Content-Type: api/response\nContent-Length: -1\n\n",
+		"Content-Type: api/response\nContent-Length: 99999999999\n\n",
+		"Content-Type: text/event-plain\nContent-Length: 36\n\nEvent-Name: %zz\nContent-Length: 99\n\n",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fsMsg, err := NewMessage(bufio.NewReader(bytes.NewReader(data)), true)
+
+		if fsMsg == nil {
+			t.Fatalf("Got nil message (err: %v)", err)
+		}
+
+		// Messages coming from the pool must not carry anything over from previous inputs
+		defer fsMsg.Release()
+
+		if len(fsMsg.Body) > MaxContentLength || len(fsMsg.Body) > len(data) {
+			t.Fatalf("Body of %d bytes out of %d bytes of input", len(fsMsg.Body), len(data))
+		}
+
+		if err != nil {
+			return
+		}
+
+		if fsMsg.Headers == nil {
+			t.Fatal("Parsed message has no headers")
+		}
+
+		// Parsed message must be safe to look at
+		_ = fsMsg.String()
+		_ = fsMsg.Dump()
+		_ = fsMsg.GetCallUUID()
+	})
+}
+
+func TestNewMessageEventPlain(t *testing.T) {
+	event := "Event-Name: BACKGROUND_JOB\nJob-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621\nJob-Command: originate\nContent-Length: 41\n\n+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n"
+	buf := reader("Content-Length: " + strconv.Itoa(len(event)) + "\nContent-Type: text/event-plain\n\n" + event)
+
+	fsMsg, err := NewMessage(buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fsMsg.GetHeader("Event-Name") != "BACKGROUND_JOB" || fsMsg.GetHeader("Job-UUID") != "7f4db78a-17d7-11dd-b7a0-db4edd065621" {
+		t.Fatalf("Event headers not parsed out of body: %v", fsMsg.Headers)
+	}
+
+	// Body is left the way freeswitch sent it, same as it always was
+	if string(fsMsg.Body) != event {
+		t.Fatalf("Unexpected body %q", fsMsg.Body)
+	}
 
-func TestParse(t *testing.T) {}
+	if string(fsMsg.EventBody()) != "+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n" {
+		t.Fatalf("Unexpected event body %q", fsMsg.EventBody())
+	}
+
+	// Content-Length is the one of the message, not of the event
+	if fsMsg.GetHeader("Content-Length") != strconv.Itoa(len(event)) {
+		t.Fatalf("Unexpected Content-Length %q", fsMsg.GetHeader("Content-Length"))
+	}
+}
+
+func TestNewMessageEventBody(t *testing.T) {
+	result := "+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n"
+
+	events := map[string][2]string{
+		"text/event-json": {
+			`{"Event-Name":"BACKGROUND_JOB","Job-UUID":"7f4db78a","Content-Length":"41","_body":"+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n"}`,
+			`{"Event-Name":"HEARTBEAT","Job-UUID":"7f4db78a"}`,
+		},
+		"text/event-xml": {
+			"<event><headers><Event-Name>BACKGROUND_JOB</Event-Name><Job-UUID>7f4db78a</Job-UUID><Content-Length>41</Content-Length></headers><body>" + result + "</body></event>",
+			"<event><headers><Event-Name>HEARTBEAT</Event-Name><Job-UUID>7f4db78a</Job-UUID></headers></event>",
+		},
+	}
+
+	for format, bodies := range events {
+		for i, event := range bodies {
+			fsMsg, err := NewMessage(reader("Content-Length: "+strconv.Itoa(len(event))+"\nContent-Type: "+format+"\n\n"+event), true)
+			if err != nil {
+				t.Fatalf("%s: %s", format, err)
+			}
+
+			// Body means the same for every event format: frame body the way freeswitch sent it
+			if string(fsMsg.Body) != event {
+				t.Fatalf("%s: unexpected body %q", format, fsMsg.Body)
+			}
+
+			expected := result
+			if i == 1 {
+				expected = ""
+			}
+
+			if string(fsMsg.EventBody()) != expected {
+				t.Fatalf("%s: unexpected event body %q", format, fsMsg.EventBody())
+			}
+
+			// Headers are not looked at yet, appending to Body must not change them
+			fsMsg.Body = append(fsMsg.Body, "xxxxxxxxxxxxxxxxxxxxxxxx"...)
+
+			if fsMsg.GetHeader("Job-UUID") != "7f4db78a" {
+				t.Fatalf("%s: unexpected Job-UUID %q", format, fsMsg.GetHeader("Job-UUID"))
+			}
+		}
+	}
+}
+
+func TestMessageRelease(t *testing.T) {
+	r := reader(HeartbeatMessage + EchoResponse)
+
+	msg := newMessage(r, MessageLimits{})
+	if err := msg.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	msg.Release()
+
+	msg = newMessage(r, MessageLimits{})
+	if err := msg.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Whatever message got from the pool, nothing of heartbeat can be left in it
+	if len(msg.HeaderNames()) != 2 || msg.GetHeader("Content-Type") != "api/response" || string(msg.Body) != "hi" {
+		t.Fatalf("Unexpected message after release %v", msg)
+	}
+}
+
+func TestMessageReleaseShared(t *testing.T) {
+	msg := newMessage(reader(HeartbeatMessage), MessageLimits{})
+	if err := msg.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Handlers and waiters get the same message, none of them can hand it back to the pool
+	msg.share()
+	msg.Release()
+
+	if msg.GetHeader("Event-Name") != "HEARTBEAT" {
+		t.Fatalf("Shared message got released %v", msg)
+	}
+}
+
+func TestMessageCanonicalKeys(t *testing.T) {
+	fsMsg, err := NewMessage(reader("Content-Type: command/reply\nReply-Text: +OK Job-UUID: abc\nJob-UUID: abc\n\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fsMsg.Headers["Job-Uuid"] != "abc" || fsMsg.GetHeader("Job-UUID") != "abc" {
+		t.Fatalf("Unexpected headers %v", fsMsg.Headers)
+	}
+}
+
+// legacyParse - Parsing the way it was done before messages got pooled and decoded straight from the read buffer.
+// Kept as a baseline for benchmarks.
+func legacyParse(r *bufio.Reader) (*Message, error) {
+	m := &Message{Headers: make(map[string]string)}
+
+	cmr, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && err.Error() != "EOF" {
+		return nil, err
+	}
+
+	if lv := cmr.Get("Content-Length"); lv != "" {
+		l, err := strconv.Atoi(lv)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Body = make([]byte, l)
+		if _, err := io.ReadFull(r, m.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	msgType := cmr.Get("Content-Type")
+
+	if msgType != "text/event-json" {
+		for k, v := range cmr {
+			m.Headers[k] = v[0]
+
+			if strings.Contains(v[0], "%") {
+				if m.Headers[k], err = url.QueryUnescape(v[0]); err != nil {
+					continue
+				}
+			}
+		}
+	}
+
+	switch msgType {
+	case "text/event-json":
+		var decoded map[string]interface{}
+
+		if err := json.Unmarshal(m.Body, &decoded); err != nil {
+			return nil, err
+		}
+
+		for k, v := range decoded {
+			if v, ok := v.(string); ok {
+				m.Headers[k] = v
+			}
+		}
+
+		if v := m.Headers["_body"]; v != "" {
+			m.Body = []byte(v)
+			delete(m.Headers, "_body")
+		} else {
+			m.Body = []byte("")
+		}
+	case "text/event-plain":
+		legacyParseEventPlain(m)
+	}
+
+	return m, nil
+}
+
+// legacyParseEventPlain - Event headers were read out of text/event-plain body through yet another reader
+func legacyParseEventPlain(m *Message) error {
+	r := bufio.NewReader(bytes.NewReader(m.Body))
+	headers := make(map[string]string)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			break
+		}
+
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return errors.New("malformed event header line")
+		}
+
+		k, v := line[:i], strings.TrimLeft(line[i+1:], " ")
+
+		if strings.Contains(v, "%") {
+			if dv, err := url.QueryUnescape(v); err == nil {
+				v = dv
+			}
+		}
+
+		headers[k] = v
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	for k, v := range headers {
+		m.Headers[k] = v
+	}
+
+	m.Body = []byte("")
+
+	return nil
+}
+
+// benchmarkFrames - Will build reader returning frame over and over again
+func benchmarkFrames(b *testing.B, frame string) *bufio.Reader {
+	b.Helper()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+
+	return bufio.NewReaderSize(strings.NewReader(strings.Repeat(frame, b.N)), ReadBufferSize)
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	r := benchmarkFrames(b, HeartbeatMessage)
+
+	for i := 0; i < b.N; i++ {
+		msg := newMessage(r, MessageLimits{})
+		if err := msg.Parse(); err != nil {
+			b.Fatal(err)
+		}
+		msg.Release()
+	}
+}
+
+func BenchmarkParseJSONLegacy(b *testing.B) {
+	r := benchmarkFrames(b, HeartbeatMessage)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := legacyParse(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// plainChannelEvent - text/event-plain frame of channel event the way freeswitch sends it
+func plainChannelEvent() string {
+	event := "Event-Name: CHANNEL_ANSWER\nCore-UUID: 596ab2fd-14c5-44b5-a02b-93ffb7cd5dd6\nFreeSWITCH-Hostname: fs-server\n" +
+		"Event-Date-Local: 2023-10-03%2014%3A13%3A36\nEvent-Date-GMT: Tue,%2003%20Oct%202023%2021%3A13%3A36%20GMT\n" +
+		"Event-Calling-File: switch_channel.c\nEvent-Calling-Function: switch_channel_perform_mark_answered\n" +
+		"Channel-State: CS_EXECUTE\nChannel-Call-State: ACTIVE\nUnique-ID: 0dd4e4f7-36ed-a04d-a8f7-7aebb683af50\n" +
+		"Call-Direction: inbound\nAnswer-State: answered\nCaller-Caller-ID-Name: John%20Doe\nCaller-Caller-ID-Number: 1000\n" +
+		"Caller-Destination-Number: 5000\nCaller-Unique-ID: 0dd4e4f7-36ed-a04d-a8f7-7aebb683af50\n" +
+		"Caller-Channel-Name: sofia/internal/1000%40127.0.0.1\nvariable_sip_from_user: 1000\nvariable_sip_to_user: 5000\n\n"
+
+	return "Content-Length: " + strconv.Itoa(len(event)) + "\nContent-Type: text/event-plain\n\n" + event
+}
+
+func BenchmarkParsePlain(b *testing.B) {
+	r := benchmarkFrames(b, plainChannelEvent())
+
+	for i := 0; i < b.N; i++ {
+		msg := newMessage(r, MessageLimits{})
+		if err := msg.Parse(); err != nil {
+			b.Fatal(err)
+		}
+		msg.Release()
+	}
+}
+
+func BenchmarkParsePlainLegacy(b *testing.B) {
+	r := benchmarkFrames(b, plainChannelEvent())
+
+	for i := 0; i < b.N; i++ {
+		if _, err := legacyParse(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
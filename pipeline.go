@@ -0,0 +1,222 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pendingReply - Reply freeswitch owes us for a command we sent. Replies of commands sent with Send (Api, BgApi...)
+// have no channel and go to ReadMsg, the way they always did.
+type pendingReply struct {
+	ch chan replyResult
+}
+
+// replyResult - Reply (command/reply, api/response) or *ReplyError freeswitch answered command with
+type replyResult struct {
+	msg *Message
+	err error
+}
+
+// pipeline - Replies we are waiting on, in the order commands were written. Freeswitch answers commands on a
+// connection one by one in the order it got them, so the first reply read always belongs to the oldest of them.
+type pipeline struct {
+	sync.Mutex
+	pending []*pendingReply
+	err     error
+	done    chan struct{}
+	timeout time.Duration
+}
+
+func newPipeline() *pipeline {
+	return &pipeline{done: make(chan struct{})}
+}
+
+// push - Will queue reply slot. Fails once connection is gone as no reply would ever come.
+func (p *pipeline) push(r *pendingReply) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.pending = append(p.pending, r)
+
+	return nil
+}
+
+// remove - Will drop reply slot of command that could not be written
+func (p *pipeline) remove(r *pendingReply) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := range p.pending {
+		if p.pending[i] == r {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			break
+		}
+	}
+}
+
+// pop - Will return the oldest reply slot, nil in case we are not waiting on any reply
+func (p *pipeline) pop() *pendingReply {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	r := p.pending[0]
+	p.pending[0] = nil
+	p.pending = p.pending[1:]
+
+	return r
+}
+
+// close - Will fail all of the pending requests, and any request made from now on, with ErrConnectionClosed
+// caused by err. Waiters (ExecuteWait & co) are woken up through done.
+func (p *pipeline) close(err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.err != nil {
+		return
+	}
+
+	err = fmt.Errorf("%w: %v", ErrConnectionClosed, err)
+	p.err = err
+	close(p.done)
+
+	for _, r := range p.pending {
+		if r.ch != nil {
+			r.ch <- replyResult{err: err}
+		}
+	}
+
+	p.pending = nil
+}
+
+// complete - Called by reader for every reply (or -ERR reply). Returns false when reply is not awaited by request,
+// in which case it goes to ReadMsg.
+func (c *SocketConnection) complete(msg *Message, err error) bool {
+	if c.p == nil {
+		return false
+	}
+
+	r := c.p.pop()
+	if r == nil || r.ch == nil {
+		return false
+	}
+
+	// Buffered, request that timed out in the meantime simply never reads it
+	r.ch <- replyResult{msg: msg, err: err}
+
+	return true
+}
+
+// closed - Will tell whether connection is gone, e.g. Handle() stopped reading it
+func (c *SocketConnection) closed() bool {
+	if c.p == nil {
+		return false
+	}
+
+	c.p.Lock()
+	defer c.p.Unlock()
+
+	return c.p.err != nil
+}
+
+// SetRequestTimeout - Will limit how long SendApi, SendMsg, SendEvent & co wait for a reply. Zero (default) waits
+// until reply comes or connection is gone. Timeout of a single request can also be set with its *Context variant.
+// Can be changed at any time, requests already waiting keep the timeout they started with.
+func (c *SocketConnection) SetRequestTimeout(timeout time.Duration) {
+	if c.p == nil {
+		return
+	}
+
+	c.p.Lock()
+	defer c.p.Unlock()
+
+	c.p.timeout = timeout
+}
+
+// requestTimeout - Will return timeout set with SetRequestTimeout
+func (p *pipeline) requestTimeout() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.timeout
+}
+
+// send - Will queue reply slot and write command in one go, so that slots are in the same order as commands on
+// the wire no matter how many goroutines are sending
+func (c *SocketConnection) send(b []byte, r *pendingReply) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.p != nil {
+		if err := c.p.push(r); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.Write(b); err != nil {
+		if c.p != nil {
+			c.p.remove(r)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// request - Will send command and wait for its reply. Any number of goroutines can make requests at the same time,
+// each gets reply to its own command. In case freeswitch replies with -ERR, *ReplyError is returned.
+func (c *SocketConnection) request(ctx context.Context, line string, m *Message) (*Message, error) {
+	b, err := encodeCommand(line, m)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections put together by hand (no pipeline) read whatever reply comes next
+	if c.p == nil {
+		if err := c.write(b); err != nil {
+			return nil, err
+		}
+
+		return c.readReply()
+	}
+
+	r := &pendingReply{ch: make(chan replyResult, 1)}
+
+	if err := c.send(b, r); err != nil {
+		return nil, err
+	}
+
+	if timeout := c.p.requestTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case res := <-r.ch:
+		if res.msg != nil {
+			res.msg.decode()
+		}
+
+		return res.msg, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,260 @@
+package goesl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func TestPipelineConcurrent(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPIFunc(func(cmd string) (string, bool) {
+		if n := strings.TrimPrefix(cmd, "echo "); n != cmd {
+			return n + "\n", true
+		}
+		return "", false
+	})
+
+	srv.HandleCommand("sendmsg", func(s *esltest.Session, cmd esltest.Command) string {
+		return "+OK " + cmd.Args()
+	})
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events in between replies must not be taken for them
+	go func() {
+		for i := 0; i < 20; i++ {
+			sess.SendEventAs(esltest.FormatPlain, esltest.NewEvent("HEARTBEAT"))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 300)
+
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			n := strconv.Itoa(i)
+
+			switch i % 3 {
+			case 0:
+				m, err := c.SendApi("echo " + n)
+				if err != nil || string(m.Body) != n+"\n" {
+					errs <- fmt.Errorf("echo %s: got %v, %v", n, m, err)
+				}
+			case 1:
+				_, err := c.SendApi("fail " + n)
+				var rerr *ReplyError
+				if !errors.As(err, &rerr) || rerr.Reply != "fail Command not found!" {
+					errs <- fmt.Errorf("fail %s: got %v", n, err)
+				}
+			case 2:
+				m, err := c.SendMsg(map[string]string{"call-command": "hangup"}, "uuid-"+n, "")
+				if err != nil || m.GetHeader("Reply-Text") != "+OK uuid-"+n {
+					errs <- fmt.Errorf("sendmsg %s: got %v, %v", n, m, err)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if m, err := c.ReadMsg(); err != nil || m.GetHeader("Event-Name") != "HEARTBEAT" {
+			t.Fatalf("Expected HEARTBEAT, got %v, %v", m, err)
+		}
+	}
+}
+
+// fakeReplies - Will read commands off conn and hand their lines over to the test, which replies on its own
+func fakeReplies(conn net.Conn) <-chan string {
+	lines := make(chan string, 16)
+
+	go func() {
+		defer close(lines)
+
+		r := bufio.NewReader(conn)
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if line = strings.TrimSpace(line); line != "" {
+				lines <- line
+			}
+		}
+	}()
+
+	return lines
+}
+
+func apiResponse(conn net.Conn, body string) {
+	fmt.Fprintf(conn, "Content-Type: api/response\nContent-Length: %d\n\n%s", len(body), body)
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+
+	go c.Handle()
+
+	lines := fakeReplies(serverConn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.SendApiContext(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	result := make(chan *Message, 1)
+	go func() {
+		m, err := c.SendApi("fast")
+		if err != nil {
+			t.Error(err)
+		}
+		result <- m
+	}()
+
+	for _, expected := range []string{"api slow", "api fast"} {
+		if line := <-lines; line != expected {
+			t.Fatalf("Expected %q, got %q", expected, line)
+		}
+	}
+
+	// Late reply to the request that timed out is dropped, not handed to the next one
+	apiResponse(serverConn, "slow\n")
+	apiResponse(serverConn, "fast\n")
+
+	if m := <-result; m == nil || string(m.Body) != "fast\n" {
+		t.Fatalf("Expected reply to fast, got %v", m)
+	}
+
+	c.SetRequestTimeout(50 * time.Millisecond)
+
+	if _, err := c.SendApi("slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	// Timeout can be set while other requests are being made
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			c.SetRequestTimeout(50 * time.Millisecond)
+
+			if _, err := c.SendApi("slow"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected DeadlineExceeded, got %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestPipelineSendAndReadMsg(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+
+	go c.Handle()
+
+	lines := fakeReplies(serverConn)
+
+	// Reply to command sent with Send goes to ReadMsg, reply to SendApi right after it to SendApi
+	if err := c.Api("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan *Message, 1)
+	go func() {
+		m, _ := c.SendApi("second")
+		result <- m
+	}()
+
+	<-lines
+	<-lines
+
+	go func() {
+		apiResponse(serverConn, "first\n")
+		apiResponse(serverConn, "second\n")
+	}()
+
+	if m, err := c.ReadMsg(); err != nil || string(m.Body) != "first\n" {
+		t.Fatalf("Expected reply to first, got %v, %v", m, err)
+	}
+
+	if m := <-result; m == nil || string(m.Body) != "second\n" {
+		t.Fatalf("Expected reply to second, got %v", m)
+	}
+}
+
+func TestPipelineConnectionClosed(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+
+	c := newSocketConnection(clientConn)
+	defer c.Close()
+
+	go c.Handle()
+
+	lines := fakeReplies(serverConn)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.SendApi("status")
+		result <- err
+	}()
+
+	<-lines
+	serverConn.Close()
+
+	if err := <-result; !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Expected ErrConnectionClosed, got %v", err)
+	}
+
+	// Requests made afterwards fail right away
+	if _, err := c.SendApi("status"); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Expected ErrConnectionClosed, got %v", err)
+	}
+}
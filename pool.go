@@ -0,0 +1,276 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// PoolOptions - How many api connections pool keeps against freeswitch and how it treats them. Zero values fall back
+// to DefaultPoolSize and DefaultPoolRetryInterval. RequestTimeout is set on every api connection (SetRequestTimeout).
+type PoolOptions struct {
+	Size           int
+	RetryInterval  time.Duration
+	RequestTimeout time.Duration
+}
+
+// ClientPool - Authenticated inbound connections to the same freeswitch. Freeswitch runs api commands of a single
+// connection one after another, pool spreads them over Size connections so that slow ones (originate...) do not
+// hold up the rest. Dead connections are replaced in the background. Events are read from the separate connection
+// returned by Events().
+type ClientPool struct {
+	host    string
+	port    uint
+	passwd  string
+	timeout int
+	opts    PoolOptions
+
+	idle chan *Client
+	done chan struct{}
+	once sync.Once
+
+	mtx    sync.Mutex
+	events *Client
+}
+
+// NewClientPool - Will establish and authenticate api connections and events connection against freeswitch. In case
+// any of them fails, connections established so far are closed and error is returned.
+func NewClientPool(host string, port uint, passwd string, timeout int, opts PoolOptions) (*ClientPool, error) {
+	if opts.Size < 1 {
+		opts.Size = DefaultPoolSize
+	}
+
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultPoolRetryInterval
+	}
+
+	p := &ClientPool{
+		host:    host,
+		port:    port,
+		passwd:  passwd,
+		timeout: timeout,
+		opts:    opts,
+		idle:    make(chan *Client, opts.Size),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < opts.Size; i++ {
+		c, err := p.dial()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		p.put(c)
+	}
+
+	events, err := NewClient(host, port, passwd, timeout)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	p.events = events
+
+	return p, nil
+}
+
+// dial - Will establish api connection. Its replies go to requests and anything else that comes in is read and
+// dropped, so that connection never stalls on messages nobody reads.
+func (p *ClientPool) dial() (*Client, error) {
+	c, err := NewClient(p.host, p.port, p.passwd, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetRequestTimeout(p.opts.RequestTimeout)
+
+	go c.Handle()
+
+	go func() {
+		for {
+			if _, err := c.ReadMsg(); err != nil {
+				var rerr *ReplyError
+				if errors.As(err, &rerr) {
+					continue
+				}
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// SendApi - Will send api command over one of the idle connections and wait for its api/response
+func (p *ClientPool) SendApi(command string) (*Message, error) {
+	return p.SendApiContext(context.Background(), command)
+}
+
+// SendApiContext - Same as SendApi but gives up waiting for idle connection and response once ctx is done
+func (p *ClientPool) SendApiContext(ctx context.Context, command string) (*Message, error) {
+	c, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer p.release(c)
+
+	return c.SendApiContext(ctx, command)
+}
+
+// acquire - Will wait for idle connection that is still alive
+func (p *ClientPool) acquire(ctx context.Context) (*Client, error) {
+	for {
+		select {
+		case <-p.done:
+			return nil, ErrPoolClosed
+		default:
+		}
+
+		select {
+		case c := <-p.idle:
+			if !c.closed() {
+				return c, nil
+			}
+
+			p.release(c)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.done:
+			return nil, ErrPoolClosed
+		}
+	}
+}
+
+// release - Will put connection back to idle ones or, in case it died meanwhile, replace it
+func (p *ClientPool) release(c *Client) {
+	if c.closed() {
+		Warn("Pool connection to %s is gone. Replacing it ...", c.Addr)
+		c.Close()
+		go p.replace()
+		return
+	}
+
+	p.put(c)
+}
+
+// put - Will make connection idle or, in case pool got closed, close it
+func (p *ClientPool) put(c *Client) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	select {
+	case <-p.done:
+		c.Close()
+	default:
+		p.idle <- c
+	}
+}
+
+// replace - Will keep dialing until new connection is established or pool is closed
+func (p *ClientPool) replace() {
+	for {
+		c, err := p.dial()
+		if err == nil {
+			p.put(c)
+			return
+		}
+
+		Warn("Could not replace pool connection: %s. Retrying in %s", err, p.opts.RetryInterval)
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(p.opts.RetryInterval):
+		}
+	}
+}
+
+// Events - Will return connection dedicated to events. It is up to you to subscribe (event plain ALL...), register
+// handlers and run Handle() on it. Once connection is gone (Handle() returned) new one is established and returned,
+// subscriptions and handlers have to be set up on it again.
+func (p *ClientPool) Events() (*Client, error) {
+	if c, err := p.liveEvents(); c != nil || err != nil {
+		return c, err
+	}
+
+	// Dialing can take a while, pool must not be held up by it
+	c, err := NewClient(p.host, p.port, p.passwd, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	select {
+	case <-p.done:
+		c.Close()
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	// Someone else got there first
+	if p.events != nil && !p.events.closed() {
+		c.Close()
+		return p.events, nil
+	}
+
+	if p.events != nil {
+		p.events.Close()
+	}
+
+	p.events = c
+
+	return c, nil
+}
+
+// liveEvents - Will return events connection in case it's still alive, nil if new one has to be established
+func (p *ClientPool) liveEvents() (*Client, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	if p.events != nil && !p.events.closed() {
+		return p.events, nil
+	}
+
+	return nil, nil
+}
+
+// Close - Will close all of the connections. Requests in progress finish before their connection is closed.
+func (p *ClientPool) Close() {
+	p.once.Do(func() {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+
+		close(p.done)
+
+		for {
+			select {
+			case c := <-p.idle:
+				c.Close()
+				continue
+			default:
+			}
+			break
+		}
+
+		if p.events != nil {
+			p.events.Close()
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package goesl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+// poolServer - Will start fake freeswitch answering `api echo <n>` with n
+func poolServer() *esltest.Server {
+	srv := esltest.NewServer("")
+
+	srv.HandleAPIFunc(func(cmd string) (string, bool) {
+		if n := strings.TrimPrefix(cmd, "echo "); n != cmd {
+			return n + "\n", true
+		}
+		return "", false
+	})
+
+	return srv
+}
+
+// acceptSessions - Will accept n sessions, skipping their auth commands
+func acceptSessions(t *testing.T, srv *esltest.Server, n int) []*esltest.Session {
+	var sessions []*esltest.Session
+
+	for i := 0; i < n; i++ {
+		sess, err := srv.Accept(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sess.NextCommand(time.Second)
+		sessions = append(sessions, sess)
+	}
+
+	return sessions
+}
+
+func TestClientPool(t *testing.T) {
+	srv := poolServer()
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	pool, err := NewClientPool(host, port, esltest.DefaultPassword, 5, PoolOptions{Size: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// 3 api connections followed by events connection
+	sessions := acceptSessions(t, srv, 4)
+
+	// Idle connections are taken in turns
+	for i := 0; i < 6; i++ {
+		m, err := pool.SendApi("echo " + strconv.Itoa(i))
+		if err != nil || string(m.Body) != strconv.Itoa(i)+"\n" {
+			t.Fatalf("Unexpected response %v, %v", m, err)
+		}
+	}
+
+	for i, sess := range sessions[:3] {
+		for _, n := range []int{i, i + 3} {
+			cmd, err := sess.NextCommand(time.Second)
+			if err != nil || cmd.Line != "api echo "+strconv.Itoa(n) {
+				t.Fatalf("Expected connection %d to get echo %d, got %+v, %v", i, n, cmd, err)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func(n string) {
+			defer wg.Done()
+
+			m, err := pool.SendApi("echo " + n)
+			if err != nil || string(m.Body) != n+"\n" {
+				t.Errorf("Unexpected response to echo %s: %v, %v", n, m, err)
+			}
+		}(strconv.Itoa(i))
+	}
+
+	wg.Wait()
+
+	var rerr *ReplyError
+	if _, err := pool.SendApi("status"); !errors.As(err, &rerr) {
+		t.Fatalf("Expected ReplyError, got %v", err)
+	}
+
+	events, err := pool.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go events.Handle()
+
+	if err := sessions[3].SendEventAs(esltest.FormatPlain, esltest.NewEvent("HEARTBEAT")); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := events.ReadMsg(); err != nil || m.GetHeader("Event-Name") != "HEARTBEAT" {
+		t.Fatalf("Expected HEARTBEAT on events connection, got %v, %v", m, err)
+	}
+
+	// Events connection is replaced once it is gone
+	sessions[3].Close()
+
+	if _, err := events.ReadMsg(); err == nil {
+		t.Fatal("Expected events connection to be gone")
+	}
+
+	replaced, err := pool.Events()
+	if err != nil || replaced == events {
+		t.Fatalf("Expected new events connection, got %v", err)
+	}
+}
+
+func TestClientPoolReplacesDead(t *testing.T) {
+	srv := poolServer()
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	pool, err := NewClientPool(host, port, esltest.DefaultPassword, 5, PoolOptions{Size: 1, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	sessions := acceptSessions(t, srv, 2)
+	sessions[0].Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		m, err := pool.SendApi("echo 1")
+		if err == nil {
+			if string(m.Body) != "1\n" {
+				t.Fatalf("Unexpected response %q", m.Body)
+			}
+			break
+		}
+
+		if !errors.Is(err, ErrConnectionClosed) || time.Now().After(deadline) {
+			t.Fatalf("Expected dead connection to be replaced, got %v", err)
+		}
+	}
+
+	sess := acceptSessions(t, srv, 1)[0]
+
+	if cmd, err := sess.NextCommand(time.Second); err != nil || cmd.Line != "api echo 1" {
+		t.Fatalf("Expected replacement connection to get echo, got %+v, %v", cmd, err)
+	}
+}
+
+func TestClientPoolClose(t *testing.T) {
+	srv := poolServer()
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	pool, err := NewClientPool(host, port, esltest.DefaultPassword, 5, PoolOptions{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only connection is busy
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := pool.SendApiContext(ctx, "echo 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	pool.Close()
+	pool.release(c)
+
+	if _, err := c.SendApi("echo 1"); err == nil {
+		t.Fatal("Expected connection released after Close to be closed")
+	}
+
+	if _, err := pool.SendApi("echo 1"); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Expected ErrPoolClosed, got %v", err)
+	}
+
+	if _, err := pool.Events(); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Expected ErrPoolClosed, got %v", err)
+	}
+
+	if _, err := NewClientPool(host, port, "wrong", 5, PoolOptions{Size: 2}); err == nil {
+		t.Fatal("Expected pool with wrong password to fail")
+	}
+}
+
+func TestClientPoolEventsDialOutsideLock(t *testing.T) {
+	srv := poolServer()
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	pool, err := NewClientPool(host, port, esltest.DefaultPassword, 5, PoolOptions{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := acceptSessions(t, srv, 2)
+
+	// Freeswitch that accepts connection and never asks for auth
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conns <- conn
+		}
+	}()
+
+	_, hangingPort, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(hangingPort)
+	pool.port = uint(p)
+
+	sessions[1].Close()
+
+	events := make(chan error, 1)
+	go func() {
+		// Whatever it was handed before, new connection is never authenticated
+		for {
+			c, err := pool.Events()
+			if err != nil {
+				events <- err
+				return
+			}
+			c.Handle()
+		}
+	}()
+
+	var conn net.Conn
+	select {
+	case conn = <-conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected events connection to be dialed")
+	}
+
+	// Pool is not held up by events connection being dialed
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close got blocked by events connection being dialed")
+	}
+
+	conn.Close()
+
+	if err := <-events; err == nil {
+		t.Fatal("Expected events connection to fail")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy - What happens with new event when event queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock - Stop reading from freeswitch until there's room in the queue (default). Replies to SendApi & co
+	// are not read either meanwhile, so single slow handler/ReadMsg holds up the whole connection.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest - Drop oldest queued event to make room for the new one
+	OverflowDropOldest
+
+	// OverflowDropNewest - Drop the new event
+	OverflowDropNewest
+
+	// OverflowDisconnect - Close connection with ErrEventQueueOverflow
+	OverflowDisconnect
+)
+
+// EventQueueOptions - Limits of queue events are held in between being read from freeswitch and being handled
+// (handlers or ReadMsg). Replies and -ERR's nobody waits on (e.g. of commands sent with Send) wait in the same queue,
+// so ReadMsg gets them in the order they were read. Policy only ever applies to events, replies and errors are always
+// queued, even over Size, as whoever sent the command waits on them. Dropped events are logged (Warn) and counted in
+// EventQueueStats. OnHighWater is called, from reader goroutine, every time queue
+// grows to HighWater events.
+type EventQueueOptions struct {
+	Size        int
+	Policy      OverflowPolicy
+	HighWater   int
+	OnHighWater func(depth int)
+}
+
+// EventQueueStats - Current state of the event queue
+type EventQueueStats struct {
+	Depth   int
+	Dropped uint64
+}
+
+// queued - Event, reply or -ERR (*ReplyError) waiting in the queue
+type queued struct {
+	msg *Message
+	err error
+}
+
+// event - Will tell whether queued item is an event, the only kind of item overflow policy applies to
+func (it queued) event() bool {
+	return it.msg != nil && isEvent(it.msg)
+}
+
+// name - Will return what queued item is, for logging purposes
+func (it queued) name() string {
+	if it.msg == nil {
+		return "error"
+	}
+
+	if name := it.msg.header("Event-Name"); name != "" {
+		return name + " event"
+	}
+
+	return it.msg.header("Content-Type")
+}
+
+// eventQueue - Bounded FIFO of events applying overflow policy when full
+type eventQueue struct {
+	sync.Mutex
+	opts     EventQueueOptions
+	items    []queued
+	err      error
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	closed   bool
+	high     bool
+	dropped  uint64
+}
+
+func newEventQueue(opts EventQueueOptions) *eventQueue {
+	if opts.Size < 1 {
+		opts.Size = DefaultEventQueueSize
+	}
+
+	if opts.HighWater < 1 || opts.HighWater > opts.Size {
+		opts.HighWater = opts.Size
+	}
+
+	q := &eventQueue{opts: opts}
+	q.notEmpty = sync.NewCond(q)
+	q.notFull = sync.NewCond(q)
+
+	return q
+}
+
+// SetEventQueue - Will change limits of the event queue. Must be called before Handle().
+func (c *SocketConnection) SetEventQueue(opts EventQueueOptions) {
+	c.q = newEventQueue(opts)
+}
+
+// EventQueueStats - Will return current depth of the event queue and how many events were dropped so far
+func (c *SocketConnection) EventQueueStats() EventQueueStats {
+	if c.q == nil {
+		return EventQueueStats{}
+	}
+
+	c.q.Lock()
+	defer c.q.Unlock()
+
+	return EventQueueStats{
+		Depth:   len(c.q.items),
+		Dropped: atomic.LoadUint64(&c.q.dropped),
+	}
+}
+
+// push - Will queue item applying overflow policy to events. Only error returned is ErrEventQueueOverflow.
+func (q *eventQueue) push(it queued) error {
+	q.Lock()
+
+full:
+	for it.event() && len(q.items) >= q.opts.Size && !q.closed {
+		switch q.opts.Policy {
+		case OverflowDropOldest:
+			i := q.oldestEvent()
+			if i < 0 {
+				// Nothing but replies in the queue, they are never dropped
+				atomic.AddUint64(&q.dropped, 1)
+				q.Unlock()
+				Warn("Event queue is full. Dropped %s", it.name())
+				return nil
+			}
+
+			dropped := q.items[i]
+			copy(q.items[i:], q.items[i+1:])
+			q.items[len(q.items)-1] = queued{}
+			q.items = q.items[:len(q.items)-1]
+			atomic.AddUint64(&q.dropped, 1)
+			Warn("Event queue is full. Dropped oldest %s", dropped.name())
+
+			// Replies queued over size would otherwise get the rest of the events dropped
+			break full
+		case OverflowDropNewest:
+			atomic.AddUint64(&q.dropped, 1)
+			q.Unlock()
+			Warn("Event queue is full. Dropped %s", it.name())
+			return nil
+		case OverflowDisconnect:
+			q.Unlock()
+			return ErrEventQueueOverflow
+		default:
+			q.notFull.Wait()
+		}
+	}
+
+	if q.closed {
+		q.Unlock()
+		return nil
+	}
+
+	q.items = append(q.items, it)
+	q.notEmpty.Signal()
+
+	depth := len(q.items)
+	crossed := !q.high && depth >= q.opts.HighWater
+	if crossed {
+		q.high = true
+	}
+
+	q.Unlock()
+
+	if crossed && q.opts.OnHighWater != nil {
+		q.opts.OnHighWater(depth)
+	}
+
+	return nil
+}
+
+// oldestEvent - Will return index of the oldest queued event, -1 in case there is none. Must be called with lock held.
+func (q *eventQueue) oldestEvent() int {
+	for i, it := range q.items {
+		if it.event() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// pop - Will return next item, blocking until there is one. Once queue is closed and empty, error it was closed with
+// is returned, followed by false.
+func (q *eventQueue) pop() (queued, bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+
+	if len(q.items) == 0 {
+		if q.err != nil {
+			it := queued{err: q.err}
+			q.err = nil
+			return it, true
+		}
+
+		return queued{}, false
+	}
+
+	it := q.items[0]
+	q.items[0] = queued{}
+	q.items = q.items[1:]
+
+	// Let the next crossing of high water mark be reported once queue went back down to half of it
+	if q.high && len(q.items) <= q.opts.HighWater/2 {
+		q.high = false
+	}
+
+	q.notFull.Signal()
+
+	return it, true
+}
+
+// close - Will wake up everyone waiting on the queue. Items already queued can still be popped, followed by err
+// (reason connection is gone) if it is not nil.
+func (q *eventQueue) close(err error) {
+	q.Lock()
+	if !q.closed {
+		q.closed = true
+		q.err = err
+	}
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.Unlock()
+}
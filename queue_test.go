@@ -0,0 +1,285 @@
+package goesl
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+// event - Will build event message with given name
+func event(name string) *Message {
+	return &Message{Headers: map[string]string{"Content-Type": "text/event-plain", "Event-Name": name}}
+}
+
+// commandReply - Will build command/reply message nobody waits on (e.g. reply to Send)
+func commandReply(text string) *Message {
+	return &Message{Headers: map[string]string{"Content-Type": "command/reply", "Reply-Text": text}}
+}
+
+func TestEventQueueDropOldest(t *testing.T) {
+	q := newEventQueue(EventQueueOptions{Size: 2, Policy: OverflowDropOldest})
+
+	for _, name := range []string{"A", "B", "C"} {
+		if err := q.push(queued{msg: event(name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if q.dropped != 1 {
+		t.Fatalf("Expected 1 dropped event, got %d", q.dropped)
+	}
+
+	for _, name := range []string{"B", "C"} {
+		if it, _ := q.pop(); it.msg.GetHeader("Event-Name") != name {
+			t.Fatalf("Expected %s, got %s", name, it.msg.GetHeader("Event-Name"))
+		}
+	}
+}
+
+func TestEventQueueDropNewest(t *testing.T) {
+	q := newEventQueue(EventQueueOptions{Size: 2, Policy: OverflowDropNewest})
+
+	for _, name := range []string{"A", "B", "C"} {
+		if err := q.push(queued{msg: event(name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if q.dropped != 1 {
+		t.Fatalf("Expected 1 dropped event, got %d", q.dropped)
+	}
+
+	for _, name := range []string{"A", "B"} {
+		if it, _ := q.pop(); it.msg.GetHeader("Event-Name") != name {
+			t.Fatalf("Expected %s, got %s", name, it.msg.GetHeader("Event-Name"))
+		}
+	}
+}
+
+func TestEventQueueBlock(t *testing.T) {
+	q := newEventQueue(EventQueueOptions{Size: 1, Policy: OverflowBlock})
+	q.push(queued{msg: event("A")})
+
+	pushed := make(chan bool)
+	go func() {
+		q.push(queued{msg: event("B")})
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("Expected push to block while queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.pop()
+	<-pushed
+
+	if it, _ := q.pop(); it.msg.GetHeader("Event-Name") != "B" {
+		t.Fatalf("Expected B, got %s", it.msg.GetHeader("Event-Name"))
+	}
+
+	q.close(nil)
+	if _, ok := q.pop(); ok {
+		t.Fatal("Expected closed empty queue to return nothing")
+	}
+}
+
+func TestEventQueueKeepsReplies(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDropOldest, OverflowDropNewest, OverflowDisconnect} {
+		q := newEventQueue(EventQueueOptions{Size: 2, Policy: policy})
+
+		q.push(queued{msg: commandReply("+OK 1")})
+		q.push(queued{msg: event("A")})
+
+		for _, it := range []queued{{msg: commandReply("+OK 2")}, {err: &ReplyError{Reply: "-ERR"}}} {
+			if err := q.push(it); err != nil {
+				t.Fatalf("Policy %d: expected %s to be queued, got %s", policy, it.name(), err)
+			}
+		}
+
+		if len(q.items) != 4 || q.dropped != 0 {
+			t.Fatalf("Policy %d: expected replies to be queued over size, got %d items and %d dropped", policy, len(q.items), q.dropped)
+		}
+
+		err := q.push(queued{msg: event("B")})
+		if policy == OverflowDisconnect {
+			if !errors.Is(err, ErrEventQueueOverflow) {
+				t.Fatalf("Expected ErrEventQueueOverflow, got %v", err)
+			}
+			continue
+		}
+
+		// Oldest event goes, replies in front of it stay
+		expected := []string{"+OK 1", "+OK 2", "error", "B"}
+		if policy == OverflowDropNewest {
+			expected = []string{"+OK 1", "A", "+OK 2", "error"}
+		}
+
+		for _, name := range expected {
+			it, _ := q.pop()
+
+			got := it.name()
+			if it.msg != nil && it.msg.GetHeader("Reply-Text") != "" {
+				got = it.msg.GetHeader("Reply-Text")
+			} else if it.msg != nil {
+				got = it.msg.GetHeader("Event-Name")
+			}
+
+			if got != name {
+				t.Fatalf("Policy %d: expected %s, got %s", policy, name, got)
+			}
+		}
+	}
+}
+
+func TestEventQueueHighWater(t *testing.T) {
+	var depths []int
+	q := newEventQueue(EventQueueOptions{Size: 10, HighWater: 4, OnHighWater: func(depth int) {
+		depths = append(depths, depth)
+	}})
+
+	for i := 0; i < 6; i++ {
+		q.push(queued{msg: event("A")})
+	}
+
+	// Stays above half of high water, must not be reported again
+	q.pop()
+	q.push(queued{msg: event("A")})
+
+	for i := 0; i < 5; i++ {
+		q.pop()
+	}
+
+	for i := 0; i < 3; i++ {
+		q.push(queued{msg: event("A")})
+	}
+
+	if len(depths) != 2 || depths[0] != 4 || depths[1] != 4 {
+		t.Fatalf("Expected high water to be reported twice at depth 4, got %v", depths)
+	}
+}
+
+func TestEventQueueDisconnect(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := newSocketConnection(clientConn)
+	defer serverConn.Close()
+
+	c.SetEventQueue(EventQueueOptions{Size: 1, Policy: OverflowDisconnect})
+
+	go c.Handle()
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			writeEvent(serverConn, "Event-Name: HEARTBEAT")
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	for {
+		_, err := c.ReadMsg()
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, ErrEventQueueOverflow) {
+			t.Fatalf("Expected ErrEventQueueOverflow, got %v", err)
+		}
+
+		break
+	}
+}
+
+func TestEventQueueFullRepliesFlow(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("status", "UP\n")
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetEventQueue(EventQueueOptions{Size: 4, Policy: OverflowDropOldest})
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody reads ReadMsg, queue fills up
+	for i := 0; i < 20; i++ {
+		sess.SendEventAs(esltest.FormatPlain, esltest.NewEvent("HEARTBEAT"))
+	}
+
+	if m, err := c.SendApi("status"); err != nil || string(m.Body) != "UP\n" {
+		t.Fatalf("Expected api response while queue is full, got %v, %v", m, err)
+	}
+
+	if stats := c.EventQueueStats(); stats.Dropped == 0 {
+		t.Fatalf("Expected full queue dropping events, got %+v", stats)
+	}
+}
+
+func TestEventQueueCloseUnblocksPump(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan struct{})
+	go func() {
+		c.Handle()
+		close(handled)
+	}()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody ever reads ReadMsg
+	for i := 0; i < 5; i++ {
+		sess.SendEventAs(esltest.FormatPlain, esltest.NewEvent("HEARTBEAT"))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.EventQueueStats().Depth < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected events to pile up, got %+v", c.EventQueueStats())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Close()
+	<-handled
+
+	// Pump blocked on handing the first event to ReadMsg gives up and drains the rest
+	for c.EventQueueStats().Depth > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected pump to drain queue once connection is closed, got %+v", c.EventQueueStats())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := c.ReadMsg(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Expected ErrConnectionClosed, got %v", err)
+	}
+}
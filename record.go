@@ -0,0 +1,368 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Directions of recorded data
+const (
+	// RecordIn - Data freeswitch sent to us
+	RecordIn = "in"
+
+	// RecordOut - Data we sent to freeswitch
+	RecordOut = "out"
+)
+
+// ErrReplayMismatch - Data written to replayed connection is not what was recorded (ReplayOptions.Strict)
+var ErrReplayMismatch = errors.New("written data does not match recording")
+
+// RecordEntry - Single chunk of data read from or written to connection. Recordings are stored as JSON lines,
+// one entry per line. Data that is not valid UTF-8 is stored base64 encoded in Base64 instead of Data.
+type RecordEntry struct {
+	Time   time.Time `json:"time"`
+	Dir    string    `json:"dir"`
+	Data   string    `json:"data,omitempty"`
+	Base64 []byte    `json:"base64,omitempty"`
+}
+
+// Bytes - Will return recorded data
+func (e RecordEntry) Bytes() []byte {
+	if e.Base64 != nil {
+		return e.Base64
+	}
+
+	return []byte(e.Data)
+}
+
+// Recorder - Writes everything going through the connection as JSON lines. Safe for concurrent use.
+type Recorder struct {
+	mtx sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder - Will create recorder writing into w (e.g. *os.File)
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Err - Will return first error recorder got writing the recording. Recording stops at first error, connection
+// itself keeps working.
+func (r *Recorder) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.err
+}
+
+// write - Will record data going in given direction
+func (r *Recorder) write(dir string, data []byte) {
+	e := RecordEntry{Time: time.Now(), Dir: dir}
+
+	if utf8.Valid(data) {
+		e.Data = string(data)
+	} else {
+		e.Base64 = append([]byte(nil), data...)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if err := r.enc.Encode(e); err != nil {
+		r.err = err
+		Error("Could not write recording: %s", err)
+	}
+}
+
+// recordingConn - Connection recording everything that is read from and written to it
+type recordingConn struct {
+	net.Conn
+	r *Recorder
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.r.write(RecordIn, b[:n])
+	}
+
+	return n, err
+}
+
+// Write - Will record data before writing it. Reply to large write can be read before Write returns, recording it
+// afterwards would put reply in front of the command it answers.
+func (c *recordingConn) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		c.r.write(RecordOut, b)
+	}
+
+	return c.Conn.Write(b)
+}
+
+// Record - Will record everything read from and written to the connection from now on. Must be called before
+// Handle(). Client authentication happens before that, so passwords do not end up in recordings.
+func (c *SocketConnection) Record(r *Recorder) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.Conn = &recordingConn{Conn: c.Conn, r: r}
+}
+
+// ReadRecording - Will read all of the entries of JSON lines recording. Entries are decoded one after another
+// rather than line by line, so there is no limit on how large single recorded chunk can be.
+func ReadRecording(r io.Reader) ([]RecordEntry, error) {
+	var entries []RecordEntry
+
+	d := json.NewDecoder(r)
+
+	for i := 1; ; i++ {
+		var e RecordEntry
+		if err := d.Decode(&e); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+
+			return nil, fmt.Errorf("recording entry %d: %w", i, err)
+		}
+
+		if e.Dir != RecordIn && e.Dir != RecordOut {
+			return nil, fmt.Errorf("recording entry %d: unknown direction %q", i, e.Dir)
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+// ReplayOptions - How recording is replayed. Strict makes writes fail with ErrReplayMismatch unless they are
+// exactly what was recorded. Speed > 0 keeps recorded pauses between data freeswitch sent (2 being twice as fast),
+// 0 replays it as fast as possible.
+type ReplayOptions struct {
+	Strict bool
+	Speed  float64
+}
+
+// replayStep - Consecutive recorded chunks going in the same direction
+type replayStep struct {
+	dir  string
+	data []byte
+	at   time.Time
+}
+
+// ReplayConn - Connection playing freeswitch side of recording. Data freeswitch sent is handed out in recorded
+// order, each piece only once whatever we sent before it in the recording got written, so replies never come
+// before the commands they answer. Read returns io.EOF once recording is over.
+type ReplayConn struct {
+	opts  ReplayOptions
+	steps []*replayStep
+
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	next    int
+	off     int
+	started bool
+	last    time.Time
+	closed  bool
+	err     error
+}
+
+// NewReplayConn - Will create connection replaying recorded entries
+func NewReplayConn(entries []RecordEntry, opts ReplayOptions) *ReplayConn {
+	rc := &ReplayConn{opts: opts}
+	rc.cond = sync.NewCond(&rc.mtx)
+
+	for _, e := range entries {
+		if n := len(rc.steps); n > 0 && rc.steps[n-1].dir == e.Dir {
+			rc.steps[n-1].data = append(rc.steps[n-1].data, e.Bytes()...)
+			continue
+		}
+
+		rc.steps = append(rc.steps, &replayStep{dir: e.Dir, data: append([]byte(nil), e.Bytes()...), at: e.Time})
+	}
+
+	return rc
+}
+
+// NewReplayConnection - Will create connection replaying recording. Call Handle() on it and use it as if it was
+// connected to freeswitch.
+func NewReplayConnection(entries []RecordEntry, opts ReplayOptions) *SocketConnection {
+	c := newSocketConnection(NewReplayConn(entries, opts))
+	return &c
+}
+
+// Read - Will return data freeswitch sent, waiting for us to send whatever was sent before it in the recording
+func (rc *ReplayConn) Read(b []byte) (int, error) {
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+
+	for {
+		if rc.err != nil {
+			return 0, rc.err
+		}
+
+		if rc.closed {
+			return 0, net.ErrClosed
+		}
+
+		if rc.next >= len(rc.steps) {
+			return 0, io.EOF
+		}
+
+		if step := rc.steps[rc.next]; step.dir == RecordIn {
+			if rc.off == 0 {
+				rc.pace(step)
+			}
+
+			n := copy(b, step.data[rc.off:])
+			rc.advance(n)
+
+			return n, nil
+		}
+
+		rc.cond.Wait()
+	}
+}
+
+// pace - Will sleep for as long as freeswitch took to send step in the recording. Must be called with lock held.
+func (rc *ReplayConn) pace(step *replayStep) {
+	if rc.opts.Speed > 0 && rc.started && !step.at.IsZero() {
+		if d := time.Duration(float64(step.at.Sub(rc.last)) / rc.opts.Speed); d > 0 {
+			rc.mtx.Unlock()
+			time.Sleep(d)
+			rc.mtx.Lock()
+		}
+	}
+
+	rc.started = true
+	rc.last = step.at
+}
+
+// advance - Will mark n bytes of current step as done. Must be called with lock held.
+func (rc *ReplayConn) advance(n int) {
+	rc.off += n
+
+	if rc.off >= len(rc.steps[rc.next].data) {
+		rc.next++
+		rc.off = 0
+		rc.cond.Broadcast()
+	}
+}
+
+// Write - Will match written data against what was recorded. Data written when recording does not expect any
+// is dropped, or rejected with ErrReplayMismatch in strict mode.
+func (rc *ReplayConn) Write(b []byte) (int, error) {
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+
+	if rc.closed {
+		return 0, net.ErrClosed
+	}
+
+	if rc.err != nil {
+		return 0, rc.err
+	}
+
+	if rc.next >= len(rc.steps) || rc.steps[rc.next].dir != RecordOut {
+		if rc.opts.Strict {
+			return 0, rc.mismatch(b, nil)
+		}
+
+		return len(b), nil
+	}
+
+	step := rc.steps[rc.next]
+
+	if !rc.opts.Strict {
+		// Whatever we write now stands for the whole recorded step, encoding of commands is allowed to change
+		rc.advance(len(step.data) - rc.off)
+		return len(b), nil
+	}
+
+	written := 0
+
+	for written < len(b) {
+		if rc.next >= len(rc.steps) || rc.steps[rc.next].dir != RecordOut {
+			return written, rc.mismatch(b[written:], nil)
+		}
+
+		expected := rc.steps[rc.next].data[rc.off:]
+
+		n := len(b) - written
+		if n > len(expected) {
+			n = len(expected)
+		}
+
+		if !bytes.Equal(b[written:written+n], expected[:n]) {
+			return written, rc.mismatch(b[written:], expected)
+		}
+
+		written += n
+		rc.advance(n)
+	}
+
+	return written, nil
+}
+
+// mismatch - Will fail the replay. Must be called with lock held.
+func (rc *ReplayConn) mismatch(got []byte, expected []byte) error {
+	rc.err = fmt.Errorf("%w: wrote %q, expected %q", ErrReplayMismatch, got, expected)
+	rc.cond.Broadcast()
+
+	return rc.err
+}
+
+// Done - Will check if whole recording got replayed
+func (rc *ReplayConn) Done() bool {
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+
+	return rc.next >= len(rc.steps)
+}
+
+// Close - Will close the connection waking up anyone waiting on it
+func (rc *ReplayConn) Close() error {
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+
+	rc.closed = true
+	rc.cond.Broadcast()
+
+	return nil
+}
+
+// LocalAddr - Replayed connection has no real address
+func (rc *ReplayConn) LocalAddr() net.Addr { return replayAddr{} }
+
+// RemoteAddr - Replayed connection has no real address
+func (rc *ReplayConn) RemoteAddr() net.Addr { return replayAddr{} }
+
+// SetDeadline - Deadlines are not supported on replayed connection
+func (rc *ReplayConn) SetDeadline(t time.Time) error { return nil }
+
+// SetReadDeadline - Deadlines are not supported on replayed connection
+func (rc *ReplayConn) SetReadDeadline(t time.Time) error { return nil }
+
+// SetWriteDeadline - Deadlines are not supported on replayed connection
+func (rc *ReplayConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
@@ -0,0 +1,190 @@
+package goesl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("status", "UP 0 years, 0 days\n")
+
+	host, port := srv.HostPort()
+
+	client, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec bytes.Buffer
+	client.Record(NewRecorder(&rec))
+
+	go client.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SendApi("status"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sess.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := client.ReadMsg(); err != nil || m.GetHeader("Event-Name") != "CHANNEL_ANSWER" {
+		t.Fatalf("Expected CHANNEL_ANSWER, got %v, %v", m, err)
+	}
+
+	client.Close()
+
+	if strings.Contains(rec.String(), esltest.DefaultPassword) {
+		t.Fatal("Password ended up in the recording")
+	}
+
+	entries, err := ReadRecording(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) < 3 || entries[0].Dir != RecordOut || !strings.HasPrefix(entries[0].Data, "api status") || entries[0].Time.IsZero() {
+		t.Fatalf("Unexpected recording: %+v", entries)
+	}
+
+	// Replaying against handlers
+	c := NewReplayConnection(entries, ReplayOptions{Strict: true})
+	defer c.Close()
+
+	answered := make(chan string, 1)
+	c.OnEvent("CHANNEL_ANSWER", func(m *Message) {
+		answered <- m.GetHeader("Unique-ID")
+	})
+
+	go c.Handle()
+
+	m, err := c.SendApi("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(m.Body) != "UP 0 years, 0 days\n" {
+		t.Fatalf("Unexpected replayed response %q", m.Body)
+	}
+
+	select {
+	case uuid := <-answered:
+		if uuid != "abc" {
+			t.Fatalf("Unexpected event uuid %q", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Replayed event was not handled")
+	}
+}
+
+func TestReplayStrict(t *testing.T) {
+	entries := []RecordEntry{
+		{Dir: RecordOut, Data: "api status\r\n\r\n"},
+		{Dir: RecordIn, Data: "Content-Type: api/response\nContent-Length: 3\n\nUP\n"},
+	}
+
+	rc := NewReplayConn(entries, ReplayOptions{Strict: true})
+
+	if _, err := rc.Write([]byte("api uptime\r\n\r\n")); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("Expected ErrReplayMismatch, got %v", err)
+	}
+
+	if _, err := rc.Read(make([]byte, 16)); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("Expected reads to fail after mismatch, got %v", err)
+	}
+
+	// Not strict replay lets command encoding change
+	rc = NewReplayConn(entries, ReplayOptions{})
+
+	if _, err := rc.Write([]byte("api uptime\n\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 128)
+	n, err := rc.Read(b)
+	if err != nil || string(b[:n]) != entries[1].Data || !rc.Done() {
+		t.Fatalf("Unexpected read %q, %v", b[:n], err)
+	}
+}
+
+func TestRecordEntryBinary(t *testing.T) {
+	var rec bytes.Buffer
+
+	r := NewRecorder(&rec)
+	r.write(RecordIn, []byte{0xff, 0xfe, 'a'})
+	r.write(RecordOut, []byte("exit\n\n"))
+
+	entries, err := ReadRecording(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || !bytes.Equal(entries[0].Bytes(), []byte{0xff, 0xfe, 'a'}) || entries[1].Data != "exit\n\n" {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+}
+
+func TestRecordAndReplayLargeBody(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	// Sent in a single write, its recorded line grows well over 4MB once quotes and new lines are JSON escaped
+	body := strings.Repeat("\"line\"\n", MaxContentLength/2/7)
+
+	srv.HandleAPI("big", body)
+
+	host, port := srv.HostPort()
+
+	client, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec bytes.Buffer
+	client.Record(NewRecorder(&rec))
+
+	go client.Handle()
+
+	event := OutgoingEvent{Name: "CUSTOM", Subclass: "goesl::test", Body: body}
+
+	if _, err := client.SendEvent(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := client.SendApi("big"); err != nil || len(m.Body) != len(body) {
+		t.Fatalf("Expected %d bytes of api response, got %v", len(body), err)
+	}
+
+	client.Close()
+
+	entries, err := ReadRecording(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewReplayConnection(entries, ReplayOptions{Strict: true})
+	defer c.Close()
+
+	go c.Handle()
+
+	if _, err := c.SendEvent(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := c.SendApi("big"); err != nil || string(m.Body) != body {
+		t.Fatalf("Expected replayed api response to match, got %v", err)
+	}
+}
@@ -49,11 +49,7 @@ func (s *OutboundServer) Start() error {
 				break
 			}
 
-			conn := SocketConnection{
-				Conn: c,
-				err:  make(chan error),
-				m:    make(chan *Message),
-			}
+			conn := newSocketConnection(c)
 
 			Info("Got new connection from: %s", conn.OriginatorAddr())
 
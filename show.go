@@ -0,0 +1,302 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChannelRow - Single row of `show channels` output
+type ChannelRow struct {
+	UUID            string `json:"uuid"`
+	Direction       string `json:"direction"`
+	Created         string `json:"created"`
+	CreatedEpoch    string `json:"created_epoch"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	CIDName         string `json:"cid_name"`
+	CIDNum          string `json:"cid_num"`
+	IPAddr          string `json:"ip_addr"`
+	Dest            string `json:"dest"`
+	Application     string `json:"application"`
+	ApplicationData string `json:"application_data"`
+	Dialplan        string `json:"dialplan"`
+	Context         string `json:"context"`
+	ReadCodec       string `json:"read_codec"`
+	ReadRate        string `json:"read_rate"`
+	WriteCodec      string `json:"write_codec"`
+	WriteRate       string `json:"write_rate"`
+	Secure          string `json:"secure"`
+	Hostname        string `json:"hostname"`
+	PresenceID      string `json:"presence_id"`
+	Accountcode     string `json:"accountcode"`
+	CallState       string `json:"callstate"`
+	CalleeName      string `json:"callee_name"`
+	CalleeNum       string `json:"callee_num"`
+	CalleeDirection string `json:"callee_direction"`
+	CallUUID        string `json:"call_uuid"`
+}
+
+// CallRow - Single row of `show calls` output. Fields prefixed with B are about the other leg of the call.
+type CallRow struct {
+	UUID             string `json:"uuid"`
+	Direction        string `json:"direction"`
+	Created          string `json:"created"`
+	CreatedEpoch     string `json:"created_epoch"`
+	Name             string `json:"name"`
+	State            string `json:"state"`
+	CIDName          string `json:"cid_name"`
+	CIDNum           string `json:"cid_num"`
+	IPAddr           string `json:"ip_addr"`
+	Dest             string `json:"dest"`
+	PresenceID       string `json:"presence_id"`
+	Accountcode      string `json:"accountcode"`
+	CallState        string `json:"callstate"`
+	CalleeName       string `json:"callee_name"`
+	CalleeNum        string `json:"callee_num"`
+	CalleeDirection  string `json:"callee_direction"`
+	CallUUID         string `json:"call_uuid"`
+	Hostname         string `json:"hostname"`
+	BUUID            string `json:"b_uuid"`
+	BDirection       string `json:"b_direction"`
+	BCreated         string `json:"b_created"`
+	BCreatedEpoch    string `json:"b_created_epoch"`
+	BName            string `json:"b_name"`
+	BState           string `json:"b_state"`
+	BCIDName         string `json:"b_cid_name"`
+	BCIDNum          string `json:"b_cid_num"`
+	BIPAddr          string `json:"b_ip_addr"`
+	BDest            string `json:"b_dest"`
+	BCallState       string `json:"b_callstate"`
+	CallCreatedEpoch string `json:"call_created_epoch"`
+}
+
+// RegistrationRow - Single row of `show registrations` output
+type RegistrationRow struct {
+	RegUser      string `json:"reg_user"`
+	Realm        string `json:"realm"`
+	Token        string `json:"token"`
+	URL          string `json:"url"`
+	Expires      string `json:"expires"`
+	NetworkIP    string `json:"network_ip"`
+	NetworkPort  string `json:"network_port"`
+	NetworkProto string `json:"network_proto"`
+	Hostname     string `json:"hostname"`
+	Metadata     string `json:"metadata"`
+}
+
+// ModuleRow - Single row of `show modules` output
+type ModuleRow struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Ikey     string `json:"ikey"`
+	Filename string `json:"filename"`
+}
+
+// CodecRow - Single row of `show codecs` output
+type CodecRow struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Ikey string `json:"ikey"`
+}
+
+// showResult - Wrapper freeswitch puts around `show ... as json` rows. Rows are missing when row_count is 0.
+type showResult struct {
+	RowCount int             `json:"row_count"`
+	Rows     json.RawMessage `json:"rows"`
+}
+
+// show - Will issue `show <what> as json` and decode its rows into rows (pointer to slice). In case freeswitch
+// does not give us json back, we fall back to `show <what> as delim |`.
+func (sc *SocketConnection) show(ctx context.Context, what string, rows interface{}) error {
+	if _, err := apiArg(what); err != nil {
+		return err
+	}
+
+	m, err := sc.SendApiContext(ctx, "show "+what+" as json")
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(m.Body), []byte("{")) {
+		if err := ParseShow(m.Body, rows); err == nil {
+			return nil
+		}
+	}
+
+	Debug("show %s did not return json. Falling back to delimited output", what)
+
+	m, err = sc.SendApiContext(ctx, "show "+what+" as delim |")
+	if err != nil {
+		return err
+	}
+
+	if err := ParseShow(m.Body, rows); err != nil {
+		return fmt.Errorf(ECouldNotParseShow, what, err)
+	}
+
+	return nil
+}
+
+// ParseShow - Will decode output of show command into rows (pointer to slice of e.g. ChannelRow). Both json
+// output ({"row_count":N,"rows":[...]}) and delimited output (header line, rows, "N total.") are supported.
+func ParseShow(body []byte, rows interface{}) error {
+	trimmed := bytes.TrimSpace(body)
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var res showResult
+		if err := json.Unmarshal(trimmed, &res); err != nil {
+			return err
+		}
+
+		if res.RowCount == 0 || len(res.Rows) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(res.Rows, rows)
+	}
+
+	records, err := parseDelimited(string(trimmed), "")
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Going through json so that rows are filled by the very same json tags
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, rows)
+}
+
+// parseDelimited - Will parse comma or | delimited show output into records keyed by header names. Values are
+// expected not to contain delimiter, except for the free column (last one in case free is empty) which gets
+// whatever is left once the rest of the columns are split off both ends of the line.
+func parseDelimited(out string, free string) ([]map[string]string, error) {
+	var lines []string
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if line == "" || strings.HasSuffix(line, " total.") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(lines[0], "-ERR") || strings.HasPrefix(lines[0], "-USAGE") {
+		return nil, &ReplyError{Reply: lines[0]}
+	}
+
+	delim := ","
+	if strings.Contains(lines[0], "|") {
+		delim = "|"
+	}
+
+	header := strings.Split(lines[0], delim)
+	records := make([]map[string]string, 0, len(lines)-1)
+
+	f := len(header) - 1
+	for i, name := range header {
+		if name == free {
+			f = i
+		}
+	}
+
+	for _, line := range lines[1:] {
+		values := strings.Split(line, delim)
+		if len(values) < len(header) {
+			return nil, fmt.Errorf("expected %d columns, got %d in line: %q", len(header), len(values), line)
+		}
+
+		// Delimiters over the header count are part of the free column
+		if extra := len(values) - len(header); extra > 0 {
+			cols := make([]string, 0, len(header))
+			cols = append(cols, values[:f]...)
+			cols = append(cols, strings.Join(values[f:f+extra+1], delim))
+			values = append(cols, values[f+extra+1:]...)
+		}
+
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			record[name] = values[i]
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// ShowChannels - Will return all of the channels freeswitch currently has (`show channels`)
+func (sc *SocketConnection) ShowChannels() ([]ChannelRow, error) {
+	return sc.ShowChannelsContext(context.Background())
+}
+
+// ShowChannelsContext - Same as ShowChannels but gives up waiting for response once ctx is done
+func (sc *SocketConnection) ShowChannelsContext(ctx context.Context) ([]ChannelRow, error) {
+	rows := []ChannelRow{}
+	if err := sc.show(ctx, "channels", &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// ShowCalls - Will return all of the calls (bridged channel pairs) freeswitch currently has (`show calls`)
+func (sc *SocketConnection) ShowCalls() ([]CallRow, error) {
+	rows := []CallRow{}
+	if err := sc.show(context.Background(), "calls", &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// ShowRegistrations - Will return all of the SIP registrations freeswitch keeps in its core db (`show registrations`)
+func (sc *SocketConnection) ShowRegistrations() ([]RegistrationRow, error) {
+	rows := []RegistrationRow{}
+	if err := sc.show(context.Background(), "registrations", &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// ShowModules - Will return all of the loaded modules (`show modules`)
+func (sc *SocketConnection) ShowModules() ([]ModuleRow, error) {
+	rows := []ModuleRow{}
+	if err := sc.show(context.Background(), "modules", &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// ShowCodecs - Will return all of the available codecs (`show codecs`)
+func (sc *SocketConnection) ShowCodecs() ([]CodecRow, error) {
+	rows := []CodecRow{}
+	if err := sc.show(context.Background(), "codecs", &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,79 @@
+package goesl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseShowJSON(t *testing.T) {
+	body := `{"row_count":1,"rows":[{"type":"codec","name":"PCMU","ikey":"CORE_PCM_MODULE"}]}`
+
+	var rows []CodecRow
+	if err := ParseShow([]byte(body), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].Name != "PCMU" || rows[0].Ikey != "CORE_PCM_MODULE" {
+		t.Fatalf("Unexpected rows: %+v", rows)
+	}
+}
+
+func TestParseShowEmpty(t *testing.T) {
+	for _, body := range []string{`{"row_count":0}`, "\n0 total.\n", "uuid,direction,created\n\n0 total.\n"} {
+		rows := []ChannelRow{}
+		if err := ParseShow([]byte(body), &rows); err != nil {
+			t.Fatalf("Got error parsing %q: %s", body, err)
+		}
+
+		if len(rows) != 0 {
+			t.Fatalf("Expected no rows out of %q, got %+v", body, rows)
+		}
+	}
+}
+
+func TestParseShowCSV(t *testing.T) {
+	body := "type,name,ikey,filename\n" +
+		"api,uuid_kill,mod_commands,/usr/lib/freeswitch/mod/mod_commands.so\n" +
+		"application,playback,mod_dptools,/usr/lib/freeswitch/mod/mod_dptools.so\n" +
+		"\n2 total.\n"
+
+	var rows []ModuleRow
+	if err := ParseShow([]byte(body), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[1].Type != "application" || rows[1].Name != "playback" || rows[1].Filename != "/usr/lib/freeswitch/mod/mod_dptools.so" {
+		t.Fatalf("Unexpected rows: %+v", rows)
+	}
+}
+
+func TestShowFallback(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		if strings.HasSuffix(cmd, "as json") {
+			return "reg_user,realm\n"
+		}
+
+		return "reg_user|realm|token|url|expires|network_ip|network_port|network_proto|hostname|metadata\n" +
+			"1000|example.com|abc|sofia/internal/sip:1000@10.0.0.2:5060|1696367916|10.0.0.2|5060|udp|fs-server|\n" +
+			"\n1 total.\n"
+	})
+
+	go c.Handle()
+
+	rows, err := c.ShowRegistrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].RegUser != "1000" || rows[0].NetworkIP != "10.0.0.2" || rows[0].URL != "sofia/internal/sip:1000@10.0.0.2:5060" {
+		t.Fatalf("Unexpected rows: %+v", rows)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "show registrations as json" {
+		t.Fatalf("Expected json to be tried first, got %q", cmd)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "show registrations as delim |" {
+		t.Fatalf("Expected fallback to delimited output, got %q", cmd)
+	}
+}
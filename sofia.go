@@ -0,0 +1,292 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SofiaStatusEntry - Single row of `sofia status`. Type is one of profile, gateway or alias.
+type SofiaStatusEntry struct {
+	Name  string `xml:"name"`
+	Type  string `xml:"type"`
+	Data  string `xml:"data"`
+	State string `xml:"state"`
+}
+
+// SofiaGateway - Gateway as reported by `sofia xmlstatus gateway`. PingTime is in milliseconds.
+type SofiaGateway struct {
+	Name           string  `xml:"name"`
+	Profile        string  `xml:"profile"`
+	Scheme         string  `xml:"scheme"`
+	Realm          string  `xml:"realm"`
+	Username       string  `xml:"username"`
+	From           string  `xml:"from"`
+	Contact        string  `xml:"contact"`
+	Exten          string  `xml:"exten"`
+	To             string  `xml:"to"`
+	Proxy          string  `xml:"proxy"`
+	Context        string  `xml:"context"`
+	Expires        int     `xml:"expires"`
+	Freq           int     `xml:"freq"`
+	Ping           int64   `xml:"ping"`
+	PingFreq       int     `xml:"pingfreq"`
+	PingMin        int     `xml:"pingmin"`
+	PingCount      int     `xml:"pingcount"`
+	PingMax        int     `xml:"pingmax"`
+	PingTime       float64 `xml:"pingtime"`
+	Pinging        int     `xml:"pinging"`
+	State          string  `xml:"state"`
+	Status         string  `xml:"status"`
+	UptimeUsec     int64   `xml:"uptime-usec"`
+	CallsIn        int     `xml:"calls-in"`
+	CallsOut       int     `xml:"calls-out"`
+	FailedCallsIn  int     `xml:"failed-calls-in"`
+	FailedCallsOut int     `xml:"failed-calls-out"`
+}
+
+// Up - Will check if gateway is considered up by freeswitch
+func (g SofiaGateway) Up() bool {
+	return g.Status == "UP"
+}
+
+// Uptime - Will return for how long gateway has been up
+func (g SofiaGateway) Uptime() time.Duration {
+	return time.Duration(g.UptimeUsec) * time.Microsecond
+}
+
+// SofiaRegistration - Registration as reported by `sofia xmlstatus profile <name> reg`. Expires is how long
+// registration had left at the moment freeswitch answered, Expiry is when it expires in freeswitch local time.
+// PingTime is in milliseconds.
+type SofiaRegistration struct {
+	CallID       string  `xml:"call-id"`
+	User         string  `xml:"user"`
+	Contact      string  `xml:"contact"`
+	Agent        string  `xml:"agent"`
+	Status       string  `xml:"status"`
+	PingStatus   string  `xml:"ping-status"`
+	PingTime     float64 `xml:"ping-time"`
+	Host         string  `xml:"host"`
+	NetworkIP    string  `xml:"network-ip"`
+	NetworkPort  int     `xml:"network-port"`
+	SIPAuthUser  string  `xml:"sip-auth-user"`
+	SIPAuthRealm string  `xml:"sip-auth-realm"`
+	MWIAccount   string  `xml:"mwi-account"`
+
+	Expires time.Duration `xml:"-"`
+	Expiry  time.Time     `xml:"-"`
+}
+
+var (
+	expSecsRegexp = regexp.MustCompile(`EXPSECS\((\d+)\)`)
+	expRegexp     = regexp.MustCompile(`EXP\(([^)]+)\)`)
+)
+
+// sofiaStatus - Root of `sofia xmlstatus`. Profiles, gateways and aliases are siblings there.
+type sofiaStatus struct {
+	Entries []SofiaStatusEntry `xml:",any"`
+}
+
+type sofiaGateways struct {
+	Gateways []SofiaGateway `xml:"gateway"`
+}
+
+type sofiaRegistrations struct {
+	Registrations []SofiaRegistration `xml:"registrations>registration"`
+}
+
+// decodeXMLReply - Will decode xml output of api command (sofia xmlstatus, fifo list...). Anything that is not xml
+// is what freeswitch had to say about our command (Invalid Profile!, Invalid Gateway! etc) and is returned as *ReplyError.
+func decodeXMLReply(body []byte, v interface{}) error {
+	trimmed := bytes.TrimSpace(body)
+
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return &ReplyError{Reply: string(trimmed)}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(trimmed))
+
+	// Freeswitch claims ISO-8859-1, content we care about is plain ascii
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	return d.Decode(v)
+}
+
+// ParseSofiaStatus - Will parse output of `sofia xmlstatus` or, if it's not xml, the `sofia status` table
+func ParseSofiaStatus(body []byte) ([]SofiaStatusEntry, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		return parseSofiaStatusTable(string(body)), nil
+	}
+
+	var status sofiaStatus
+	if err := decodeXMLReply(body, &status); err != nil {
+		return nil, err
+	}
+
+	return status.Entries, nil
+}
+
+// parseSofiaStatusTable - Will parse `sofia status` table. Name, type and data never contain spaces, state might.
+func parseSofiaStatusTable(out string) []SofiaStatusEntry {
+	entries := []SofiaStatusEntry{}
+	inTable := false
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "=====") {
+			inTable = !inTable
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if !inTable || len(fields) < 4 {
+			continue
+		}
+
+		entries = append(entries, SofiaStatusEntry{
+			Name:  fields[0],
+			Type:  fields[1],
+			Data:  fields[2],
+			State: strings.Join(fields[3:], " "),
+		})
+	}
+
+	return entries
+}
+
+// ParseSofiaGateways - Will parse output of `sofia xmlstatus gateway` (all gateways) or `sofia xmlstatus gateway <name>`
+func ParseSofiaGateways(body []byte) ([]SofiaGateway, error) {
+	trimmed := bytes.TrimSpace(body)
+
+	// Single gateway is not wrapped into <gateways>
+	if bytes.Contains(trimmed, []byte("<gateways")) {
+		var gws sofiaGateways
+		if err := decodeXMLReply(trimmed, &gws); err != nil {
+			return nil, err
+		}
+
+		return gws.Gateways, nil
+	}
+
+	var gw SofiaGateway
+	if err := decodeXMLReply(trimmed, &gw); err != nil {
+		return nil, err
+	}
+
+	return []SofiaGateway{gw}, nil
+}
+
+// ParseSofiaRegistrations - Will parse output of `sofia xmlstatus profile <name> reg`
+func ParseSofiaRegistrations(body []byte) ([]SofiaRegistration, error) {
+	var regs sofiaRegistrations
+	if err := decodeXMLReply(body, &regs); err != nil {
+		return nil, err
+	}
+
+	for i, reg := range regs.Registrations {
+		if m := expSecsRegexp.FindStringSubmatch(reg.Status); m != nil {
+			secs, _ := strconv.Atoi(m[1])
+			regs.Registrations[i].Expires = time.Duration(secs) * time.Second
+		}
+
+		if m := expRegexp.FindStringSubmatch(reg.Status); m != nil {
+			if t, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local); err == nil {
+				regs.Registrations[i].Expiry = t
+			}
+		}
+	}
+
+	if regs.Registrations == nil {
+		return []SofiaRegistration{}, nil
+	}
+
+	return regs.Registrations, nil
+}
+
+// SofiaStatus - Will return all of the sofia profiles, gateways and aliases (`sofia xmlstatus`)
+func (sc *SocketConnection) SofiaStatus() ([]SofiaStatusEntry, error) {
+	m, err := sc.SendApi("sofia xmlstatus")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSofiaStatus(m.Body)
+}
+
+// SofiaProfiles - Will return sofia profiles only (`sofia xmlstatus` rows of type profile)
+func (sc *SocketConnection) SofiaProfiles() ([]SofiaStatusEntry, error) {
+	entries, err := sc.SofiaStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []SofiaStatusEntry{}
+	for _, e := range entries {
+		if e.Type == "profile" {
+			profiles = append(profiles, e)
+		}
+	}
+
+	return profiles, nil
+}
+
+// SofiaGateways - Will return status of all the gateways of all the profiles (`sofia xmlstatus gateway`)
+func (sc *SocketConnection) SofiaGateways() ([]SofiaGateway, error) {
+	m, err := sc.SendApi("sofia xmlstatus gateway")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSofiaGateways(m.Body)
+}
+
+// SofiaGateway - Will return status of single gateway (`sofia xmlstatus gateway <name>`)
+func (sc *SocketConnection) SofiaGateway(name string) (*SofiaGateway, error) {
+	cmd, err := apiCommand("sofia xmlstatus gateway", []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	gws, err := ParseSofiaGateways(m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(gws) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchGateway, name)
+	}
+
+	return &gws[0], nil
+}
+
+// SofiaRegistrations - Will return registrations of sofia profile (`sofia xmlstatus profile <name> reg`)
+func (sc *SocketConnection) SofiaRegistrations(profile string) ([]SofiaRegistration, error) {
+	cmd, err := apiCommand("sofia xmlstatus profile", []string{profile, "reg"})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSofiaRegistrations(m.Body)
+}
@@ -0,0 +1,167 @@
+package goesl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestParseSofiaStatus(t *testing.T) {
+	for _, name := range []string{"sofia_xmlstatus.xml", "sofia_status.txt"} {
+		entries, err := ParseSofiaStatus(fixture(t, name))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		expected := []SofiaStatusEntry{
+			{Name: "external", Type: "profile", Data: "sip:mod_sofia@192.168.0.1:5080", State: "RUNNING (0)"},
+			{Name: "external::provider", Type: "gateway", Data: "sip:1234@sip.provider.com", State: "REGED"},
+			{Name: "internal", Type: "profile", Data: "sip:mod_sofia@192.168.0.1:5060", State: "RUNNING (2)"},
+			{Name: "192.168.0.1", Type: "alias", Data: "internal", State: "ALIASED"},
+		}
+
+		if len(entries) != len(expected) {
+			t.Fatalf("%s: expected %d entries, got %+v", name, len(expected), entries)
+		}
+
+		for i := range expected {
+			if entries[i] != expected[i] {
+				t.Fatalf("%s: expected %+v, got %+v", name, expected[i], entries[i])
+			}
+		}
+	}
+}
+
+func TestParseSofiaGateways(t *testing.T) {
+	gws, err := ParseSofiaGateways(fixture(t, "sofia_xmlstatus_gateway.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gws) != 2 {
+		t.Fatalf("Expected 2 gateways, got %+v", gws)
+	}
+
+	gw := gws[0]
+	if gw.Name != "provider" || gw.Profile != "external" || gw.State != "REGED" || !gw.Up() {
+		t.Fatalf("Unexpected gateway: %+v", gw)
+	}
+
+	if gw.PingTime != 23.45 || gw.Uptime() != 1725899820134*time.Microsecond {
+		t.Fatalf("Unexpected gateway timings: %+v", gw)
+	}
+
+	if gws[1].Name != "backup" || gws[1].State != "FAIL_WAIT" || gws[1].Up() {
+		t.Fatalf("Unexpected gateway: %+v", gws[1])
+	}
+
+	if _, err := ParseSofiaGateways([]byte("Invalid Gateway!\n")); !errors.As(err, new(*ReplyError)) {
+		t.Fatalf("Expected reply error, got %v", err)
+	}
+}
+
+func TestParseSofiaRegistrations(t *testing.T) {
+	regs, err := ParseSofiaRegistrations(fixture(t, "sofia_xmlstatus_profile_reg.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(regs) != 2 {
+		t.Fatalf("Expected 2 registrations, got %+v", regs)
+	}
+
+	reg := regs[0]
+	if reg.User != "1000@example.com" {
+		t.Fatalf("Unexpected user: %q", reg.User)
+	}
+
+	if reg.Contact != `"John Doe" <sip:1000@10.0.0.2:5060;transport=udp>` {
+		t.Fatalf("Unexpected contact: %q", reg.Contact)
+	}
+
+	if reg.NetworkIP != "10.0.0.2" || reg.NetworkPort != 5060 || reg.Expires != 3540*time.Second {
+		t.Fatalf("Unexpected registration: %+v", reg)
+	}
+
+	if expiry := time.Date(2023, 10, 3, 14, 48, 36, 0, time.Local); !reg.Expiry.Equal(expiry) {
+		t.Fatalf("Expected expiry %s, got %s", expiry, reg.Expiry)
+	}
+
+	if regs[1].NetworkPort != 49152 || regs[1].Expires != 1835*time.Second {
+		t.Fatalf("Unexpected registration: %+v", regs[1])
+	}
+}
+
+func TestSofiaRegistrations(t *testing.T) {
+	reg := fixture(t, "sofia_xmlstatus_profile_reg.xml")
+
+	c, sess := apiClient(t, func(cmd string) string {
+		if cmd == "sofia xmlstatus profile internal reg" {
+			return string(reg)
+		}
+		return "Invalid Profile!"
+	})
+
+	go c.Handle()
+
+	regs, err := c.SofiaRegistrations("internal")
+	if err != nil || len(regs) != 2 {
+		t.Fatalf("Expected 2 registrations, got %+v, %v", regs, err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "sofia xmlstatus profile internal reg" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if _, err := c.SofiaRegistrations("unknown"); !errors.As(err, new(*ReplyError)) {
+		t.Fatalf("Expected reply error, got %v", err)
+	}
+
+	if _, err := c.SofiaRegistrations("internal reg"); err == nil {
+		t.Fatal("Expected profile with whitespace to be rejected")
+	}
+}
+
+func TestSofiaGateway(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("sofia xmlstatus gateway gw1", string(fixture(t, "sofia_xmlstatus_gateway.xml")))
+	srv.HandleAPI("sofia xmlstatus gateway empty", "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<gateways>\n</gateways>\n")
+	srv.HandleAPI("sofia xmlstatus gateway unknown", "Invalid Gateway!\n")
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go c.Handle()
+
+	if gw, err := c.SofiaGateway("gw1"); err != nil || gw.Name == "" {
+		t.Fatalf("Expected gateway, got %+v, %v", gw, err)
+	}
+
+	for _, name := range []string{"empty", "unknown"} {
+		if _, err := c.SofiaGateway(name); !errors.Is(err, ErrNoSuchGateway) {
+			t.Fatalf("Expected ErrNoSuchGateway for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackedChannel - What ChannelTracker knows about live channel
+type TrackedChannel struct {
+	UUID              string
+	Name              string
+	Direction         string
+	State             string
+	CallState         string
+	Answered          bool
+	CallerIDName      string
+	CallerIDNumber    string
+	DestinationNumber string
+	Context           string
+	BridgedTo         string
+	Created           time.Time
+	Variables         map[string]string
+}
+
+// copy - Will return copy of the channel safe to be handed out of tracker lock
+func (ch *TrackedChannel) copy() TrackedChannel {
+	c := *ch
+	c.Variables = make(map[string]string, len(ch.Variables))
+
+	for k, v := range ch.Variables {
+		c.Variables[k] = v
+	}
+
+	return c
+}
+
+// trackedEvents - Events ChannelTracker needs to be subscribed to
+var trackedEvents = []string{
+	"CHANNEL_CREATE",
+	"CHANNEL_STATE",
+	"CHANNEL_ANSWER",
+	"CHANNEL_BRIDGE",
+	"CHANNEL_UNBRIDGE",
+	"CHANNEL_CALLSTATE",
+	"CHANNEL_HANGUP",
+	"CHANNEL_HANGUP_COMPLETE",
+}
+
+// ChannelTracker - Keeps in-memory view of live channels out of channel events. Attach it to a connection
+// subscribed to the events (see TrackedEvents) and attach it again to the new connection every time connection
+// is re-established, so that channels created or destroyed while we were not connected are picked up.
+type ChannelTracker struct {
+	mtx      sync.RWMutex
+	channels map[string]*TrackedChannel
+
+	// While Sync is running, channels events touched or hung up are remembered so snapshot does not override them
+	syncing bool
+	touched map[string]bool
+	gone    map[string]bool
+
+	subs []*Subscription
+}
+
+// NewChannelTracker - Will create empty channel tracker
+func NewChannelTracker() *ChannelTracker {
+	return &ChannelTracker{channels: make(map[string]*TrackedChannel)}
+}
+
+// TrackedEvents - Will return names of the events tracker needs connection to be subscribed to
+func TrackedEvents() []string {
+	return append([]string(nil), trackedEvents...)
+}
+
+// Attach - Will register tracker as handler of channel events on the connection, detaching it from connection it
+// was attached to before, and Sync it in the background. Handle() has to be running for sync to complete, in case
+// it fails or takes longer than DefaultSyncTimeout tracker keeps going with events alone. Keep in mind that events handled by tracker are no longer
+// returned by ReadMsg, register your own handlers for them if needed.
+func (t *ChannelTracker) Attach(c *SocketConnection) {
+	t.Detach()
+
+	// Events coming in before snapshot is taken take precedence over it
+	t.beginSync()
+
+	for _, name := range trackedEvents {
+		t.subs = append(t.subs, c.OnEvent(name, t.Apply))
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultSyncTimeout)
+		defer cancel()
+
+		if err := t.endSync(c.ShowChannelsContext(ctx)); err != nil {
+			Warn("Could not sync tracked channels: %s", err)
+		}
+	}()
+}
+
+// Detach - Will stop tracking events of the connection tracker got attached to
+func (t *ChannelTracker) Detach() {
+	for _, sub := range t.subs {
+		sub.Unregister()
+	}
+
+	t.subs = nil
+}
+
+// Sync - Will replace tracked channels with what `show channels` reports. Events received while this is running
+// take precedence over the snapshot. Attach does it for you.
+func (t *ChannelTracker) Sync(c *SocketConnection) error {
+	t.beginSync()
+	return t.endSync(c.ShowChannels())
+}
+
+// beginSync - Will start remembering channels events touched or hung up until endSync
+func (t *ChannelTracker) beginSync() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.syncing = true
+	t.touched = make(map[string]bool)
+	t.gone = make(map[string]bool)
+}
+
+// endSync - Will replace tracked channels with show channels rows, keeping channels events touched since beginSync
+func (t *ChannelTracker) endSync(rows []ChannelRow, err error) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.syncing = false
+
+	if err != nil {
+		return err
+	}
+
+	channels := make(map[string]*TrackedChannel, len(rows))
+
+	for _, row := range rows {
+		if t.gone[row.UUID] {
+			continue
+		}
+
+		if ch, ok := t.channels[row.UUID]; ok && t.touched[row.UUID] {
+			channels[row.UUID] = ch
+			continue
+		}
+
+		ch := &TrackedChannel{
+			UUID:              row.UUID,
+			Name:              row.Name,
+			Direction:         row.Direction,
+			State:             row.State,
+			CallState:         row.CallState,
+			Answered:          row.CallState == "ACTIVE" || row.CallState == "HELD",
+			CallerIDName:      row.CIDName,
+			CallerIDNumber:    row.CIDNum,
+			DestinationNumber: row.Dest,
+			Context:           row.Context,
+			Created:           epochTime(row.CreatedEpoch),
+			Variables:         make(map[string]string),
+		}
+
+		// Keep what we know about bridge partner and variables, show channels does not have it
+		if old, ok := t.channels[row.UUID]; ok {
+			ch.BridgedTo = old.BridgedTo
+			ch.Variables = old.Variables
+		}
+
+		channels[row.UUID] = ch
+	}
+
+	// Channels created while snapshot was being taken
+	for uuid := range t.touched {
+		if ch, ok := t.channels[uuid]; ok && !t.gone[uuid] {
+			channels[uuid] = ch
+		}
+	}
+
+	t.channels = channels
+	t.touched = nil
+	t.gone = nil
+
+	return nil
+}
+
+// Apply - Will update tracked channels with the event. Called for you once tracker is attached to connection,
+// but can be used directly in case you're reading events with ReadMsg.
+func (t *ChannelTracker) Apply(msg *Message) {
+	te, err := DecodeEvent(msg)
+	if err != nil {
+		return
+	}
+
+	e := te.Base()
+	if e.UUID == "" {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.syncing {
+		t.touched[e.UUID] = true
+	}
+
+	if e.Name == "CHANNEL_HANGUP_COMPLETE" {
+		if ch, ok := t.channels[e.UUID]; ok && ch.BridgedTo != "" {
+			if other, ok := t.channels[ch.BridgedTo]; ok && other.BridgedTo == e.UUID {
+				other.BridgedTo = ""
+			}
+		}
+
+		delete(t.channels, e.UUID)
+
+		if t.syncing {
+			t.gone[e.UUID] = true
+		}
+
+		return
+	}
+
+	ch, ok := t.channels[e.UUID]
+	if !ok {
+		ch = &TrackedChannel{UUID: e.UUID, Created: e.Timestamp, Variables: make(map[string]string)}
+		t.channels[e.UUID] = ch
+	}
+
+	t.update(ch, e)
+
+	switch ev := te.(type) {
+	case *ChannelAnswerEvent:
+		ch.Answered = true
+	case *ChannelBridgeEvent:
+		t.bridge(ch, ev.OtherLegUUID)
+	case *ChannelUnbridgeEvent:
+		t.unbridge(ch)
+	}
+}
+
+// update - Will copy channel fields found in every channel event onto tracked channel
+func (t *ChannelTracker) update(ch *TrackedChannel, e *Event) {
+	set := func(field *string, header string) {
+		if v := e.Header(header); v != "" {
+			*field = v
+		}
+	}
+
+	set(&ch.Name, "Channel-Name")
+	set(&ch.Direction, "Call-Direction")
+	set(&ch.State, "Channel-State")
+	set(&ch.CallState, "Channel-Call-State")
+	set(&ch.CallerIDName, "Caller-Caller-ID-Name")
+	set(&ch.CallerIDNumber, "Caller-Caller-ID-Number")
+	set(&ch.DestinationNumber, "Caller-Destination-Number")
+	set(&ch.Context, "Caller-Context")
+
+	if e.Header("Answer-State") == "answered" {
+		ch.Answered = true
+	}
+
+	for k, v := range e.Variables() {
+		ch.Variables[k] = v
+	}
+}
+
+// bridge - Will mark both channels as bridged to each other. Must be called with lock held.
+func (t *ChannelTracker) bridge(ch *TrackedChannel, other string) {
+	if other == "" {
+		return
+	}
+
+	ch.BridgedTo = other
+
+	if o, ok := t.channels[other]; ok {
+		o.BridgedTo = ch.UUID
+	}
+}
+
+// unbridge - Will clear bridge partner of channel and of its partner. Must be called with lock held.
+func (t *ChannelTracker) unbridge(ch *TrackedChannel) {
+	if o, ok := t.channels[ch.BridgedTo]; ok && o.BridgedTo == ch.UUID {
+		o.BridgedTo = ""
+	}
+
+	ch.BridgedTo = ""
+}
+
+// Get - Will return copy of tracked channel
+func (t *ChannelTracker) Get(uuid string) (TrackedChannel, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	ch, ok := t.channels[uuid]
+	if !ok {
+		return TrackedChannel{}, false
+	}
+
+	return ch.copy(), true
+}
+
+// List - Will return copies of all tracked channels ordered by creation time
+func (t *ChannelTracker) List() []TrackedChannel {
+	t.mtx.RLock()
+	list := make([]TrackedChannel, 0, len(t.channels))
+
+	for _, ch := range t.channels {
+		list = append(list, ch.copy())
+	}
+	t.mtx.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Created.Equal(list[j].Created) {
+			return list[i].UUID < list[j].UUID
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+
+	return list
+}
+
+// Len - Will return number of tracked channels
+func (t *ChannelTracker) Len() int {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return len(t.channels)
+}
@@ -0,0 +1,269 @@
+package goesl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+// channelEvent - Will parse text/event-plain frame built out of event headers
+func channelEvent(t *testing.T, headers string) *Message {
+	m, err := NewMessage(reader(plainEvent(headers, "")), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestChannelTrackerApply(t *testing.T) {
+	tracker := NewChannelTracker()
+
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_CREATE\nUnique-ID: a\nChannel-Name: sofia/internal/1000\nChannel-State: CS_INIT\nCall-Direction: inbound\nCaller-Caller-ID-Number: 1000\nvariable_foo: bar\n"))
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_CREATE\nUnique-ID: b\nChannel-State: CS_INIT\nCall-Direction: outbound\n"))
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_ANSWER\nUnique-ID: a\nChannel-State: CS_EXECUTE\nChannel-Call-State: ACTIVE\n"))
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_BRIDGE\nUnique-ID: a\nOther-Leg-Unique-ID: b\n"))
+
+	if tracker.Len() != 2 {
+		t.Fatalf("Expected 2 channels, got %d", tracker.Len())
+	}
+
+	a, ok := tracker.Get("a")
+	if !ok || !a.Answered || a.State != "CS_EXECUTE" || a.CallState != "ACTIVE" || a.CallerIDNumber != "1000" || a.Variables["foo"] != "bar" {
+		t.Fatalf("Unexpected channel a: %+v", a)
+	}
+
+	if b, _ := tracker.Get("b"); a.BridgedTo != "b" || b.BridgedTo != "a" {
+		t.Fatalf("Expected a and b to be bridged, got %q and %q", a.BridgedTo, b.BridgedTo)
+	}
+
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_CALLSTATE\nUnique-ID: a\nChannel-Call-State: HELD\n"))
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_UNBRIDGE\nUnique-ID: a\nOther-Leg-Unique-ID: b\n"))
+
+	a, _ = tracker.Get("a")
+	if b, _ := tracker.Get("b"); a.BridgedTo != "" || b.BridgedTo != "" || a.CallState != "HELD" {
+		t.Fatalf("Expected a and b to be unbridged and a held, got %+v and %+v", a, b)
+	}
+
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_HANGUP_COMPLETE\nUnique-ID: a\n"))
+
+	if _, ok := tracker.Get("a"); ok || tracker.Len() != 1 {
+		t.Fatalf("Expected a to be gone, got %d channels", tracker.Len())
+	}
+}
+
+func TestChannelTrackerSync(t *testing.T) {
+	c, _ := apiClient(t, func(cmd string) string {
+		return `{"row_count":2,"rows":[` +
+			`{"uuid":"a","direction":"inbound","created_epoch":"1696367616","name":"sofia/internal/1000","state":"CS_EXECUTE","cid_num":"1000","dest":"541","callstate":"ACTIVE"},` +
+			`{"uuid":"c","direction":"outbound","created_epoch":"1696367617","name":"sofia/internal/1001","state":"CS_EXCHANGE_MEDIA","callstate":"RINGING"}]}`
+	})
+
+	go c.Handle()
+
+	tracker := NewChannelTracker()
+
+	// Stale channel we missed hangup of while disconnected
+	tracker.Apply(channelEvent(t, "Event-Name: CHANNEL_CREATE\nUnique-ID: stale\n"))
+
+	if err := tracker.Sync(&c.SocketConnection); err != nil {
+		t.Fatal(err)
+	}
+
+	list := tracker.List()
+	if len(list) != 2 || list[0].UUID != "a" || list[1].UUID != "c" {
+		t.Fatalf("Unexpected channels after sync: %+v", list)
+	}
+
+	if !list[0].Answered || list[0].DestinationNumber != "541" || !list[0].Created.Equal(time.Unix(1696367616, 0)) {
+		t.Fatalf("Unexpected channel a after sync: %+v", list[0])
+	}
+}
+
+func TestChannelTrackerAttach(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return `{"row_count":0}`
+	})
+
+	go c.Handle()
+
+	tracker := NewChannelTracker()
+	tracker.Attach(&c.SocketConnection)
+
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "a"))
+	sess.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE", "Unique-ID", "a"))
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "b"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := tracker.Get("b"); ok && tracker.Len() == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Tracker did not pick up events, got %+v", tracker.List())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tracker.Detach()
+}
+
+func TestChannelTrackerReattach(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("show channels as json", `{"row_count":0}`)
+
+	host, port := srv.HostPort()
+
+	// connect - Will establish connection, attach tracker to it and return its session on the server side
+	connect := func() (*Client, *esltest.Session) {
+		c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go c.Handle()
+
+		sess, err := srv.Accept(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c, sess
+	}
+
+	tracker := NewChannelTracker()
+
+	// waitFor - Will wait until tracker tracks exactly given channels
+	waitFor := func(uuids ...string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			ok := tracker.Len() == len(uuids)
+			for _, uuid := range uuids {
+				if _, found := tracker.Get(uuid); !found {
+					ok = false
+				}
+			}
+
+			if ok {
+				return
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected channels %v, got %+v", uuids, tracker.List())
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	c, sess := connect()
+	defer c.Close()
+
+	tracker.Attach(&c.SocketConnection)
+
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "a"))
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "b"))
+	waitFor("a", "b")
+
+	// While connection is down a hangs up and c gets created
+	sess.Close()
+
+	srv.HandleAPI("show channels as json", `{"row_count":2,"rows":[`+
+		`{"uuid":"b","direction":"inbound","created_epoch":"1696367616","name":"sofia/internal/1000","state":"CS_EXECUTE","callstate":"ACTIVE"},`+
+		`{"uuid":"c","direction":"outbound","created_epoch":"1696367617","name":"sofia/internal/1001","state":"CS_EXCHANGE_MEDIA","callstate":"RINGING"}]}`)
+
+	c2, sess := connect()
+	defer c2.Close()
+
+	tracker.Attach(&c2.SocketConnection)
+	waitFor("b", "c")
+
+	// Events of the new connection are tracked, old one is detached
+	sess.SendEvent(esltest.NewEvent("CHANNEL_HANGUP_COMPLETE", "Unique-ID", "b"))
+	waitFor("c")
+}
+
+func TestChannelTrackerCallState(t *testing.T) {
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	srv.HandleAPI("show channels as json", `{"row_count":0}`)
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go c.Handle()
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewChannelTracker()
+	tracker.Attach(&c.SocketConnection)
+	defer tracker.Detach()
+
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Unique-ID", "a", "Channel-Call-State", "RINGING"))
+	sess.SendEvent(esltest.NewEvent("CHANNEL_CALLSTATE", "Unique-ID", "a", "Channel-Call-State", "HELD", "Original-Channel-Call-State", "ACTIVE"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if a, ok := tracker.Get("a"); ok && a.CallState == "HELD" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Tracker did not pick up call state, got %+v", tracker.List())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChannelTrackerAttachSyncTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { DefaultSyncTimeout = timeout }(DefaultSyncTimeout)
+	DefaultSyncTimeout = 50 * time.Millisecond
+
+	srv := esltest.NewServer("")
+	defer srv.Close()
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Handle() is not running, nobody ever reads show channels reply
+	tracker := NewChannelTracker()
+	tracker.Attach(&c.SocketConnection)
+	defer tracker.Detach()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tracker.mtx.RLock()
+		syncing := tracker.syncing
+		tracker.mtx.RUnlock()
+
+		if !syncing {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Tracker is still waiting on show channels")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -6,6 +6,15 @@
 
 package goesl
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
 // StringInSlice - Will check if string in list. This is equivalent to python if x in []
 func StringInSlice(str string, list []string) bool {
 	for _, value := range list {
@@ -15,3 +24,32 @@ func StringInSlice(str string, list []string) bool {
 	}
 	return false
 }
+
+// epochTime - Will turn unix time in seconds, as freeswitch reports it in list outputs and event headers, into time.
+// Zero (never) and anything that is not a number come back as zero time.
+func epochTime(v string) time.Time {
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(i, 0)
+}
+
+// newUUID - Will generate random (version 4) UUID used to tag commands we send to freeswitch
+func newUUID() string {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		// Falling back to time based bytes. Uniqueness per connection is all we really need here
+		binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(b[8:], atomic.AddUint64(&uuidCounter, 1))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+var uuidCounter uint64
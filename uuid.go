@@ -0,0 +1,287 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Leg - Which leg of the call uuid_* command should be applied to
+type Leg string
+
+const (
+	LegA    Leg = "aleg"
+	LegB    Leg = "bleg"
+	LegBoth Leg = "both"
+)
+
+// HoldMode - What uuid_hold should do with the channel
+type HoldMode string
+
+const (
+	HoldOn     HoldMode = ""
+	HoldOff    HoldMode = "off"
+	HoldToggle HoldMode = "toggle"
+)
+
+// RecordAction - What uuid_record should do with the recording
+type RecordAction string
+
+const (
+	RecordStart  RecordAction = "start"
+	RecordStop   RecordAction = "stop"
+	RecordMask   RecordAction = "mask"
+	RecordUnmask RecordAction = "unmask"
+)
+
+// UndefinedVar - What uuid_getvar replies with when variable is not set on the channel
+const UndefinedVar = "_undef_"
+
+// apiArg - Makes sure argument cannot break out of the api command (new lines would start new ESL command)
+func apiArg(arg string) (string, error) {
+	if err := validateValue(FieldAPIArg, arg); err != nil {
+		return "", err
+	}
+
+	return arg, nil
+}
+
+// apiWord - Same as apiArg but argument must be a single, non empty word as freeswitch splits api args on spaces
+func apiWord(arg string) (string, error) {
+	if err := validateValue(FieldAPIArg, arg); err != nil {
+		return "", err
+	}
+
+	if arg == "" || strings.ContainsAny(arg, " \t") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidArgument, arg)
+	}
+
+	return arg, nil
+}
+
+// quoteArg - Will quote argument that may contain spaces (file paths, app::args) as freeswitch splits args of
+// most commands on spaces unless they are within quotes. Quote itself cannot be escaped so it's rejected.
+func quoteArg(arg string) (string, error) {
+	if arg == "" || strings.Contains(arg, "'") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidArgument, arg)
+	}
+
+	if strings.ContainsAny(arg, " \t") {
+		return "'" + arg + "'", nil
+	}
+
+	return arg, nil
+}
+
+// apiCommand - Will validate and join api command with its args. Last arg is allowed to contain spaces
+// as freeswitch passes rest of the line to it (variable values, file paths...). Empty optional args are skipped.
+func apiCommand(name string, words []string, rest ...string) (string, error) {
+	cmd := []string{name}
+
+	for _, w := range words {
+		w, err := apiWord(w)
+		if err != nil {
+			return "", err
+		}
+
+		cmd = append(cmd, w)
+	}
+
+	for _, r := range rest {
+		if r == "" {
+			continue
+		}
+
+		r, err := apiArg(r)
+		if err != nil {
+			return "", err
+		}
+
+		cmd = append(cmd, r)
+	}
+
+	return strings.Join(cmd, " "), nil
+}
+
+// uuidApi - Will send uuid_* api command and return its response body. Anything that is not +OK is an error.
+func (sc *SocketConnection) uuidApi(name string, words []string, rest ...string) (string, error) {
+	cmd, err := apiCommand(name, words, rest...)
+	if err != nil {
+		return "", err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	body := strings.TrimSpace(string(m.Body))
+
+	if !strings.HasPrefix(body, "+OK") {
+		return "", &ReplyError{Reply: body}
+	}
+
+	return body, nil
+}
+
+// UUIDKill - Will hangup channel with optional hangup cause (e.g. NORMAL_CLEARING)
+func (sc *SocketConnection) UUIDKill(uuid string, cause string) error {
+	words := []string{uuid}
+	if cause != "" {
+		words = append(words, cause)
+	}
+
+	_, err := sc.uuidApi("uuid_kill", words)
+	return err
+}
+
+// UUIDTransfer - Will transfer leg(s) of the channel to destination in dialplan. Dialplan and context are optional.
+func (sc *SocketConnection) UUIDTransfer(uuid string, leg Leg, dest string, dialplan string, context string) error {
+	words := []string{uuid}
+
+	switch leg {
+	case LegB:
+		words = append(words, "-bleg")
+	case LegBoth:
+		words = append(words, "-both")
+	}
+
+	words = append(words, dest)
+
+	if dialplan != "" {
+		words = append(words, dialplan)
+	}
+
+	if context != "" {
+		if dialplan == "" {
+			words = append(words, "XML")
+		}
+		words = append(words, context)
+	}
+
+	_, err := sc.uuidApi("uuid_transfer", words)
+	return err
+}
+
+// UUIDBridge - Will bridge two already existing channels together
+func (sc *SocketConnection) UUIDBridge(uuid string, otherUUID string) error {
+	_, err := sc.uuidApi("uuid_bridge", []string{uuid, otherUUID})
+	return err
+}
+
+// UUIDPark - Will park channel
+func (sc *SocketConnection) UUIDPark(uuid string) error {
+	_, err := sc.uuidApi("uuid_park", []string{uuid})
+	return err
+}
+
+// UUIDHold - Will place channel on hold, take it off hold or toggle hold state depending on mode
+func (sc *SocketConnection) UUIDHold(uuid string, mode HoldMode) error {
+	words := []string{uuid}
+	if mode != HoldOn {
+		words = []string{string(mode), uuid}
+	}
+
+	_, err := sc.uuidApi("uuid_hold", words)
+	return err
+}
+
+// UUIDSetVar - Will set channel variable. Passing empty value unsets the variable.
+func (sc *SocketConnection) UUIDSetVar(uuid string, name string, value string) error {
+	_, err := sc.uuidApi("uuid_setvar", []string{uuid, name}, value)
+	return err
+}
+
+// UUIDGetVar - Will return channel variable value. ok is false in case variable is not set on the channel.
+func (sc *SocketConnection) UUIDGetVar(uuid string, name string) (value string, ok bool, err error) {
+	cmd, err := apiCommand("uuid_getvar", []string{uuid, name})
+	if err != nil {
+		return "", false, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return "", false, err
+	}
+
+	value = strings.TrimRight(string(m.Body), "\r\n")
+
+	if value == UndefinedVar {
+		return "", false, nil
+	}
+
+	return value, true, nil
+}
+
+// UUIDBroadcast - Will play file (or app::args) to the channel. Leg is optional and defaults to aleg. Path with
+// spaces (e.g. speak::flite|kal|Hello world) is quoted so that it stays in one piece.
+func (sc *SocketConnection) UUIDBroadcast(uuid string, path string, leg Leg) error {
+	path, err := quoteArg(path)
+	if err != nil {
+		return err
+	}
+
+	if leg != "" {
+		if _, err := apiWord(string(leg)); err != nil {
+			return err
+		}
+	}
+
+	_, err = sc.uuidApi("uuid_broadcast", []string{uuid}, path, string(leg))
+	return err
+}
+
+// UUIDRecord - Will start/stop/mask/unmask recording of the channel into path. Limit is only used on start, 0 for no limit.
+// Path with spaces is quoted the same way UUIDBroadcast does it.
+func (sc *SocketConnection) UUIDRecord(uuid string, action RecordAction, path string, limit time.Duration) error {
+	path, err := quoteArg(path)
+	if err != nil {
+		return err
+	}
+
+	var seconds string
+	if action == RecordStart && limit > 0 {
+		seconds = strconv.Itoa(int(limit / time.Second))
+	}
+
+	_, err = sc.uuidApi("uuid_record", []string{uuid, string(action)}, path, seconds)
+	return err
+}
+
+// UUIDSendDTMF - Will send DTMF digits to the channel. Tone duration is optional, 0 uses freeswitch default.
+func (sc *SocketConnection) UUIDSendDTMF(uuid string, digits string, duration time.Duration) error {
+	if duration > 0 {
+		digits += "@" + strconv.Itoa(int(duration/time.Millisecond))
+	}
+
+	_, err := sc.uuidApi("uuid_send_dtmf", []string{uuid, digits})
+	return err
+}
+
+// UUIDAnswer - Will answer channel
+func (sc *SocketConnection) UUIDAnswer(uuid string) error {
+	_, err := sc.uuidApi("uuid_answer", []string{uuid})
+	return err
+}
+
+// UUIDExists - Will check if channel exists
+func (sc *SocketConnection) UUIDExists(uuid string) (bool, error) {
+	cmd, err := apiCommand("uuid_exists", []string{uuid})
+	if err != nil {
+		return false, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(string(m.Body)) == "true", nil
+}
@@ -0,0 +1,177 @@
+package goesl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byoungdale/goesl/esltest"
+)
+
+// apiClient - Will connect client to fake freeswitch answering every api command with whatever reply func returns.
+// Returned session is used to check what client wrote on the wire after it authenticated. Handle is left to the test.
+func apiClient(t *testing.T, reply func(cmd string) string) (*Client, *esltest.Session) {
+	t.Helper()
+
+	srv := esltest.NewServer("")
+	t.Cleanup(srv.Close)
+
+	srv.HandleAPIFunc(func(cmd string) (string, bool) {
+		return reply(cmd), true
+	})
+
+	host, port := srv.HostPort()
+
+	c, err := NewClient(host, port, esltest.DefaultPassword, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	sess, err := srv.Accept(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Auth is not what tests are after
+	if _, err := sess.NextCommand(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, sess
+}
+
+// nextAPI - Will return next api command (without api prefix) client sent, skipping anything else (event, sendmsg...)
+func nextAPI(t *testing.T, sess *esltest.Session) string {
+	t.Helper()
+
+	for {
+		cmd, err := sess.NextCommand(time.Second)
+		if err != nil {
+			t.Fatalf("Expected api command, got %v", err)
+		}
+
+		if cmd.Name() == "api" {
+			return cmd.Args()
+		}
+	}
+}
+
+func TestUUIDExists(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		if cmd == "uuid_exists c3b923ab" {
+			return "true"
+		}
+		return "false"
+	})
+
+	go c.Handle()
+
+	ok, err := c.UUIDExists("c3b923ab")
+	if err != nil || !ok {
+		t.Fatalf("Expected channel to exist, got %v, %v", ok, err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "uuid_exists c3b923ab" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	ok, err = c.UUIDExists("unknown")
+	if err != nil || ok {
+		t.Fatalf("Expected channel to not exist, got %v, %v", ok, err)
+	}
+}
+
+func TestUUIDGetVar(t *testing.T) {
+	c, _ := apiClient(t, func(cmd string) string {
+		if cmd == "uuid_getvar c3b923ab caller_id_name" {
+			return "John Doe"
+		}
+		return UndefinedVar
+	})
+
+	go c.Handle()
+
+	value, ok, err := c.UUIDGetVar("c3b923ab", "caller_id_name")
+	if err != nil || !ok || value != "John Doe" {
+		t.Fatalf("Expected John Doe, got %q, %v, %v", value, ok, err)
+	}
+
+	value, ok, err = c.UUIDGetVar("c3b923ab", "missing")
+	if err != nil || ok || value != "" {
+		t.Fatalf("Expected undefined variable, got %q, %v, %v", value, ok, err)
+	}
+}
+
+func TestUUIDKillNoSuchChannel(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		if strings.HasPrefix(cmd, "uuid_kill") {
+			return "-ERR No such channel!\n"
+		}
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	err := c.UUIDKill("c3b923ab", "NORMAL_CLEARING")
+	if !errors.Is(err, ErrNoSuchChannel) {
+		t.Fatalf("Expected ErrNoSuchChannel, got %v", err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "uuid_kill c3b923ab NORMAL_CLEARING" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	// -ERR reply must not tear down connection
+	if err := c.UUIDPark("c3b923ab"); err != nil {
+		t.Fatalf("Expected connection to still be usable, got %v", err)
+	}
+}
+
+func TestUUIDCommands(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return "+OK\n"
+	})
+
+	go c.Handle()
+
+	tests := []struct {
+		call func() error
+		cmd  string
+	}{
+		{func() error { return c.UUIDTransfer("a", LegBoth, "1000", "", "default") }, "uuid_transfer a -both 1000 XML default"},
+		{func() error { return c.UUIDBridge("a", "b") }, "uuid_bridge a b"},
+		{func() error { return c.UUIDHold("a", HoldOff) }, "uuid_hold off a"},
+		{func() error { return c.UUIDSetVar("a", "foo", "bar baz") }, "uuid_setvar a foo bar baz"},
+		{func() error { return c.UUIDBroadcast("a", "/tmp/test.wav", LegB) }, "uuid_broadcast a /tmp/test.wav bleg"},
+		{func() error { return c.UUIDBroadcast("a", "speak::flite|kal|Hello world", "") }, "uuid_broadcast a 'speak::flite|kal|Hello world'"},
+		{func() error { return c.UUIDRecord("a", RecordStart, "/tmp/rec.wav", 0) }, "uuid_record a start /tmp/rec.wav"},
+		{func() error { return c.UUIDRecord("a", RecordStart, "/tmp/my rec.wav", time.Minute) }, "uuid_record a start '/tmp/my rec.wav' 60"},
+		{func() error { return c.UUIDSendDTMF("a", "123#", 0) }, "uuid_send_dtmf a 123#"},
+		{func() error { return c.UUIDAnswer("a") }, "uuid_answer a"},
+	}
+
+	for _, test := range tests {
+		if err := test.call(); err != nil {
+			t.Fatalf("Got error sending %q: %v", test.cmd, err)
+		}
+
+		if cmd := nextAPI(t, sess); cmd != test.cmd {
+			t.Fatalf("Expected %q, got %q", test.cmd, cmd)
+		}
+	}
+}
+
+func TestUUIDInvalidArgument(t *testing.T) {
+	c := newSocketConnection(nil)
+
+	if err := c.UUIDSetVar("a", "foo", "bar\n\napi shutdown"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+
+	if err := c.UUIDKill("a b", ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValetParkingInfo - Subclass of CUSTOM events mod_valet_park reports parking and retrieval of calls with
+const ValetParkingInfo = "valet_parking::info"
+
+// ValetLot - Parking lot as reported by `valet_info`
+type ValetLot struct {
+	Name       string           `xml:"name,attr"`
+	Extensions []ValetExtension `xml:"extension"`
+}
+
+// ValetExtension - Extension of the parking lot and channel parked in it
+type ValetExtension struct {
+	Extension string `xml:",chardata"`
+	UUID      string `xml:"uuid,attr"`
+}
+
+type valetLots struct {
+	Lots []ValetLot `xml:"lot"`
+}
+
+// ValetEvent - CUSTOM valet_parking::info. Action is hold when call got parked, bridge when it got retrieved
+// (BridgeToUUID is the retrieving channel) and exit when parked call left the lot.
+type ValetEvent struct {
+	*Event
+	Lot          string
+	Extension    string
+	Action       string
+	BridgeToUUID string
+}
+
+// valetArgs - Will validate and join valet_park args, all of them are single words
+func valetArgs(args ...string) (string, error) {
+	for _, a := range args {
+		if _, err := apiWord(a); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+// ExecuteValetPark - Helper designed to park active ESL session in extension of the lot. In case extension is
+// already taken, parked call is retrieved and bridged to active ESL session instead.
+func (sc *SocketConnection) ExecuteValetPark(lot string, ext string, sync bool) (m *Message, err error) {
+	args, err := valetArgs(lot, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("valet_park", args, sync)
+}
+
+// ExecuteValetParkAuto - Helper designed to park active ESL session in first free extension of the lot between min and max
+func (sc *SocketConnection) ExecuteValetParkAuto(lot string, min int, max int, sync bool) (m *Message, err error) {
+	args, err := valetArgs(lot, "auto", "in", strconv.Itoa(min), strconv.Itoa(max))
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("valet_park", args, sync)
+}
+
+// ExecuteValetRetrieveAsk - Helper designed to ask caller for extension (between min and max) of the call to retrieve
+// out of the lot, playing prompt and waiting up to timeout for digits
+func (sc *SocketConnection) ExecuteValetRetrieveAsk(lot string, min int, max int, timeout time.Duration, prompt string, sync bool) (m *Message, err error) {
+	args, err := valetArgs(lot, "ask", strconv.Itoa(min), strconv.Itoa(max), strconv.Itoa(int(timeout/time.Millisecond)), prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.executeApp("valet_park", args, sync)
+}
+
+// ParseValetInfo - Will parse output of `valet_info`
+func ParseValetInfo(body []byte) ([]ValetLot, error) {
+	var lots valetLots
+	if err := decodeXMLReply(body, &lots); err != nil {
+		return nil, err
+	}
+
+	for i := range lots.Lots {
+		for j := range lots.Lots[i].Extensions {
+			ext := &lots.Lots[i].Extensions[j]
+			ext.Extension = strings.TrimSpace(ext.Extension)
+		}
+	}
+
+	if lots.Lots == nil {
+		return []ValetLot{}, nil
+	}
+
+	return lots.Lots, nil
+}
+
+// ValetInfo - Will return parking lots and calls parked in them. Lot is optional, all lots are returned when empty.
+func (sc *SocketConnection) ValetInfo(lot string) ([]ValetLot, error) {
+	cmd, err := apiCommand("valet_info", nil, lot)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := sc.SendApi(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseValetInfo(m.Body)
+}
+
+func newValetEvent(e *Event) *ValetEvent {
+	return &ValetEvent{
+		Event:        e,
+		Lot:          e.Header("Valet-Lot-Name"),
+		Extension:    e.Header("Valet-Extension"),
+		Action:       e.Header("Action"),
+		BridgeToUUID: e.Header("Bridge-To-UUID"),
+	}
+}
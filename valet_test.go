@@ -0,0 +1,74 @@
+package goesl
+
+import (
+	"errors"
+	"testing"
+)
+
+var valetInfo = `<lots>
+  <lot name="lot1">
+    <extension uuid="0dd4e4f7-36ed-a04d-a8f7-7aebb683af50">6001</extension>
+    <extension uuid="6c1ef0b4-8f9b-4a6e-a3e5-6d0f8b0bd3d3">6002</extension>
+  </lot>
+  <lot name="lot2">
+  </lot>
+</lots>
+`
+
+func TestValetArgs(t *testing.T) {
+	args, err := valetArgs("lot1", "auto", "in", "6001", "6010")
+	if err != nil || args != "lot1 auto in 6001 6010" {
+		t.Fatalf("Unexpected args %q, %v", args, err)
+	}
+
+	if _, err := valetArgs("lot 1", "6001"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestValetInfo(t *testing.T) {
+	c, sess := apiClient(t, func(cmd string) string {
+		return valetInfo
+	})
+
+	go c.Handle()
+
+	lots, err := c.ValetInfo("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "valet_info" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+
+	if len(lots) != 2 || lots[0].Name != "lot1" || len(lots[0].Extensions) != 2 || len(lots[1].Extensions) != 0 {
+		t.Fatalf("Unexpected lots: %+v", lots)
+	}
+
+	if ext := lots[0].Extensions[1]; ext.Extension != "6002" || ext.UUID != "6c1ef0b4-8f9b-4a6e-a3e5-6d0f8b0bd3d3" {
+		t.Fatalf("Unexpected extension: %+v", ext)
+	}
+
+	if _, err := c.ValetInfo("lot1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := nextAPI(t, sess); cmd != "valet_info lot1" {
+		t.Fatalf("Unexpected command sent: %q", cmd)
+	}
+}
+
+func TestDecodeValetEvent(t *testing.T) {
+	m := channelEvent(t, "Event-Name: CUSTOM\nEvent-Subclass: valet_parking%3A%3Ainfo\nUnique-ID: a\nValet-Lot-Name: lot1\nValet-Extension: 6001\nAction: bridge\nBridge-To-UUID: b\n")
+
+	te, err := DecodeEvent(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := te.(*ValetEvent)
+	if !ok || ev.Lot != "lot1" || ev.Extension != "6001" || ev.Action != "bridge" || ev.BridgeToUUID != "b" || ev.UUID != "a" {
+		t.Fatalf("Unexpected event: %+v", te)
+	}
+}
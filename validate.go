@@ -0,0 +1,87 @@
+// Copyright 2015 Nevio Vesic
+// Please check out LICENSE file for more information about what you CAN and what you CANNOT do!
+// Basically in short this is a free software for you to do whatever you want to do BUT copyright must be included!
+// I didn't write all of this code so you could say it's yours.
+// MIT License
+
+package goesl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parts of ESL commands validated before anything is sent, reported in InjectionError
+const (
+	FieldCommand     = "command"
+	FieldHeaderName  = "header name"
+	FieldHeaderValue = "header value"
+	FieldUUID        = "uuid"
+	FieldAppName     = "application name"
+	FieldAppArg      = "application argument"
+	FieldAPIArg      = "api argument"
+	FieldVariable    = "variable name"
+)
+
+// unsafeChars - Freeswitch ends command line and headers on \n (\r is stripped along with it) and C strings on \x00.
+// Any of them within a value would let the rest of it be read as new headers or a new command.
+const unsafeChars = "\r\n\x00"
+
+// InjectionError - Value passed along to freeswitch would break out of the part of the command it belongs to.
+// Field is one of FieldCommand, FieldHeaderName, FieldHeaderValue... errors.Is matches it against both
+// ErrCommandInjection and ErrInvalidArgument.
+type InjectionError struct {
+	Field string
+	Value string
+}
+
+// Error - Will describe which value was rejected
+func (e *InjectionError) Error() string {
+	return fmt.Sprintf(EInjectionAttempt, e.Field, e.Value)
+}
+
+// Is - Makes errors.Is(err, ErrCommandInjection) and errors.Is(err, ErrInvalidArgument) work
+func (e *InjectionError) Is(target error) bool {
+	return target == ErrCommandInjection || target == ErrInvalidArgument
+}
+
+// validateValue - Will make sure value cannot end the part of the command it is written into
+func validateValue(field, v string) error {
+	if strings.ContainsAny(v, unsafeChars) {
+		return &InjectionError{Field: field, Value: v}
+	}
+
+	return nil
+}
+
+// validateHeaderName - Header name cannot be empty nor contain anything that would end it early (: included)
+func validateHeaderName(name string) error {
+	if name == "" || strings.ContainsAny(name, ": \t"+unsafeChars) {
+		return &InjectionError{Field: FieldHeaderName, Value: name}
+	}
+
+	return nil
+}
+
+// validateUUID - Channel uuid is written on the sendmsg line and must be a single word
+func validateUUID(uuid string) error {
+	if strings.ContainsAny(uuid, " \t"+unsafeChars) {
+		return &InjectionError{Field: FieldUUID, Value: uuid}
+	}
+
+	return nil
+}
+
+// validateVariable - Channel variable name (set, export...) cannot be empty nor contain = as freeswitch splits
+// name=value on the first one of them
+func validateVariable(name string) error {
+	if err := validateValue(FieldVariable, name); err != nil {
+		return err
+	}
+
+	if name == "" || strings.ContainsAny(name, "= \t") {
+		return fmt.Errorf("%w: variable name %q", ErrInvalidArgument, name)
+	}
+
+	return nil
+}
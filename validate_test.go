@@ -0,0 +1,302 @@
+package goesl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureConn - Keeps whatever is written to it
+type captureConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	return c.buf.Write(b)
+}
+
+// sentCommand - Command as freeswitch would read it off the wire
+type sentCommand struct {
+	Line    string
+	Headers map[string]string
+	Body    string
+}
+
+// captureConnection - Will create connection writing into captureConn. Replies are queued with reply().
+func captureConnection() (*SocketConnection, *captureConn) {
+	conn := &captureConn{}
+
+	return &SocketConnection{
+		Conn: conn,
+		mtx:  &sync.RWMutex{},
+		err:  make(chan error),
+		m:    make(chan *Message, 1),
+	}, conn
+}
+
+func reply(c *SocketConnection) {
+	c.m <- &Message{Headers: map[string]string{"Content-Type": "command/reply", "Reply-Text": "+OK"}}
+}
+
+// readCommands - Will split data into commands the way mod_event_socket does: line ends on \n (\r is stripped),
+// headers end on empty line and body is read only when Content-Length says so
+func readCommands(data []byte) ([]sentCommand, error) {
+	var cmds []sentCommand
+
+	r := bufio.NewReader(bytes.NewReader(data))
+
+	for {
+		cmd := sentCommand{Headers: make(map[string]string)}
+
+		for {
+			line, err := r.ReadString('\n')
+			if err == io.EOF && line == "" && cmd.Line == "" {
+				return cmds, nil
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			if line == "" {
+				if cmd.Line == "" {
+					continue
+				}
+				break
+			}
+
+			if cmd.Line == "" {
+				cmd.Line = line
+				continue
+			}
+
+			if i := strings.Index(line, ":"); i > 0 {
+				cmd.Headers[line[:i]] = strings.TrimLeft(line[i+1:], " ")
+			}
+		}
+
+		if l, err := strconv.Atoi(cmd.Headers["Content-Length"]); err == nil && l > 0 {
+			body := make([]byte, l)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, err
+			}
+
+			cmd.Body = string(body)
+		}
+
+		cmds = append(cmds, cmd)
+	}
+}
+
+func TestInjectionRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		send  func(c *SocketConnection) error
+	}{
+		{"command", FieldCommand, func(c *SocketConnection) error { return c.Send("api status\nexit") }},
+		{"command carriage return", FieldCommand, func(c *SocketConnection) error { return c.Send("api status\rexit") }},
+		{"command nul", FieldCommand, func(c *SocketConnection) error { return c.Send("api status\x00") }},
+		{"api", FieldCommand, func(c *SocketConnection) error { return c.Api("status\n\nexit") }},
+		{"uuid", FieldUUID, func(c *SocketConnection) error {
+			_, err := c.SendMsg(map[string]string{"call-command": "hangup"}, "abc\ncall-command: hangup", "")
+			return err
+		}},
+		{"header name", FieldHeaderName, func(c *SocketConnection) error {
+			_, err := c.SendMsg(map[string]string{"call-command\n": "hangup"}, "", "")
+			return err
+		}},
+		{"header value", FieldHeaderValue, func(c *SocketConnection) error {
+			_, err := c.SendMsg(map[string]string{"call-command": "hangup\x00"}, "", "")
+			return err
+		}},
+		{"app name", FieldAppName, func(c *SocketConnection) error {
+			_, err := c.Execute("playback\nexecute-app-name: system", "", false)
+			return err
+		}},
+		{"app arg", FieldAppArg, func(c *SocketConnection) error {
+			_, err := c.ExecuteUUID("abc", "playback", "/tmp/a.wav\n\nsendmsg abc", false)
+			return err
+		}},
+		{"set key", FieldVariable, func(c *SocketConnection) error {
+			_, err := c.ExecuteSet("a\nexecute-app-name: system", "b", false)
+			return err
+		}},
+		{"set value", FieldAppArg, func(c *SocketConnection) error {
+			_, err := c.ExecuteSet("a", "b\r\n\r\nexit", false)
+			return err
+		}},
+		{"event header", FieldHeaderValue, func(c *SocketConnection) error {
+			_, err := c.SendEvent(OutgoingEvent{Name: "NOTIFY", Headers: map[string]string{"profile": "internal\nhost: evil"}})
+			return err
+		}},
+		{"api arg", FieldAPIArg, func(c *SocketConnection) error { return c.UUIDSetVar("a", "foo", "bar\rapi shutdown") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, conn := captureConnection()
+
+			err := tt.send(c)
+
+			var ierr *InjectionError
+			if !errors.As(err, &ierr) || !errors.Is(err, ErrCommandInjection) || !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("Expected InjectionError, got %v", err)
+			}
+
+			if ierr.Field != tt.field {
+				t.Fatalf("Expected %s to be rejected, got %s", tt.field, ierr.Field)
+			}
+
+			if conn.buf.Len() > 0 {
+				t.Fatalf("Nothing should be sent, got %q", conn.buf.String())
+			}
+		})
+	}
+}
+
+func TestExecuteSetVariable(t *testing.T) {
+	c, conn := captureConnection()
+
+	for _, key := range []string{"", "a=b", "a b"} {
+		if _, err := c.ExecuteSet(key, "1", false); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("Expected variable %q to be rejected, got %v", key, err)
+		}
+	}
+
+	reply(c)
+
+	if _, err := c.ExecuteSet("a", "b=c d", false); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds, err := readCommands(conn.buf.Bytes())
+	if err != nil || len(cmds) != 1 || cmds[0].Headers["execute-app-arg"] != "a=b=c d" {
+		t.Fatalf("Unexpected commands %+v (%v)", cmds, err)
+	}
+}
+
+func FuzzSend(f *testing.F) {
+	for _, seed := range []string{"api status", "api status\nexit", "event plain ALL\r\n\r\napi shutdown", "\x00", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, cmd string) {
+		c, conn := captureConnection()
+
+		if err := c.Send(cmd); err != nil {
+			if !errors.Is(err, ErrCommandInjection) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			return
+		}
+
+		if strings.ContainsAny(cmd, unsafeChars) {
+			t.Fatalf("Accepted command %q", cmd)
+		}
+
+		cmds, err := readCommands(conn.buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cmds) > 1 || (len(cmds) == 1 && cmds[0].Line != cmd) {
+			t.Fatalf("Command %q was sent as %+v", cmd, cmds)
+		}
+	})
+}
+
+func FuzzExecuteUUID(f *testing.F) {
+	f.Add("abc", "playback", "/tmp/test.wav")
+	f.Add("abc\ncall-command: hangup", "playback", "")
+	f.Add("", "set", "a=b\n\nsendmsg\ncall-command: hangup")
+	f.Add("abc", "playback\r", "a")
+	f.Add("abc", "bridge", strings.Repeat("a", ExecuteArgBodyLength+1))
+
+	f.Fuzz(func(t *testing.T, uuid, app, args string) {
+		c, conn := captureConnection()
+		reply(c)
+
+		if _, err := c.ExecuteUUID(uuid, app, args, false); err != nil {
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			return
+		}
+
+		if strings.ContainsAny(uuid+app+args, unsafeChars) {
+			t.Fatalf("Accepted %q %q %q", uuid, app, args)
+		}
+
+		cmds, err := readCommands(conn.buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cmds) != 1 {
+			t.Fatalf("Expected single command, got %+v", cmds)
+		}
+
+		cmd, line := cmds[0], "sendmsg"
+		if uuid != "" {
+			line += " " + uuid
+		}
+
+		if cmd.Line != line || cmd.Headers["call-command"] != "execute" || cmd.Headers["execute-app-name"] != app {
+			t.Fatalf("Unexpected command %+v", cmd)
+		}
+
+		if arg := cmd.Headers["execute-app-arg"] + cmd.Body; arg != strings.TrimLeft(args, " ") && arg != args {
+			t.Fatalf("Expected args %q, got %+v", args, cmd)
+		}
+	})
+}
+
+func FuzzSendEvent(f *testing.F) {
+	f.Add("NOTIFY", "profile", "internal", "body")
+	f.Add("NOTIFY", "profile", "internal\nhost: evil", "")
+	f.Add("SEND_MESSAGE\n", "x: y", "z", "\n\nexit\n\n")
+
+	f.Fuzz(func(t *testing.T, name, key, value, body string) {
+		c, conn := captureConnection()
+		reply(c)
+
+		e := OutgoingEvent{Name: name, Headers: map[string]string{key: value}, Body: body}
+		if strings.EqualFold(key, "Content-Length") {
+			return
+		}
+
+		if _, err := c.SendEvent(e); err != nil {
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			return
+		}
+
+		if strings.ContainsAny(name+key+value, unsafeChars) {
+			t.Fatalf("Accepted %+v", e)
+		}
+
+		cmds, err := readCommands(conn.buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cmds) != 1 || cmds[0].Line != "sendevent "+name || cmds[0].Body != body {
+			t.Fatalf("Event %+v was sent as %+v", e, cmds)
+		}
+
+		if v := cmds[0].Headers[key]; v != value && v != strings.TrimLeft(value, " ") {
+			t.Fatalf("Event %+v was sent as %+v", e, cmds)
+		}
+	})
+}
@@ -6,12 +6,47 @@
 
 package goesl
 
+import "time"
+
 var (
 
 	// Size of buffer when we read from connection.
 	// 1024 << 6 == 65536
 	ReadBufferSize = 1024 << 6
 
+	// Largest Content-Length we accept from freeswitch by default (MessageLimits). Messages announcing more are
+	// rejected with ErrContentTooLarge before any of the body is read.
+	// 1024 << 13 == 8388608
+	MaxContentLength = 1024 << 13
+
+	// How many headers message can have by default (MessageLimits). Channel events carrying all of the channel
+	// variables have a few hundred of them.
+	DefaultMaxHeaders = 4096
+
+	// How long single header line can be by default (MessageLimits)
+	// 1024 << 6 == 65536
+	DefaultMaxLineLength = 1024 << 6
+
+	// How many events can wait to be handled (handlers or ReadMsg) by default before reader stops reading
+	DefaultEventQueueSize = 1024
+
+	// How many channels event handlers (OnEvent & co) can handle in parallel by default
+	DefaultHandlerConcurrency = 8
+
+	// How many api connections ClientPool keeps against freeswitch by default
+	DefaultPoolSize = 4
+
+	// How long ClientPool waits before trying to replace dead connection again
+	DefaultPoolRetryInterval = time.Second
+
+	// How long ChannelTracker.Attach waits for `show channels` before giving up on syncing
+	DefaultSyncTimeout = 10 * time.Second
+
+	// Application args longer than this are sent as sendmsg body (content-type: text/plain) instead of
+	// execute-app-arg header
+	// 1024 << 1 == 2048
+	ExecuteArgBodyLength = 1024 << 1
+
 	// Freeswitch events that we can handle (have logic for it)
-	AvailableMessageTypes = []string{"auth/request", "text/disconnect-notice", "text/event-json", "text/event-plain", "api/response", "command/reply"}
+	AvailableMessageTypes = []string{"auth/request", "text/disconnect-notice", "text/event-json", "text/event-plain", "text/event-xml", "api/response", "command/reply"}
 )